@@ -0,0 +1,270 @@
+package car
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	// the manifest is the first link of the aggregate root, its name sorts before the cids
+	AGGREGATE_MANIFEST_FILENAME = "@AggregateManifest.ndjson"
+	AGGREGATE_MANIFEST_VERSION  = 1
+
+	RECORD_TYPE_PREAMBLE = "DagAggregatePreamble"
+	RECORD_TYPE_SUMMARY  = "DagAggregateSummary"
+	RECORD_TYPE_ENTRY    = "DagAggregateEntry"
+
+	// the manifest file is chunked like go-dagaggregator-unixfs does
+	AGGREGATE_MANIFEST_CHUNK_SIZE = 256 * 1024
+	AGGREGATE_MANIFEST_LINKS      = 8192 / 47
+)
+
+// AggregateEntry is a dag to aggregate, DagSize and NodeCount are optional and recorded in the manifest,
+// DagSize is also the size of the link to the dag
+type AggregateEntry struct {
+	RootCid   Cid
+	DagSize   uint64
+	NodeCount uint64
+}
+
+// ManifestEntry is a line of the manifest, the path of the dag in the aggregate is
+// PathPrefixes[0]/PathPrefixes[1]/DagCidV1, PathIndexes are the indexes of the links on that path
+type ManifestEntry struct {
+	RecordType   string
+	DagCidV1     string
+	DagCidV0     string  `json:",omitempty"`
+	DagSize      *uint64 `json:",omitempty"`
+	NodeCount    *uint64 `json:",omitempty"`
+	PathPrefixes [2]string
+	PathIndexes  [3]int
+}
+
+type manifestPreamble struct {
+	RecordType string
+	Version    uint32
+}
+
+type manifestSummary struct {
+	RecordType      string
+	EntryCount      int
+	EntriesSortedBy string
+	Description     string
+}
+
+// Block is a block of a dag with its cid
+type Block struct {
+	Cid  Cid
+	Data []byte
+}
+
+// Aggregation is the result of Aggregate, Blocks are the blocks of the aggregate dag which are not in the aggregated dags
+type Aggregation struct {
+	Root    Cid
+	Entries []*ManifestEntry // sorted by DagCidV1
+	Blocks  []Block
+}
+
+// blockCollector keeps the blocks put in memory, each block once
+type blockCollector struct {
+	blocks  []Block
+	written map[string]bool
+}
+
+func (blockCollector *blockCollector) putBlock(cid Cid, data []byte) error {
+	if blockCollector.written[string(cid)] {
+		return nil
+	}
+
+	blockCollector.blocks = append(blockCollector.blocks, Block{Cid: cid, Data: append([]byte{}, data...)})
+	blockCollector.written[string(cid)] = true
+	return nil
+}
+
+// Aggregate builds a unixfs directory linking the dags of entries, with the layout and the manifest of
+// go-dagaggregator-unixfs so that the aggregate root is the same. The dags are deduplicated and sharded
+// in two levels of directories named after the ends of their cids.
+func Aggregate(entries []AggregateEntry) (*Aggregation, error) {
+	dags := map[string]*ManifestEntry{}
+	dagCids := map[string]Cid{}
+
+	for _, entry := range entries {
+		_, codec, multihash, err := entry.RootCid.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		cidV1 := cidFromMultihash(codec, multihash)
+		dagCidV1 := cidV1.String()
+		if _, exists := dags[dagCidV1]; exists {
+			continue
+		}
+
+		manifestEntry := &ManifestEntry{
+			RecordType: RECORD_TYPE_ENTRY,
+			DagCidV1:   dagCidV1,
+		}
+		if codec == CODEC_DAG_PB && len(multihash) == 2+sha256.Size && multihash[0] == MULTIHASH_SHA2_256 {
+			manifestEntry.DagCidV0 = Cid(multihash).String()
+		}
+		if entry.DagSize != 0 {
+			dagSize := entry.DagSize
+			manifestEntry.DagSize = &dagSize
+		}
+		if entry.NodeCount != 0 {
+			nodeCount := entry.NodeCount
+			manifestEntry.NodeCount = &nodeCount
+		}
+
+		dags[dagCidV1] = manifestEntry
+		dagCids[dagCidV1] = cidV1
+	}
+
+	if len(dags) == 0 {
+		err := fmt.Errorf("no dag to aggregate")
+		return nil, err
+	}
+
+	manifestEntries := make([]*ManifestEntry, 0, len(dags))
+	for _, manifestEntry := range dags {
+		manifestEntries = append(manifestEntries, manifestEntry)
+	}
+	sort.Slice(manifestEntries, func(i, j int) bool {
+		return manifestEntries[i].DagCidV1 < manifestEntries[j].DagCidV1
+	})
+
+	blockCollector := &blockCollector{written: map[string]bool{}}
+	dagBuilder := newDagBuilder(blockCollector, AGGREGATE_MANIFEST_CHUNK_SIZE, AGGREGATE_MANIFEST_LINKS)
+
+	// inner directories are named after the first 3 and the last 4 characters of the cids they link
+	innerLinks := map[string][]*link{}
+	for _, manifestEntry := range manifestEntries {
+		dagCidV1 := manifestEntry.DagCidV1
+		innerName := dagCidV1[:3] + "..." + dagCidV1[len(dagCidV1)-4:]
+
+		tsize := uint64(0)
+		if manifestEntry.DagSize != nil {
+			tsize = *manifestEntry.DagSize
+		}
+
+		manifestEntry.PathPrefixes[1] = innerName
+		manifestEntry.PathIndexes[2] = len(innerLinks[innerName])
+		innerLinks[innerName] = append(innerLinks[innerName], &link{cid: dagCids[dagCidV1], name: dagCidV1, tsize: tsize})
+	}
+
+	// outer directories drop the 2 characters before the last 2 of the inner names
+	outerLinks := map[string][]*link{}
+	innerIndexes := map[string]int{}
+	for _, innerName := range sortedKeys(innerLinks) {
+		inner, err := dagBuilder.addNode(innerLinks[innerName], encodeUnixfsData(UNIXFS_DIRECTORY, nil, nil, nil), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		inner.name = innerName
+		outerName := innerName[:len(innerName)-4] + innerName[len(innerName)-2:]
+		innerIndexes[innerName] = len(outerLinks[outerName])
+		outerLinks[outerName] = append(outerLinks[outerName], inner)
+	}
+
+	rootLinks := []*link{}
+	outerIndexes := map[string]int{}
+	for _, outerName := range sortedKeys(outerLinks) {
+		outer, err := dagBuilder.addNode(outerLinks[outerName], encodeUnixfsData(UNIXFS_DIRECTORY, nil, nil, nil), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		outer.name = outerName
+		rootLinks = append(rootLinks, outer)
+		// the manifest is the first link of the root
+		outerIndexes[outerName] = len(rootLinks)
+	}
+
+	for _, manifestEntry := range manifestEntries {
+		innerName := manifestEntry.PathPrefixes[1]
+		outerName := innerName[:len(innerName)-4] + innerName[len(innerName)-2:]
+
+		manifestEntry.PathPrefixes[0] = outerName
+		manifestEntry.PathIndexes[0] = outerIndexes[outerName]
+		manifestEntry.PathIndexes[1] = innerIndexes[innerName]
+	}
+
+	manifestJson, err := encodeManifest(manifestEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := dagBuilder.addFile(bytes.NewReader(manifestJson))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.name = AGGREGATE_MANIFEST_FILENAME
+	rootLinks = append([]*link{manifest}, rootLinks...)
+
+	root, err := dagBuilder.addNode(rootLinks, encodeUnixfsData(UNIXFS_DIRECTORY, nil, nil, nil), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregation := &Aggregation{
+		Root:    root.cid,
+		Entries: manifestEntries,
+		Blocks:  blockCollector.blocks,
+	}
+
+	return aggregation, nil
+}
+
+// encodeManifest encodes the manifest as ndjson: the preamble, the summary then the entries
+func encodeManifest(manifestEntries []*ManifestEntry) ([]byte, error) {
+	manifestJson := &bytes.Buffer{}
+	encoder := json.NewEncoder(manifestJson)
+
+	records := []interface{}{
+		manifestPreamble{
+			RecordType: RECORD_TYPE_PREAMBLE,
+			Version:    AGGREGATE_MANIFEST_VERSION,
+		},
+		manifestSummary{
+			RecordType:      RECORD_TYPE_SUMMARY,
+			EntryCount:      len(manifestEntries),
+			EntriesSortedBy: "DagCidV1",
+			// kept as is, the manifest is the format of go-dagaggregator-unixfs
+			Description: "Aggregate of non-related DAGs, produced by github.com/filecoin-project/go-dagaggregator-unixfs",
+		},
+	}
+	for _, manifestEntry := range manifestEntries {
+		records = append(records, manifestEntry)
+	}
+
+	for _, record := range records {
+		err := encoder.Encode(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return manifestJson.Bytes(), nil
+}
+
+func sortedKeys(links map[string][]*link) []string {
+	keys := make([]string, 0, len(links))
+	for key := range links {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// cidFromMultihash returns the CID v1 of codec and multihash
+func cidFromMultihash(codec uint64, multihash []byte) Cid {
+	cid := binary.AppendUvarint(nil, CID_VERSION_1)
+	cid = binary.AppendUvarint(cid, codec)
+	return append(cid, multihash...)
+}
@@ -0,0 +1,183 @@
+package car
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dagaggregator "github.com/filecoin-project/go-dagaggregator-unixfs"
+	"github.com/filecoin-project/go-dagaggregator-unixfs/lib/rambs"
+	"github.com/ipfs/go-blockservice"
+	gocid "github.com/ipfs/go-cid"
+	exchangeoffline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-merkledag"
+)
+
+// testFiles creates files under dir, the keys are slash separated paths and the values their sizes
+func testFiles(t *testing.T, dir string, files map[string]int) {
+	for path, size := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(path))
+		err := os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i*7 + len(path))
+		}
+		err = os.WriteFile(filePath, data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testCarEntry generates the car of files and returns its root with the stats of its blocks
+func testCarEntry(t *testing.T, files map[string]int, srcName string) AggregateEntry {
+	dir := t.TempDir()
+	testFiles(t, dir, files)
+
+	carFilePath := filepath.Join(t.TempDir(), "entry.car")
+	fileDesc, err := GenerateCarFile(filepath.Join(dir, srcName), carFilePath, Config{ChunkSize: 1024, LinksPerBlock: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := ParseCid(fileDesc.PayloadCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carStat, err := ReadCarFileStat(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return AggregateEntry{RootCid: root, DagSize: carStat.Size, NodeCount: carStat.NumBlocks}
+}
+
+func testParseCids(t *testing.T, cidStrs ...string) []AggregateEntry {
+	entries := []AggregateEntry{}
+	for _, cidStr := range cidStrs {
+		cid, err := ParseCid(cidStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, AggregateEntry{RootCid: cid})
+	}
+
+	return entries
+}
+
+// dagaggregatorAggregate aggregates entries with go-dagaggregator-unixfs and returns its root and its manifest entries
+func dagaggregatorAggregate(t *testing.T, entries []AggregateEntry) (gocid.Cid, []*dagaggregator.ManifestDagEntry) {
+	ramBs := new(rambs.RamBs)
+	ramDs := merkledag.NewDAGService(blockservice.New(ramBs, exchangeoffline.Exchange(ramBs)))
+
+	aggregateDagEntries := []dagaggregator.AggregateDagEntry{}
+	for _, entry := range entries {
+		rootCid, err := gocid.Cast(entry.RootCid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aggregateDagEntries = append(aggregateDagEntries, dagaggregator.AggregateDagEntry{
+			RootCid:                   rootCid,
+			UniqueBlockCount:          entry.NodeCount,
+			UniqueBlockCumulativeSize: entry.DagSize,
+		})
+	}
+
+	root, manifestEntries, err := dagaggregator.Aggregate(context.Background(), ramDs, aggregateDagEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return root, manifestEntries
+}
+
+func TestAggregateMatchesDagaggregator(t *testing.T) {
+	manyDags := []AggregateEntry{}
+	for i := 0; i < 500; i++ {
+		manyDags = append(manyDags, AggregateEntry{RootCid: newCid(CODEC_RAW, []byte(fmt.Sprint("block ", i))), DagSize: uint64(i)})
+	}
+
+	directory := testCarEntry(t, map[string]int{
+		"dir/a":         100,
+		"dir/b/c":       5000,
+		"dir/b/d/e":     0,
+		"dir/b/d/large": 20000,
+		"dir/f":         1024,
+	}, "dir")
+	directoryV0 := AggregateEntry{RootCid: Cid(directory.RootCid[2:])}
+
+	layouts := map[string][]AggregateEntry{
+		"small file":  {testCarEntry(t, map[string]int{"file": 10}, "file")},
+		"large file":  {testCarEntry(t, map[string]int{"file": 50000}, "file")},
+		"directory":   {directory},
+		"mixed dags":  {directory, testCarEntry(t, map[string]int{"file": 3000}, "file"), testCarEntry(t, map[string]int{"dir/file": 1}, "dir")},
+		"duplicates":  {directoryV0, directory, directory},
+		"many blocks": manyDags,
+		// the dags of the test of go-dagaggregator-unixfs, with colliding directory names
+		"cids": testParseCids(t,
+			"QmQy6xmJhrcC5QLboAcGFcAE1tC8CrwDVkrHdEYJkLscrQ",
+			"bafybeibhbx3y6tnn7q4gpsous6apnobft5jybvroiepdsmvps2lmycjjxu",
+			"bafkreihjji2ny4zwyh7ubc3bmdb5tj455vi5fhsbwf2uvcw6l75z446qea",
+			"bafkreialad2qaplrgjs2x2rs4fycwmwmpmocantoho3doxulmbmlrg6qea",
+			"bafkreiarcpog7fgb3cvs4iznh6jcqtxgyyk5rbsmk4dvxuty5tylof6qea",
+		),
+	}
+
+	for name, entries := range layouts {
+		t.Run(name, func(t *testing.T) {
+			aggregation, err := Aggregate(entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			root, manifestEntries := dagaggregatorAggregate(t, entries)
+			if aggregation.Root.String() != root.String() {
+				t.Fatalf("aggregate root: got %s, go-dagaggregator-unixfs has %s", aggregation.Root.String(), root.String())
+			}
+
+			entriesJson, err := json.Marshal(aggregation.Entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifestEntriesJson, err := json.Marshal(manifestEntries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(entriesJson) != string(manifestEntriesJson) {
+				t.Fatalf("manifest entries: got %s, go-dagaggregator-unixfs has %s", entriesJson, manifestEntriesJson)
+			}
+		})
+	}
+}
+
+func TestAggregateKnownRoot(t *testing.T) {
+	entries := testParseCids(t,
+		"QmQy6xmJhrcC5QLboAcGFcAE1tC8CrwDVkrHdEYJkLscrQ",
+		"bafybeibhbx3y6tnn7q4gpsous6apnobft5jybvroiepdsmvps2lmycjjxu",
+		"bafkreihjji2ny4zwyh7ubc3bmdb5tj455vi5fhsbwf2uvcw6l75z446qea",
+		"bafkreialad2qaplrgjs2x2rs4fycwmwmpmocantoho3doxulmbmlrg6qea",
+		"bafkreiarcpog7fgb3cvs4iznh6jcqtxgyyk5rbsmk4dvxuty5tylof6qea",
+	)
+	entries[0].DagSize = 42
+	entries[0].NodeCount = 1
+	// the same dag as the first one, its stats are ignored
+	entries[1].DagSize = 666
+	entries[1].NodeCount = 666
+
+	aggregation, err := Aggregate(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the root expected by the test of go-dagaggregator-unixfs
+	if root := aggregation.Root.String(); root != "bafybeib62b4ukyzjcj7d2h4mbzjgg7l6qiz3ma4vb4b2bawmcauf5afvua" {
+		t.Fatalf("aggregate root: got %s", root)
+	}
+}
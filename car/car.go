@@ -0,0 +1,142 @@
+// Package car generates car files natively, like lotus client import and generate-car, without lotus nor ipfs
+package car
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/filswan/go-swan-lib/commp"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/filswan/go-swan-lib/model"
+)
+
+const (
+	// same as lotus client import
+	CHUNK_SIZE_DEFAULT      = 1024 * 1024
+	LINKS_PER_BLOCK_DEFAULT = 1024
+)
+
+type Config struct {
+	Version       int  // CAR_VERSION_1 or CAR_VERSION_2 with an index, 0 means CAR_VERSION_1
+	ChunkSize     int  // bytes of file data per leaf, 0 means CHUNK_SIZE_DEFAULT
+	LinksPerBlock int  // children of the internal nodes of a file, 0 means LINKS_PER_BLOCK_DEFAULT
+	CalcPieceCid  bool // also calculate the piece cid of the car file, see the commp package
+}
+
+// GenerateCarFile writes the car file of srcPath, a file or a directory, to carFilePath.
+// The blocks are written as the dag is built, leaves first and the root last, each block once.
+func GenerateCarFile(srcPath, carFilePath string, config Config) (*model.FileDesc, error) {
+	if config.Version == 0 {
+		config.Version = CAR_VERSION_1
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = CHUNK_SIZE_DEFAULT
+	}
+	if config.LinksPerBlock <= 1 {
+		config.LinksPerBlock = LINKS_PER_BLOCK_DEFAULT
+	}
+
+	srcFileInfo, err := os.Stat(srcPath)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	carFile, err := os.Create(carFilePath)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	root, sourceSize, err := writeCar(carFile, srcPath, srcFileInfo, config)
+	if err != nil {
+		logs.Log().Error(err)
+		carFile.Close()
+		os.Remove(carFilePath)
+		return nil, err
+	}
+
+	err = carFile.Close()
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	fileDesc := &model.FileDesc{
+		SourceFileName: filepath.Base(srcPath),
+		SourceFilePath: srcPath,
+		SourceFileSize: int64(sourceSize),
+		CarFileName:    filepath.Base(carFilePath),
+		CarFilePath:    carFilePath,
+		PayloadCid:     root.cid.String(),
+	}
+
+	err = checksumCarFile(fileDesc, config.CalcPieceCid)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	logs.Log().Info("car file generated:", carFilePath, ", payload cid:", fileDesc.PayloadCid, ", size:", fileDesc.CarFileSize)
+	return fileDesc, nil
+}
+
+// writeCar returns the root of the dag written and the bytes of the files in it
+func writeCar(carFile *os.File, srcPath string, srcFileInfo os.FileInfo, config Config) (*link, uint64, error) {
+	carWriter, err := newCarWriter(carFile, config.Version)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dagBuilder := newDagBuilder(carWriter, config.ChunkSize, config.LinksPerBlock)
+	root, err := dagBuilder.addPath(srcPath, srcFileInfo)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = carWriter.finish(root.cid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return root, dagBuilder.sourceSize, nil
+}
+
+// checksumCarFile sets the size and the md5 of the car file, and its piece cid when calcPieceCid is set
+func checksumCarFile(fileDesc *model.FileDesc, calcPieceCid bool) error {
+	carFile, err := os.Open(fileDesc.CarFilePath)
+	if err != nil {
+		return err
+	}
+	defer carFile.Close()
+
+	md5Hash := md5.New()
+	writers := []io.Writer{md5Hash}
+
+	var calculator *commp.Calculator
+	if calcPieceCid {
+		calculator = commp.NewCalculator()
+		writers = append(writers, calculator)
+	}
+
+	carFileSize, err := io.Copy(io.MultiWriter(writers...), carFile)
+	if err != nil {
+		return err
+	}
+
+	fileDesc.CarFileSize = carFileSize
+	fileDesc.CarFileMd5 = hex.EncodeToString(md5Hash.Sum(nil))
+
+	if calculator != nil {
+		commP, err := calculator.Sum()
+		if err != nil {
+			return err
+		}
+		fileDesc.PieceCid = commP.PieceCid
+	}
+
+	return nil
+}
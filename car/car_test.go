@@ -0,0 +1,355 @@
+package car
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-dagaggregator-unixfs/lib/rambs"
+	"github.com/ipfs/go-blockservice"
+	gocid "github.com/ipfs/go-cid"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	exchangeoffline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
+	"github.com/ipfs/go-unixfs/importer/balanced"
+	"github.com/ipfs/go-unixfs/importer/helpers"
+	unixfsio "github.com/ipfs/go-unixfs/io"
+	carv2 "github.com/ipld/go-car/v2"
+	carv2blockstore "github.com/ipld/go-car/v2/blockstore"
+	"github.com/ipld/go-car/v2/index"
+)
+
+var testConfig = Config{ChunkSize: 1024, LinksPerBlock: 4}
+
+// unixfsImporter adds files to an in-memory dag with go-unixfs, like lotus client import: the balanced layout,
+// raw leaves and CID v1
+type unixfsImporter struct {
+	ramBs   *rambs.RamBs
+	dagServ ipld.DAGService
+	config  Config
+}
+
+func newUnixfsImporter(config Config) *unixfsImporter {
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = CHUNK_SIZE_DEFAULT
+	}
+	if config.LinksPerBlock <= 1 {
+		config.LinksPerBlock = LINKS_PER_BLOCK_DEFAULT
+	}
+
+	ramBs := new(rambs.RamBs)
+	return &unixfsImporter{
+		ramBs:   ramBs,
+		dagServ: merkledag.NewDAGService(blockservice.New(ramBs, exchangeoffline.Exchange(ramBs))),
+		config:  config,
+	}
+}
+
+func (unixfsImporter *unixfsImporter) addPath(t *testing.T, path string) ipld.Node {
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch mode := fileInfo.Mode(); {
+	case mode.IsRegular():
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		dagBuilderParams := helpers.DagBuilderParams{
+			Maxlinks:   unixfsImporter.config.LinksPerBlock,
+			RawLeaves:  true,
+			CidBuilder: merkledag.V1CidPrefix(),
+			Dagserv:    unixfsImporter.dagServ,
+		}
+		dagBuilder, err := dagBuilderParams.New(chunker.NewSizeSplitter(file, int64(unixfsImporter.config.ChunkSize)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := balanced.Layout(dagBuilder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return node
+	case mode.IsDir():
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		directory := unixfsio.NewDirectory(unixfsImporter.dagServ)
+		directory.SetCidBuilder(merkledag.V1CidPrefix())
+		for _, entry := range entries {
+			err := directory.AddChild(context.Background(), entry.Name(), unixfsImporter.addPath(t, filepath.Join(path, entry.Name())))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		node, err := directory.GetNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		unixfsImporter.add(t, node)
+		return node
+	default:
+		target, err := os.Readlink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := unixfs.SymlinkData(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		node := merkledag.NodeWithData(data)
+		node.SetCidBuilder(merkledag.V1CidPrefix())
+		unixfsImporter.add(t, node)
+		return node
+	}
+}
+
+func (unixfsImporter *unixfsImporter) add(t *testing.T, node ipld.Node) {
+	err := unixfsImporter.dagServ.Add(context.Background(), node)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stat returns the number and the size of the blocks added
+func (unixfsImporter *unixfsImporter) stat(t *testing.T) (uint64, uint64) {
+	keys, err := unixfsImporter.ramBs.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	numBlocks, size := uint64(0), uint64(0)
+	for key := range keys {
+		blockSize, err := unixfsImporter.ramBs.GetSize(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		numBlocks++
+		size += uint64(blockSize)
+	}
+
+	return numBlocks, size
+}
+
+// checkMatchesUnixfs generates the car of srcPath and checks its root and its blocks are the ones of go-unixfs
+func checkMatchesUnixfs(t *testing.T, srcPath string, config Config) {
+	carFilePath := filepath.Join(t.TempDir(), "file.car")
+	fileDesc, err := GenerateCarFile(srcPath, carFilePath, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unixfsImporter := newUnixfsImporter(config)
+	root := unixfsImporter.addPath(t, srcPath)
+	if fileDesc.PayloadCid != root.Cid().String() {
+		t.Fatalf("root of %s: got %s, go-unixfs has %s", srcPath, fileDesc.PayloadCid, root.Cid().String())
+	}
+
+	carStat, err := ReadCarFileStat(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numBlocks, size := unixfsImporter.stat(t)
+	if carStat.NumBlocks != numBlocks || carStat.Size != size {
+		t.Fatalf("car of %s has %d blocks of %d bytes, go-unixfs has %d blocks of %d bytes", srcPath, carStat.NumBlocks, carStat.Size, numBlocks, size)
+	}
+}
+
+func TestGenerateCarFileMatchesUnixfs(t *testing.T) {
+	sizes := []int{10, 1000, 1024, 1025, 4 * 1024, 4*1024 + 1, 16*1024 + 1, 50000, 100000}
+	for _, size := range sizes {
+		dir := t.TempDir()
+		testFiles(t, dir, map[string]int{"file": size})
+
+		checkMatchesUnixfs(t, filepath.Join(dir, "file"), testConfig)
+		checkMatchesUnixfs(t, filepath.Join(dir, "file"), Config{})
+	}
+}
+
+func TestGenerateCarFileDirectoryMatchesUnixfs(t *testing.T) {
+	dir := t.TempDir()
+	testFiles(t, dir, map[string]int{
+		"dir/a":         100,
+		"dir/b/c":       5000,
+		"dir/b/d/empty": 0,
+		"dir/b/d/large": 20000,
+		"dir/f":         1024,
+	})
+	for link, target := range map[string]string{"dir/link": "a", "dir/b/d/up": "../c", "dir/b/dangling": "missing"} {
+		err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(link)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.Mkdir(filepath.Join(dir, "dir", "empty"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkMatchesUnixfs(t, filepath.Join(dir, "dir"), testConfig)
+}
+
+func TestReadCarFileRootsAndStat(t *testing.T) {
+	dir := t.TempDir()
+	testFiles(t, dir, map[string]int{"dir/a": 3000, "dir/b": 3000})
+
+	for _, version := range []int{CAR_VERSION_1, CAR_VERSION_2} {
+		config := testConfig
+		config.Version = version
+		carFilePath := filepath.Join(t.TempDir(), "dir.car")
+		fileDesc, err := GenerateCarFile(filepath.Join(dir, "dir"), carFilePath, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		roots, err := ReadCarFileRoots(carFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(roots) != 1 || roots[0].String() != fileDesc.PayloadCid {
+			t.Fatalf("car v%d roots: got %v, want %s", version, roots, fileDesc.PayloadCid)
+		}
+
+		carStat, err := ReadCarFileStat(carFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a and b have the same content, whose first 2 chunks are the same: 2 leaves, one file node and the directory
+		if len(carStat.Roots) != 1 || !carStat.Roots[0].Equals(roots[0]) || carStat.NumBlocks != 4 {
+			t.Fatalf("car v%d stat: %+v", version, carStat)
+		}
+	}
+}
+
+func TestReadCarFileStatVerifiesBlocks(t *testing.T) {
+	dir := t.TempDir()
+	testFiles(t, dir, map[string]int{"file": 3000})
+	carFilePath := filepath.Join(t.TempDir(), "file.car")
+	_, err := GenerateCarFile(filepath.Join(dir, "file"), carFilePath, testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carBytes, err := os.ReadFile(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last byte is in the data of the root block
+	carBytes[len(carBytes)-1] ^= 0xff
+	err = os.WriteFile(carFilePath, carBytes, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ReadCarFileStat(carFilePath)
+	if err == nil || !strings.Contains(err.Error(), "does not match its cid") {
+		t.Fatalf("got %v, want a block not matching its cid", err)
+	}
+
+	// the header is still readable
+	_, err = ReadCarFileRoots(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// go-car reads the car v2 written with its index, every block is found at the offset of its section
+func TestCarV2ReadByGoCar(t *testing.T) {
+	dir := t.TempDir()
+	testFiles(t, dir, map[string]int{"dir/a": 10000, "dir/b/c": 5000, "dir/d": 10})
+	carFilePath := filepath.Join(t.TempDir(), "dir.car")
+	config := testConfig
+	config.Version = CAR_VERSION_2
+	fileDesc, err := GenerateCarFile(filepath.Join(dir, "dir"), carFilePath, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := carv2.OpenReader(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	roots, err := reader.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || roots[0].String() != fileDesc.PayloadCid {
+		t.Fatalf("roots: got %v, want %s", roots, fileDesc.PayloadCid)
+	}
+
+	idx, err := index.ReadFrom(reader.IndexReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockReader, err := carv2.NewBlockReader(reader.DataReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	carBlockstore, err := carv2blockstore.OpenReadOnly(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer carBlockstore.Close()
+
+	dataReader := reader.DataReader()
+	numBlocks := uint64(0)
+	for {
+		block, err := blockReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		numBlocks++
+
+		offset, err := index.GetFirst(idx, block.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		section := make([]byte, binary.MaxVarintLen64+len(block.Cid().Bytes()))
+		_, err = dataReader.ReadAt(section, int64(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, n := binary.Uvarint(section)
+		sectionCid, err := gocid.Cast(section[n : n+len(block.Cid().Bytes())])
+		if err != nil || !sectionCid.Equals(block.Cid()) {
+			t.Fatalf("section at offset %d of block %s: %v, %v", offset, block.Cid(), sectionCid, err)
+		}
+
+		storedBlock, err := carBlockstore.Get(block.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(storedBlock.RawData(), block.RawData()) {
+			t.Fatalf("block %s read from the car blockstore does not match", block.Cid())
+		}
+	}
+
+	carStat, err := ReadCarFileStat(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numBlocks != carStat.NumBlocks {
+		t.Fatalf("go-car read %d blocks, want %d", numBlocks, carStat.NumBlocks)
+	}
+}
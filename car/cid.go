@@ -0,0 +1,199 @@
+package car
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	CID_VERSION_0      = 0
+	CID_VERSION_1      = 1
+	CODEC_RAW          = 0x55
+	CODEC_DAG_PB       = 0x70
+	MULTIHASH_IDENTITY = 0x00
+	MULTIHASH_SHA2_256 = 0x12
+	MULTIBASE_BASE32   = "b"
+	MULTIBASE_BASE58   = "z"
+
+	// a CID v0 is a base58 sha2-256 multihash without multibase prefix, Qm...
+	CID_V0_PREFIX = "Qm"
+
+	// length of the binary form of the cids written by this package
+	CID_LENGTH = 4 + sha256.Size
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Cid is the binary form of a CID, the cids written by this package are CID v1 with a sha2-256 multihash.
+// The binary form of a CID v0 is its multihash.
+type Cid []byte
+
+// ParseCid decodes a CID v0, Qm..., or a CID v1 in base32 or base58, bafy... or z...
+func ParseCid(cidStr string) (Cid, error) {
+	var cid Cid
+	var err error
+
+	switch {
+	case len(cidStr) == 46 && strings.HasPrefix(cidStr, CID_V0_PREFIX):
+		cid, err = decodeBase58(cidStr)
+	case strings.HasPrefix(cidStr, MULTIBASE_BASE32):
+		cid, err = base32Encoding.DecodeString(strings.ToUpper(cidStr[1:]))
+	case strings.HasPrefix(cidStr, MULTIBASE_BASE58):
+		cid, err = decodeBase58(cidStr[1:])
+	default:
+		err = fmt.Errorf("unsupported cid:%s", cidStr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, _, err = cid.parse()
+	if err != nil {
+		err = fmt.Errorf("invalid cid:%s, %s", cidStr, err.Error())
+		return nil, err
+	}
+
+	return cid, nil
+}
+
+// parse returns the version, the codec and the multihash of the cid
+func (cid Cid) parse() (uint64, uint64, []byte, error) {
+	if len(cid) == 2+sha256.Size && cid[0] == MULTIHASH_SHA2_256 && cid[1] == sha256.Size {
+		return CID_VERSION_0, CODEC_DAG_PB, cid, nil
+	}
+
+	version, n := binary.Uvarint(cid)
+	if n <= 0 || version != CID_VERSION_1 {
+		err := fmt.Errorf("unsupported cid version")
+		return 0, 0, nil, err
+	}
+
+	codec, m := binary.Uvarint(cid[n:])
+	if m <= 0 {
+		err := fmt.Errorf("invalid codec")
+		return 0, 0, nil, err
+	}
+
+	multihash := cid[n+m:]
+	_, hashCodeLen := binary.Uvarint(multihash)
+	if hashCodeLen <= 0 {
+		err := fmt.Errorf("invalid multihash")
+		return 0, 0, nil, err
+	}
+	digestLen, digestLenLen := binary.Uvarint(multihash[hashCodeLen:])
+	if digestLenLen <= 0 || uint64(len(multihash)-hashCodeLen-digestLenLen) != digestLen {
+		err := fmt.Errorf("invalid multihash length")
+		return 0, 0, nil, err
+	}
+
+	return version, codec, multihash, nil
+}
+
+// Codec returns the multicodec of the content of the cid, dag-pb for a CID v0 and 0 for an invalid cid
+func (cid Cid) Codec() uint64 {
+	_, codec, _, err := cid.parse()
+	if err != nil {
+		return 0
+	}
+
+	return codec
+}
+
+// Equals tells if both cids address the same block, a CID v0 equals the CID v1 dag-pb of its multihash
+func (cid Cid) Equals(other Cid) bool {
+	if bytes.Equal(cid, other) {
+		return true
+	}
+
+	_, codec, multihash, err := cid.parse()
+	if err != nil {
+		return false
+	}
+	_, otherCodec, otherMultihash, err := other.parse()
+	if err != nil {
+		return false
+	}
+
+	return codec == otherCodec && bytes.Equal(multihash, otherMultihash)
+}
+
+func newCid(codec uint64, data []byte) Cid {
+	digest := sha256.Sum256(data)
+
+	cid := make([]byte, 0, CID_LENGTH)
+	cid = binary.AppendUvarint(cid, CID_VERSION_1)
+	cid = binary.AppendUvarint(cid, codec)
+	cid = binary.AppendUvarint(cid, MULTIHASH_SHA2_256)
+	cid = binary.AppendUvarint(cid, sha256.Size)
+	cid = append(cid, digest[:]...)
+
+	return cid
+}
+
+// String encodes the cid in base32 like ipfs and lotus, bafy... for dag-pb and bafk... for raw blocks,
+// a CID v0 keeps its base58 form Qm...
+func (cid Cid) String() string {
+	version, _, _, err := cid.parse()
+	if err == nil && version == CID_VERSION_0 {
+		return encodeBase58(cid)
+	}
+
+	return MULTIBASE_BASE32 + strings.ToLower(base32Encoding.EncodeToString(cid))
+}
+
+func (cid Cid) digest() []byte {
+	return cid[len(cid)-sha256.Size:]
+}
+
+func encodeBase58(data []byte) string {
+	encoded := []byte{}
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(int64(len(base58Alphabet)))
+	mod := new(big.Int)
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+
+	// each leading zero byte is a leading 1
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+func decodeBase58(encoded string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(int64(len(base58Alphabet)))
+	zeros := 0
+	for i, c := range encoded {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			err := fmt.Errorf("invalid base58 character:%c", c)
+			return nil, err
+		}
+		if digit == 0 && zeros == i {
+			zeros++
+		}
+
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	return append(make([]byte, zeros), value.Bytes()...), nil
+}
@@ -0,0 +1,450 @@
+package car
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// a car v1 header beyond this is considered corrupted, like go-car does
+	CAR_V1_HEADER_SIZE_MAX = 32 << 20
+	// a section beyond this is considered corrupted, like go-car does
+	CAR_SECTION_SIZE_MAX = 8 << 20
+)
+
+// CarStat is what reading all the blocks of a car finds, each block is counted once like ipfs dag stat does
+type CarStat struct {
+	Roots     []Cid
+	NumBlocks uint64
+	Size      uint64 // bytes of block data, without the cids nor the section sizes
+}
+
+// ReadRoots reads the header of a car v1 or v2 and returns its roots
+func ReadRoots(reader io.Reader) ([]Cid, error) {
+	v1Reader, err := carV1Reader(bufio.NewReader(reader))
+	if err != nil {
+		return nil, err
+	}
+
+	return readCarV1Header(v1Reader)
+}
+
+// ReadCarFileRoots returns the roots in the header of a car file
+func ReadCarFileRoots(carFilePath string) ([]Cid, error) {
+	carFile, err := os.Open(carFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer carFile.Close()
+
+	return ReadRoots(carFile)
+}
+
+// ReadStat reads a whole car v1 or v2, checks each block hashes to its cid, and returns its roots with the number
+// and the size of its blocks. A car cut in the middle of a section fails with io.ErrUnexpectedEOF.
+func ReadStat(reader io.Reader) (*CarStat, error) {
+	v1Reader, err := carV1Reader(bufio.NewReader(reader))
+	if err != nil {
+		return nil, err
+	}
+
+	roots, err := readCarV1Header(v1Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	carStat := &CarStat{Roots: roots}
+	seen := map[string]bool{}
+	section := []byte{}
+	for {
+		sectionSize, err := binary.ReadUvarint(v1Reader)
+		if err == io.EOF {
+			return carStat, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sectionSize == 0 || sectionSize > CAR_SECTION_SIZE_MAX {
+			err := fmt.Errorf("invalid car section size:%d", sectionSize)
+			return nil, err
+		}
+
+		if uint64(cap(section)) < sectionSize {
+			section = make([]byte, sectionSize)
+		}
+		section = section[:sectionSize]
+		_, err = io.ReadFull(v1Reader, section)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cid, data, err := splitSection(section)
+		if err != nil {
+			return nil, err
+		}
+
+		err = verifyBlock(cid, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[string(cid)] {
+			seen[string(cid)] = true
+			carStat.NumBlocks++
+			carStat.Size += uint64(len(data))
+		}
+	}
+}
+
+// ReadCarFileStat reads a whole car file, see ReadStat
+func ReadCarFileStat(carFilePath string) (*CarStat, error) {
+	carFile, err := os.Open(carFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer carFile.Close()
+
+	return ReadStat(carFile)
+}
+
+// carV1Reader skips the headers of a car v2 and returns a reader of its car v1 data, a car v1 is read as is
+func carV1Reader(bufReader *bufio.Reader) (*bufio.Reader, error) {
+	pragma, err := bufReader.Peek(len(carV2Pragma))
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(pragma, carV2Pragma) {
+		return bufReader, nil
+	}
+
+	v2Header := make([]byte, len(carV2Pragma)+CAR_V2_HEADER_SIZE)
+	_, err = io.ReadFull(bufReader, v2Header)
+	if err != nil {
+		return nil, err
+	}
+
+	dataOffset := binary.LittleEndian.Uint64(v2Header[len(carV2Pragma)+16:])
+	dataSize := binary.LittleEndian.Uint64(v2Header[len(carV2Pragma)+24:])
+	if dataOffset < uint64(len(v2Header)) {
+		err := fmt.Errorf("invalid car v2 data offset:%d", dataOffset)
+		return nil, err
+	}
+
+	_, err = io.CopyN(io.Discard, bufReader, int64(dataOffset)-int64(len(v2Header)))
+	if err != nil {
+		return nil, err
+	}
+
+	// the index after the data is not part of the car v1
+	return bufio.NewReader(io.LimitReader(bufReader, int64(dataSize))), nil
+}
+
+func readCarV1Header(reader *bufio.Reader) ([]Cid, error) {
+	headerSize, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if headerSize == 0 || headerSize > CAR_V1_HEADER_SIZE_MAX {
+		err := fmt.Errorf("invalid car header size:%d", headerSize)
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeCarV1Header(header)
+}
+
+// splitSection splits a car section into the cid and the data of its block
+func splitSection(section []byte) (Cid, []byte, error) {
+	cidLength := 0
+	if len(section) >= 2+sha256.Size && section[0] == MULTIHASH_SHA2_256 && section[1] == sha256.Size {
+		cidLength = 2 + sha256.Size
+	} else {
+		// version, codec, multihash code and digest length, followed by the digest
+		for i := 0; i < 4; i++ {
+			value, n := binary.Uvarint(section[cidLength:])
+			if n <= 0 {
+				err := fmt.Errorf("invalid cid in car section")
+				return nil, nil, err
+			}
+			cidLength += n
+
+			if i == 3 {
+				if value > uint64(len(section)-cidLength) {
+					err := fmt.Errorf("invalid cid in car section")
+					return nil, nil, err
+				}
+				cidLength += int(value)
+			}
+		}
+	}
+
+	cid := Cid(section[:cidLength])
+	_, _, _, err := cid.parse()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cid, section[cidLength:], nil
+}
+
+// verifyBlock checks data hashes to cid, only the sha2-256 and identity multihashes are supported
+func verifyBlock(cid Cid, data []byte) error {
+	_, _, multihash, err := cid.parse()
+	if err != nil {
+		return err
+	}
+
+	hashCode, n := binary.Uvarint(multihash)
+	_, m := binary.Uvarint(multihash[n:])
+	digest := multihash[n+m:]
+
+	matches := false
+	switch hashCode {
+	case MULTIHASH_SHA2_256:
+		sum := sha256.Sum256(data)
+		matches = bytes.Equal(sum[:], digest)
+	case MULTIHASH_IDENTITY:
+		matches = bytes.Equal(data, digest)
+	default:
+		err := fmt.Errorf("unsupported multihash:%#x of block:%s", hashCode, cid.String())
+		return err
+	}
+
+	if !matches {
+		err := fmt.Errorf("data of block:%s does not match its cid", cid.String())
+		return err
+	}
+
+	return nil
+}
+
+// decodeCarV1Header decodes the dag-cbor header {"roots": [...], "version": 1}
+func decodeCarV1Header(header []byte) ([]Cid, error) {
+	decoder := &cborDecoder{data: header}
+
+	majorType, pairs, err := decoder.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if majorType != cborMajorMap {
+		err := fmt.Errorf("car header is not a map")
+		return nil, err
+	}
+
+	var roots []Cid
+	version := uint64(0)
+	for i := uint64(0); i < pairs; i++ {
+		key, err := decoder.readText()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "roots":
+			roots, err = decoder.readCids()
+		case "version":
+			version, err = decoder.readUint()
+		default:
+			err = decoder.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if version != CAR_VERSION_1 {
+		err := fmt.Errorf("unsupported car version:%d", version)
+		return nil, err
+	}
+
+	if len(roots) == 0 {
+		err := fmt.Errorf("no root in car header")
+		return nil, err
+	}
+
+	return roots, nil
+}
+
+// cborDecoder decodes the subset of dag-cbor found in car headers
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (decoder *cborDecoder) readHead() (byte, uint64, error) {
+	if decoder.pos >= len(decoder.data) {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+
+	initial := decoder.data[decoder.pos]
+	decoder.pos++
+
+	majorType := initial >> 5
+	info := initial & 0x1f
+	if info < 24 {
+		return majorType, uint64(info), nil
+	}
+
+	size := 0
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		err := fmt.Errorf("unsupported cbor additional information:%d", info)
+		return 0, 0, err
+	}
+
+	if decoder.pos+size > len(decoder.data) {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+
+	value := uint64(0)
+	for _, b := range decoder.data[decoder.pos : decoder.pos+size] {
+		value = value<<8 | uint64(b)
+	}
+	decoder.pos += size
+
+	return majorType, value, nil
+}
+
+func (decoder *cborDecoder) readBytes(length uint64) ([]byte, error) {
+	if length > uint64(len(decoder.data)-decoder.pos) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := decoder.data[decoder.pos : decoder.pos+int(length)]
+	decoder.pos += int(length)
+	return b, nil
+}
+
+func (decoder *cborDecoder) readText() (string, error) {
+	majorType, length, err := decoder.readHead()
+	if err != nil {
+		return "", err
+	}
+	if majorType != cborMajorText {
+		err := fmt.Errorf("unexpected cbor major type:%d, expecting text", majorType)
+		return "", err
+	}
+
+	text, err := decoder.readBytes(length)
+	return string(text), err
+}
+
+func (decoder *cborDecoder) readUint() (uint64, error) {
+	majorType, value, err := decoder.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if majorType != cborMajorUint {
+		err := fmt.Errorf("unexpected cbor major type:%d, expecting unsigned integer", majorType)
+		return 0, err
+	}
+
+	return value, nil
+}
+
+func (decoder *cborDecoder) readCids() ([]Cid, error) {
+	majorType, count, err := decoder.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if majorType != cborMajorArray {
+		err := fmt.Errorf("unexpected cbor major type:%d, expecting array", majorType)
+		return nil, err
+	}
+
+	cids := []Cid{}
+	for i := uint64(0); i < count; i++ {
+		majorType, tag, err := decoder.readHead()
+		if err != nil {
+			return nil, err
+		}
+		if majorType != cborMajorTag || tag != cborTagCid {
+			err := fmt.Errorf("unexpected cbor item, expecting a cid")
+			return nil, err
+		}
+
+		majorType, length, err := decoder.readHead()
+		if err != nil {
+			return nil, err
+		}
+		if majorType != cborMajorBytes {
+			err := fmt.Errorf("unexpected cbor major type:%d, expecting bytes", majorType)
+			return nil, err
+		}
+
+		b, err := decoder.readBytes(length)
+		if err != nil {
+			return nil, err
+		}
+
+		// the identity multibase prefix
+		if len(b) < 2 || b[0] != 0x00 {
+			err := fmt.Errorf("invalid cid in car header")
+			return nil, err
+		}
+
+		cid := Cid(append([]byte{}, b[1:]...))
+		_, _, _, err = cid.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		cids = append(cids, cid)
+	}
+
+	return cids, nil
+}
+
+// skip skips the next item, like the values of unknown keys
+func (decoder *cborDecoder) skip() error {
+	majorType, value, err := decoder.readHead()
+	if err != nil {
+		return err
+	}
+
+	switch majorType {
+	case cborMajorBytes, cborMajorText:
+		_, err = decoder.readBytes(value)
+		return err
+	case cborMajorArray:
+		for i := uint64(0); i < value; i++ {
+			err = decoder.skip()
+			if err != nil {
+				return err
+			}
+		}
+	case cborMajorMap:
+		for i := uint64(0); i < 2*value; i++ {
+			err = decoder.skip()
+			if err != nil {
+				return err
+			}
+		}
+	case cborMajorTag:
+		return decoder.skip()
+	}
+
+	return nil
+}
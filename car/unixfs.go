@@ -0,0 +1,279 @@
+package car
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	UNIXFS_DIRECTORY = 1
+	UNIXFS_FILE      = 2
+	UNIXFS_SYMLINK   = 4
+
+	// protobuf wire types
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// link is a child of a dag-pb node
+type link struct {
+	cid      Cid
+	name     string
+	tsize    uint64 // bytes of all the blocks of the child dag
+	fileSize uint64 // bytes of file data in the child dag
+}
+
+type blockWriter interface {
+	putBlock(cid Cid, data []byte) error
+}
+
+// dagBuilder chunks files into a unixfs dag with raw leaves and the balanced layout, like lotus client import
+type dagBuilder struct {
+	blockWriter   blockWriter
+	chunkSize     int
+	linksPerBlock int
+	buffer        []byte
+	sourceSize    uint64 // bytes of the files added so far
+}
+
+func newDagBuilder(blockWriter blockWriter, chunkSize, linksPerBlock int) *dagBuilder {
+	dagBuilder := &dagBuilder{
+		blockWriter:   blockWriter,
+		chunkSize:     chunkSize,
+		linksPerBlock: linksPerBlock,
+		buffer:        make([]byte, chunkSize),
+	}
+
+	return dagBuilder
+}
+
+// addPath adds a file, a symlink or a directory and everything below it
+func (dagBuilder *dagBuilder) addPath(path string, fileInfo os.FileInfo) (*link, error) {
+	switch mode := fileInfo.Mode(); {
+	case mode.IsRegular():
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return dagBuilder.addFile(file)
+	case mode.IsDir():
+		return dagBuilder.addDirectory(path)
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return dagBuilder.addNode(nil, encodeUnixfsData(UNIXFS_SYMLINK, []byte(target), nil, nil), 0)
+	default:
+		err := fmt.Errorf("unsupported file type:%s, path:%s", mode.Type().String(), path)
+		return nil, err
+	}
+}
+
+// addDirectory adds a basic unixfs directory, very large directories are not sharded like ipfs does
+func (dagBuilder *dagBuilder) addDirectory(path string) (*link, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	links := []*link{}
+	for _, entry := range entries {
+		entryPath := filepath.Join(path, entry.Name())
+		fileInfo, err := os.Lstat(entryPath)
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := dagBuilder.addPath(entryPath, fileInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		child.name = entry.Name()
+		links = append(links, child)
+	}
+
+	// dag-pb requires the links sorted by name
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].name < links[j].name
+	})
+
+	return dagBuilder.addNode(links, encodeUnixfsData(UNIXFS_DIRECTORY, nil, nil, nil), 0)
+}
+
+// addFile chunks reader into raw leaves and builds the smallest balanced tree over them,
+// a file of a single chunk is just that leaf
+func (dagBuilder *dagBuilder) addFile(reader io.Reader) (*link, error) {
+	// levels[i] are the links waiting for a parent at height i+1
+	levels := [][]*link{}
+	chunks := 0
+
+	for {
+		n, readErr := io.ReadFull(reader, dagBuilder.buffer)
+		if n > 0 || chunks == 0 {
+			leaf, err := dagBuilder.addLeaf(dagBuilder.buffer[:n])
+			if err != nil {
+				return nil, err
+			}
+			chunks++
+
+			levels, err = dagBuilder.addLink(levels, 0, leaf)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+
+	for level := 0; level < len(levels); level++ {
+		if len(levels[level]) == 0 {
+			continue
+		}
+
+		if level == len(levels)-1 && len(levels[level]) == 1 {
+			return levels[level][0], nil
+		}
+
+		node, err := dagBuilder.addFileNode(levels[level])
+		if err != nil {
+			return nil, err
+		}
+
+		levels[level] = nil
+		levels, err = dagBuilder.addLink(levels, level+1, node)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := fmt.Errorf("no root built for %d chunks", chunks)
+	return nil, err
+}
+
+// addLink appends link at level, a full level gets its parent right away
+func (dagBuilder *dagBuilder) addLink(levels [][]*link, level int, child *link) ([][]*link, error) {
+	for len(levels) <= level {
+		levels = append(levels, nil)
+	}
+
+	levels[level] = append(levels[level], child)
+	if len(levels[level]) < dagBuilder.linksPerBlock {
+		return levels, nil
+	}
+
+	node, err := dagBuilder.addFileNode(levels[level])
+	if err != nil {
+		return nil, err
+	}
+
+	levels[level] = nil
+	return dagBuilder.addLink(levels, level+1, node)
+}
+
+func (dagBuilder *dagBuilder) addLeaf(data []byte) (*link, error) {
+	cid := newCid(CODEC_RAW, data)
+	err := dagBuilder.blockWriter.putBlock(cid, data)
+	if err != nil {
+		return nil, err
+	}
+
+	dagBuilder.sourceSize += uint64(len(data))
+
+	leaf := &link{
+		cid:      cid,
+		tsize:    uint64(len(data)),
+		fileSize: uint64(len(data)),
+	}
+
+	return leaf, nil
+}
+
+func (dagBuilder *dagBuilder) addFileNode(links []*link) (*link, error) {
+	fileSize := uint64(0)
+	blockSizes := make([]uint64, 0, len(links))
+	for _, child := range links {
+		fileSize += child.fileSize
+		blockSizes = append(blockSizes, child.fileSize)
+	}
+
+	return dagBuilder.addNode(links, encodeUnixfsData(UNIXFS_FILE, nil, &fileSize, blockSizes), fileSize)
+}
+
+func (dagBuilder *dagBuilder) addNode(links []*link, unixfsData []byte, fileSize uint64) (*link, error) {
+	data := encodeDagPbNode(links, unixfsData)
+	cid := newCid(CODEC_DAG_PB, data)
+	err := dagBuilder.blockWriter.putBlock(cid, data)
+	if err != nil {
+		return nil, err
+	}
+
+	tsize := uint64(len(data))
+	for _, child := range links {
+		tsize += child.tsize
+	}
+
+	node := &link{
+		cid:      cid,
+		tsize:    tsize,
+		fileSize: fileSize,
+	}
+
+	return node, nil
+}
+
+// encodeDagPbNode encodes a PBNode, the links come before the data as dag-pb requires
+func encodeDagPbNode(links []*link, data []byte) []byte {
+	node := []byte{}
+	for _, child := range links {
+		pbLink := []byte{}
+		pbLink = appendBytesField(pbLink, 1, child.cid)
+		pbLink = appendBytesField(pbLink, 2, []byte(child.name))
+		pbLink = appendVarintField(pbLink, 3, child.tsize)
+
+		node = appendBytesField(node, 2, pbLink)
+	}
+
+	node = appendBytesField(node, 1, data)
+	return node
+}
+
+// encodeUnixfsData encodes the unixfs Data message, nil fields are left out
+func encodeUnixfsData(dataType uint64, data []byte, fileSize *uint64, blockSizes []uint64) []byte {
+	unixfsData := appendVarintField(nil, 1, dataType)
+	if data != nil {
+		unixfsData = appendBytesField(unixfsData, 2, data)
+	}
+	if fileSize != nil {
+		unixfsData = appendVarintField(unixfsData, 3, *fileSize)
+	}
+	for _, blockSize := range blockSizes {
+		unixfsData = appendVarintField(unixfsData, 4, blockSize)
+	}
+
+	return unixfsData
+}
+
+func appendVarintField(b []byte, field int, value uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|wireVarint))
+	return binary.AppendUvarint(b, value)
+}
+
+func appendBytesField(b []byte, field int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|wireBytes))
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
@@ -0,0 +1,198 @@
+package car
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const (
+	CAR_VERSION_1 = 1
+	CAR_VERSION_2 = 2
+
+	CAR_V2_HEADER_SIZE = 40
+
+	// multicodec of the index written after the data of a car v2
+	INDEX_SORTED = 0x0400
+
+	cborMajorUint  = 0
+	cborMajorBytes = 2
+	cborMajorText  = 3
+	cborMajorArray = 4
+	cborMajorMap   = 5
+	cborMajorTag   = 6
+	cborTagCid     = 42
+)
+
+// carV2Pragma is the fixed start of a car v2, a car v1 header reading {"version": 2}
+var carV2Pragma = []byte{0x0a, 0xa1, 0x67, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x02}
+
+type indexRecord struct {
+	digest []byte
+	offset uint64
+}
+
+// carWriter writes blocks to a car file as they come, leaving room for the headers which are
+// written by finish once the root is known, their size only depends on the length of the root cid
+type carWriter struct {
+	file      *os.File
+	writer    *bufio.Writer
+	version   int
+	dataStart int64  // offset of the car v1 data in the file
+	dataSize  uint64 // bytes of car v1 data written so far
+	written   map[string]bool
+	index     []indexRecord
+}
+
+func newCarWriter(file *os.File, version int) (*carWriter, error) {
+	if version != CAR_VERSION_1 && version != CAR_VERSION_2 {
+		err := fmt.Errorf("unsupported car version:%d", version)
+		return nil, err
+	}
+
+	carWriter := &carWriter{
+		file:    file,
+		writer:  bufio.NewWriterSize(file, 1024*1024),
+		version: version,
+		written: map[string]bool{},
+	}
+
+	if version == CAR_VERSION_2 {
+		carWriter.dataStart = int64(len(carV2Pragma) + CAR_V2_HEADER_SIZE)
+	}
+
+	placeholder := make([]byte, carWriter.dataStart+int64(len(encodeCarV1Header(make(Cid, CID_LENGTH)))))
+	_, err := carWriter.writer.Write(placeholder)
+	if err != nil {
+		return nil, err
+	}
+
+	carWriter.dataSize = uint64(len(placeholder)) - uint64(carWriter.dataStart)
+	return carWriter, nil
+}
+
+// putBlock writes a section of the car, blocks already written are skipped
+func (carWriter *carWriter) putBlock(cid Cid, data []byte) error {
+	if carWriter.written[string(cid)] {
+		return nil
+	}
+
+	section := binary.AppendUvarint(nil, uint64(len(cid)+len(data)))
+	section = append(section, cid...)
+
+	if carWriter.version == CAR_VERSION_2 {
+		carWriter.index = append(carWriter.index, indexRecord{digest: cid.digest(), offset: carWriter.dataSize})
+	}
+
+	for _, b := range [][]byte{section, data} {
+		_, err := carWriter.writer.Write(b)
+		if err != nil {
+			return err
+		}
+		carWriter.dataSize += uint64(len(b))
+	}
+
+	carWriter.written[string(cid)] = true
+	return nil
+}
+
+// finish writes the index of a car v2 and the headers, it does not close the file
+func (carWriter *carWriter) finish(root Cid) error {
+	if len(root) != CID_LENGTH {
+		err := fmt.Errorf("unexpected root cid length:%d", len(root))
+		return err
+	}
+
+	if carWriter.version == CAR_VERSION_2 {
+		_, err := carWriter.writer.Write(carWriter.encodeIndex())
+		if err != nil {
+			return err
+		}
+	}
+
+	err := carWriter.writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	header := encodeCarV1Header(root)
+	if carWriter.version == CAR_VERSION_2 {
+		dataOffset := uint64(carWriter.dataStart)
+		v2Header := make([]byte, CAR_V2_HEADER_SIZE)
+		// the 16 bytes of characteristics are left zero
+		binary.LittleEndian.PutUint64(v2Header[16:], dataOffset)
+		binary.LittleEndian.PutUint64(v2Header[24:], carWriter.dataSize)
+		binary.LittleEndian.PutUint64(v2Header[32:], dataOffset+carWriter.dataSize)
+
+		header = append(append(append([]byte{}, carV2Pragma...), v2Header...), header...)
+	}
+
+	_, err = carWriter.file.WriteAt(header, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = carWriter.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// encodeIndex encodes a sorted index: the digests of the blocks with the offset of their section in the car v1 data
+func (carWriter *carWriter) encodeIndex() []byte {
+	sort.Slice(carWriter.index, func(i, j int) bool {
+		return bytes.Compare(carWriter.index[i].digest, carWriter.index[j].digest) < 0
+	})
+
+	width := uint32(len(newCid(CODEC_RAW, nil).digest()) + 8)
+
+	index := binary.AppendUvarint(nil, INDEX_SORTED)
+	index = binary.LittleEndian.AppendUint32(index, 1) // buckets, all the digests have the same width
+	index = binary.LittleEndian.AppendUint32(index, width)
+	index = binary.LittleEndian.AppendUint64(index, uint64(width)*uint64(len(carWriter.index)))
+	for _, record := range carWriter.index {
+		index = append(index, record.digest...)
+		index = binary.LittleEndian.AppendUint64(index, record.offset)
+	}
+
+	return index
+}
+
+// encodeCarV1Header encodes the dag-cbor header {"roots": [root], "version": 1} prefixed by its length
+func encodeCarV1Header(root Cid) []byte {
+	header := appendCborHead(nil, cborMajorMap, 2)
+
+	header = appendCborHead(header, cborMajorText, uint64(len("roots")))
+	header = append(header, "roots"...)
+	header = appendCborHead(header, cborMajorArray, 1)
+	header = appendCborHead(header, cborMajorTag, cborTagCid)
+	// a cid in dag-cbor is the binary cid prefixed by the identity multibase
+	header = appendCborHead(header, cborMajorBytes, uint64(len(root)+1))
+	header = append(header, 0x00)
+	header = append(header, root...)
+
+	header = appendCborHead(header, cborMajorText, uint64(len("version")))
+	header = append(header, "version"...)
+	header = appendCborHead(header, cborMajorUint, 1)
+
+	return append(binary.AppendUvarint(nil, uint64(len(header))), header...)
+}
+
+func appendCborHead(b []byte, majorType byte, value uint64) []byte {
+	major := majorType << 5
+
+	switch {
+	case value < 24:
+		return append(b, major|byte(value))
+	case value <= 0xff:
+		return append(b, major|24, byte(value))
+	case value <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(value))
+	case value <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(value))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), value)
+	}
+}
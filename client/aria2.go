@@ -1,9 +1,11 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/filswan/go-swan-lib/client/web"
@@ -30,10 +32,12 @@ type Aria2Payload struct {
 }
 
 type Aria2Client struct {
-	Host      string
-	port      int
-	token     string
-	serverUrl string
+	Host        string
+	port        int
+	token       string
+	serverUrl   string
+	HttpClient  *web.Client      // nil means web.DefaultClient()
+	RetryPolicy *web.RetryPolicy // nil means the retry policy of HttpClient
 }
 
 type Aria2DownloadOption struct {
@@ -62,6 +66,24 @@ type Aria2Error struct {
 	Message string `json:"message"`
 }
 
+func (aria2Error *Aria2Error) Error() string {
+	return aria2Error.Message
+}
+
+// Is matches web.ErrUnauthorized for a wrong secret and web.ErrNotFound for an unknown gid
+func (aria2Error *Aria2Error) Is(target error) bool {
+	message := strings.ToLower(aria2Error.Message)
+
+	switch target {
+	case web.ErrUnauthorized:
+		return strings.Contains(message, "unauthorized")
+	case web.ErrNotFound:
+		return strings.Contains(message, "not found")
+	}
+
+	return false
+}
+
 type Aria2StatusResult struct {
 	Bitfield        string                  `json:"bitfield"`
 	CompletedLength string                  `json:"completedLength"`
@@ -117,6 +139,64 @@ func GetAria2Client(aria2Host, aria2Secret string, aria2Port int) *Aria2Client {
 	return aria2cClient
 }
 
+// aria2.addUri is never retried since a lost response may still have started a download.
+// aria2 answers errors with http 400, they are returned as *Aria2Error.
+func (aria2Client *Aria2Client) post(ctx context.Context, payload Aria2Payload) ([]byte, error) {
+	httpClient := aria2Client.HttpClient
+	if httpClient == nil {
+		httpClient = web.DefaultClient()
+	}
+
+	if aria2Client.RetryPolicy != nil {
+		httpClient = httpClient.WithRetryPolicy(aria2Client.RetryPolicy)
+	}
+
+	if payload.Method != ADD_URI {
+		ctx = web.WithIdempotent(ctx)
+	}
+
+	response, err := httpClient.Request(ctx, http.MethodPost, aria2Client.serverUrl, "", payload)
+	if err != nil {
+		var statusErr *web.HTTPStatusError
+		if errors.As(err, &statusErr) {
+			aria2Resp := &Aria2Resp{}
+			if json.Unmarshal(statusErr.Body, aria2Resp) == nil && aria2Resp.Error != nil {
+				return nil, aria2Resp.Error
+			}
+		}
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// call posts payload and decodes the result of aria2 into result, errors of aria2 are returned as *Aria2Error
+func (aria2Client *Aria2Client) call(ctx context.Context, payload Aria2Payload, result interface{}) error {
+	response, err := aria2Client.post(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	aria2Resp := &struct {
+		Error  *Aria2Error     `json:"error"`
+		Result json.RawMessage `json:"result"`
+	}{}
+	err = json.Unmarshal(response, aria2Resp)
+	if err != nil {
+		return err
+	}
+
+	if aria2Resp.Error != nil {
+		return aria2Resp.Error
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(aria2Resp.Result, result)
+}
+
 func (aria2Client *Aria2Client) GenPayload4Download(method string, uri string, outDir, outFilename string) Aria2Payload {
 	options := Aria2DownloadOption{
 		Out: outFilename,
@@ -143,25 +223,48 @@ func (aria2Client *Aria2Client) GenPayload4Download(method string, uri string, o
 	return payload
 }
 
+// DownloadFile returns nil on failure, AddDownload returns the error
 func (aria2Client *Aria2Client) DownloadFile(uri string, outDir, outFilename string) *Aria2Download {
+	return aria2Client.DownloadFileWithContext(context.Background(), uri, outDir, outFilename)
+}
+
+func (aria2Client *Aria2Client) DownloadFileWithContext(ctx context.Context, uri string, outDir, outFilename string) *Aria2Download {
 	payload := aria2Client.GenPayload4Download(ADD_URI, uri, outDir, outFilename)
 
-	response, err := web.HttpPostNoToken(aria2Client.serverUrl, payload)
+	response, err := aria2Client.post(ctx, payload)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil
 	}
 
 	aria2Download := &Aria2Download{}
 	err = json.Unmarshal(response, aria2Download)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil
 	}
 
 	return aria2Download
 }
 
+// AddDownload starts the download of uri to outDir/outFilename and returns its gid
+func (aria2Client *Aria2Client) AddDownload(uri string, outDir, outFilename string) (string, error) {
+	return aria2Client.AddDownloadWithContext(context.Background(), uri, outDir, outFilename)
+}
+
+func (aria2Client *Aria2Client) AddDownloadWithContext(ctx context.Context, uri string, outDir, outFilename string) (string, error) {
+	payload := aria2Client.GenPayload4Download(ADD_URI, uri, outDir, outFilename)
+
+	var gid string
+	err := aria2Client.call(ctx, payload, &gid)
+	if err != nil {
+		logs.Log().Error(err)
+		return "", err
+	}
+
+	return gid, nil
+}
+
 func (aria2Client *Aria2Client) GenPayload4Status(gid string) Aria2Payload {
 	var params []interface{}
 	params = append(params, "token:"+aria2Client.token)
@@ -176,25 +279,88 @@ func (aria2Client *Aria2Client) GenPayload4Status(gid string) Aria2Payload {
 	return payload
 }
 
+// GetDownloadStatus returns nil on failure, DownloadStatus returns the error
 func (aria2Client *Aria2Client) GetDownloadStatus(gid string) *Aria2Status {
+	return aria2Client.GetDownloadStatusWithContext(context.Background(), gid)
+}
+
+func (aria2Client *Aria2Client) GetDownloadStatusWithContext(ctx context.Context, gid string) *Aria2Status {
 	payload := aria2Client.GenPayload4Status(gid)
-	response, err := web.HttpPostNoToken(aria2Client.serverUrl, payload)
+	response, err := aria2Client.post(ctx, payload)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil
 	}
 
 	aria2Status := &Aria2Status{}
 	err = json.Unmarshal(response, aria2Status)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil
 	}
 
 	return aria2Status
 }
 
+// DownloadStatus returns the status of the download of gid, an unknown gid matches web.ErrNotFound
+func (aria2Client *Aria2Client) DownloadStatus(gid string) (*Aria2StatusResult, error) {
+	return aria2Client.DownloadStatusWithContext(context.Background(), gid)
+}
+
+func (aria2Client *Aria2Client) DownloadStatusWithContext(ctx context.Context, gid string) (*Aria2StatusResult, error) {
+	payload := aria2Client.GenPayload4Status(gid)
+
+	aria2StatusResult := &Aria2StatusResult{}
+	err := aria2Client.call(ctx, payload, aria2StatusResult)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return aria2StatusResult, nil
+}
+
+// ChangeMaxConcurrentDownloads returns nil on failure, SetMaxConcurrentDownloads returns the error
 func (aria2Client *Aria2Client) ChangeMaxConcurrentDownloads(maxConcurrentDownloads string) *Aria2ChangeMaxConcurrentDownloads {
+	return aria2Client.ChangeMaxConcurrentDownloadsWithContext(context.Background(), maxConcurrentDownloads)
+}
+
+func (aria2Client *Aria2Client) ChangeMaxConcurrentDownloadsWithContext(ctx context.Context, maxConcurrentDownloads string) *Aria2ChangeMaxConcurrentDownloads {
+	payload := aria2Client.genPayload4MaxConcurrentDownloads(maxConcurrentDownloads)
+
+	response, err := aria2Client.post(ctx, payload)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil
+	}
+
+	aria2ChangeMaxConcurrentDownloads := &Aria2ChangeMaxConcurrentDownloads{}
+	err = json.Unmarshal(response, aria2ChangeMaxConcurrentDownloads)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil
+	}
+	return aria2ChangeMaxConcurrentDownloads
+}
+
+// SetMaxConcurrentDownloads changes the number of downloads aria2 runs at once
+func (aria2Client *Aria2Client) SetMaxConcurrentDownloads(maxConcurrentDownloads string) error {
+	return aria2Client.SetMaxConcurrentDownloadsWithContext(context.Background(), maxConcurrentDownloads)
+}
+
+func (aria2Client *Aria2Client) SetMaxConcurrentDownloadsWithContext(ctx context.Context, maxConcurrentDownloads string) error {
+	payload := aria2Client.genPayload4MaxConcurrentDownloads(maxConcurrentDownloads)
+
+	err := aria2Client.call(ctx, payload, nil)
+	if err != nil {
+		logs.Log().Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (aria2Client *Aria2Client) genPayload4MaxConcurrentDownloads(maxConcurrentDownloads string) Aria2Payload {
 	var params []interface{}
 	params = append(params, "token:"+aria2Client.token)
 	params = append(params, &ChangeMaxConcurrentDownloads{
@@ -207,22 +373,14 @@ func (aria2Client *Aria2Client) ChangeMaxConcurrentDownloads(maxConcurrentDownlo
 		Params:  params,
 	}
 
-	response, err := web.HttpPostNoToken(aria2Client.serverUrl, payload)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil
-	}
-
-	aria2ChangeMaxConcurrentDownloads := &Aria2ChangeMaxConcurrentDownloads{}
-	err = json.Unmarshal(response, aria2ChangeMaxConcurrentDownloads)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil
-	}
-	return aria2ChangeMaxConcurrentDownloads
+	return payload
 }
 
 func (aria2Client *Aria2Client) RemoveDownload(gid string) (err error) {
+	return aria2Client.RemoveDownloadWithContext(context.Background(), gid)
+}
+
+func (aria2Client *Aria2Client) RemoveDownloadWithContext(ctx context.Context, gid string) (err error) {
 	if gid == "" {
 		return errors.New("invalid empty gid")
 	}
@@ -235,9 +393,9 @@ func (aria2Client *Aria2Client) RemoveDownload(gid string) (err error) {
 		},
 	}
 
-	body, err := web.HttpPostNoToken(aria2Client.serverUrl, payload)
+	body, err := aria2Client.post(ctx, payload)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return
 	}
 	var resp Aria2Resp
@@ -245,7 +403,7 @@ func (aria2Client *Aria2Client) RemoveDownload(gid string) (err error) {
 		return
 	}
 	if resp.Error != nil {
-		return errors.New(resp.Error.Message)
+		return resp.Error
 	}
 	return
 }
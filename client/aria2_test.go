@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/filswan/go-swan-lib/client/aria2test"
+	"github.com/filswan/go-swan-lib/client/web"
+)
+
+var testAria2Content = bytes.Repeat([]byte("aria2"), 64*1024)
+
+// newTestFileServer serves testAria2Content at /file, other paths are not found
+func newTestFileServer(t *testing.T) *httptest.Server {
+	fileServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(testAria2Content)))
+		w.Write(testAria2Content)
+	}))
+	t.Cleanup(fileServer.Close)
+
+	return fileServer
+}
+
+func newTestAria2Client(server *aria2test.Server, secret string) *Aria2Client {
+	aria2Client := GetAria2Client(server.Host(), secret, server.Port())
+	aria2Client.RetryPolicy = web.DefaultRetryPolicy()
+	aria2Client.RetryPolicy.InitialBackoff = time.Millisecond
+	aria2Client.RetryPolicy.MaxBackoff = time.Millisecond
+
+	return aria2Client
+}
+
+// waitDownload polls the status of gid until the download is no longer waiting or active
+func waitDownload(t *testing.T, aria2Client *Aria2Client, gid string) *Aria2StatusResult {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		aria2StatusResult, err := aria2Client.DownloadStatus(gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if aria2StatusResult.Status != aria2test.STATUS_WAITING && aria2StatusResult.Status != aria2test.STATUS_ACTIVE {
+			return aria2StatusResult
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("download %s not finished", gid)
+	return nil
+}
+
+func TestAria2Download(t *testing.T) {
+	server := aria2test.NewServer("secret")
+	defer server.Close()
+	fileServer := newTestFileServer(t)
+	aria2Client := newTestAria2Client(server, "secret")
+	outDir := t.TempDir()
+
+	gid, err := aria2Client.AddDownload(fileServer.URL+"/file", outDir, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aria2StatusResult := waitDownload(t, aria2Client, gid)
+	if aria2StatusResult.Status != aria2test.STATUS_COMPLETE || aria2StatusResult.CompletedLength != strconv.Itoa(len(testAria2Content)) {
+		t.Fatalf("status: %+v", aria2StatusResult)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outDir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, testAria2Content) {
+		t.Fatal("downloaded content differs")
+	}
+
+	// the legacy calls see the same download
+	aria2Status := aria2Client.GetDownloadStatus(gid)
+	if aria2Status == nil || aria2Status.Result == nil || aria2Status.Result.Status != aria2test.STATUS_COMPLETE {
+		t.Fatalf("legacy status: %+v", aria2Status)
+	}
+}
+
+func TestAria2DownloadNotFound(t *testing.T) {
+	server := aria2test.NewServer("secret")
+	defer server.Close()
+	fileServer := newTestFileServer(t)
+	aria2Client := newTestAria2Client(server, "secret")
+
+	gid, err := aria2Client.AddDownload(fileServer.URL+"/missing", t.TempDir(), "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aria2StatusResult := waitDownload(t, aria2Client, gid)
+	if aria2StatusResult.Status != aria2test.STATUS_ERROR || aria2StatusResult.ErrorCode != aria2test.ERROR_CODE_RESOURCE_NOT_FOUND {
+		t.Fatalf("status: %+v", aria2StatusResult)
+	}
+}
+
+func TestAria2RemoveActiveDownload(t *testing.T) {
+	server := aria2test.NewServer("secret")
+	defer server.Close()
+	server.DownloadRate = 1024
+	fileServer := newTestFileServer(t)
+	aria2Client := newTestAria2Client(server, "secret")
+
+	gid, err := aria2Client.AddDownload(fileServer.URL+"/file", t.TempDir(), "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = aria2Client.RemoveDownload(gid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aria2StatusResult := waitDownload(t, aria2Client, gid)
+	if aria2StatusResult.Status != aria2test.STATUS_REMOVED {
+		t.Fatalf("status: %+v", aria2StatusResult)
+	}
+}
+
+func TestAria2RetriesStatusButNotAddUri(t *testing.T) {
+	server := aria2test.NewServer("secret")
+	defer server.Close()
+	fileServer := newTestFileServer(t)
+	aria2Client := newTestAria2Client(server, "secret")
+
+	server.InjectFault(ADD_URI, aria2test.Fault{Drop: true, Times: 1})
+	_, err := aria2Client.AddDownload(fileServer.URL+"/file", t.TempDir(), "file")
+	if err == nil {
+		t.Fatal("dropped addUri reported success")
+	}
+	if calls := server.Calls(ADD_URI); calls != 1 {
+		t.Fatalf("addUri calls: got %d, want 1", calls)
+	}
+
+	gid, err := aria2Client.AddDownload(fileServer.URL+"/file", t.TempDir(), "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.InjectFault(STATUS, aria2test.Fault{HttpStatus: http.StatusServiceUnavailable, Times: 2})
+	_, err = aria2Client.DownloadStatus(gid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := server.Calls(STATUS); calls != 3 {
+		t.Fatalf("tellStatus calls: got %d, want 3", calls)
+	}
+}
+
+func TestAria2WrongSecretIsUnauthorized(t *testing.T) {
+	server := aria2test.NewServer("secret")
+	defer server.Close()
+
+	aria2Client := GetAria2Client(server.Host(), "wrong", server.Port())
+
+	err := aria2Client.RemoveDownload("2089b05ecca3d829")
+	var aria2Error *Aria2Error
+	if !errors.As(err, &aria2Error) {
+		t.Fatalf("RemoveDownload: got %v, want *Aria2Error", err)
+	}
+	if !errors.Is(err, web.ErrUnauthorized) {
+		t.Fatalf("RemoveDownload: got %v, want web.ErrUnauthorized", err)
+	}
+
+	_, err = aria2Client.AddDownload("http://127.0.0.1/file", t.TempDir(), "file")
+	if !errors.Is(err, web.ErrUnauthorized) {
+		t.Fatalf("AddDownload: got %v, want web.ErrUnauthorized", err)
+	}
+
+	err = aria2Client.SetMaxConcurrentDownloads("3")
+	if !errors.Is(err, web.ErrUnauthorized) {
+		t.Fatalf("SetMaxConcurrentDownloads: got %v, want web.ErrUnauthorized", err)
+	}
+}
+
+func TestAria2UnknownGidIsNotFound(t *testing.T) {
+	server := aria2test.NewServer("secret")
+	defer server.Close()
+
+	aria2Client := GetAria2Client(server.Host(), "secret", server.Port())
+
+	_, err := aria2Client.DownloadStatus("2089b05ecca3d829")
+	if !errors.Is(err, web.ErrNotFound) {
+		t.Fatalf("DownloadStatus: got %v, want web.ErrNotFound", err)
+	}
+
+	err = aria2Client.RemoveDownload("2089b05ecca3d829")
+	if !errors.Is(err, web.ErrNotFound) {
+		t.Fatalf("RemoveDownload: got %v, want web.ErrNotFound", err)
+	}
+}
+
+func TestAria2SetMaxConcurrentDownloads(t *testing.T) {
+	server := aria2test.NewServer("secret")
+	defer server.Close()
+
+	aria2Client := GetAria2Client(server.Host(), "secret", server.Port())
+
+	err := aria2Client.SetMaxConcurrentDownloads("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if max := server.MaxConcurrentDownloads(); max != 3 {
+		t.Fatalf("max concurrent downloads: got %d, want 3", max)
+	}
+}
@@ -0,0 +1,478 @@
+package aria2test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	STATUS_WAITING  = "waiting"
+	STATUS_ACTIVE   = "active"
+	STATUS_COMPLETE = "complete"
+	STATUS_ERROR    = "error"
+	STATUS_REMOVED  = "removed"
+
+	// error codes of aria2
+	ERROR_CODE_UNKNOWN            = "1"
+	ERROR_CODE_RESOURCE_NOT_FOUND = "3"
+	ERROR_CODE_FILE_EXISTS        = "13"
+	ERROR_CODE_FILE_IO            = "16"
+	ERROR_CODE_HTTP_RESPONSE      = "22"
+
+	PIECE_LENGTH = 1024 * 1024
+
+	downloadBufferSize = 16 * 1024
+)
+
+// Status is a snapshot of a download
+type Status struct {
+	Gid             string
+	Uri             string
+	Path            string
+	Status          string
+	TotalLength     int64
+	CompletedLength int64
+	ErrorCode       string
+	ErrorMessage    string
+}
+
+type download struct {
+	status    Status
+	dir       string
+	userAgent string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startedAt time.Time
+}
+
+type addUriOptions struct {
+	Dir       string `json:"dir"`
+	Out       string `json:"out"`
+	UserAgent string `json:"user-agent"`
+}
+
+// Download returns a snapshot of the download, nil for an unknown gid
+func (server *Server) Download(gid string) *Status {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	download := server.downloads[gid]
+	if download == nil {
+		return nil
+	}
+
+	status := download.status
+	return &status
+}
+
+// UserAgent returns the user agent the download is sent with
+func (server *Server) UserAgent(gid string) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	download := server.downloads[gid]
+	if download == nil {
+		return ""
+	}
+
+	return download.userAgent
+}
+
+// MaxConcurrentDownloads is the limit of active downloads, the others are waiting
+func (server *Server) MaxConcurrentDownloads() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.maxConcurrentDownloads
+}
+
+func (server *Server) addUri(params []json.RawMessage) (interface{}, error) {
+	uris := []string{}
+	err := decodeParam(params, 0, &uris)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(uris) == 0 {
+		return nil, &RpcError{Code: RPC_CODE_ERROR, Message: "URI is not provided."}
+	}
+
+	uri, err := url.Parse(uris[0])
+	if err != nil || (uri.Scheme != "http" && uri.Scheme != "https") {
+		return nil, &RpcError{Code: RPC_CODE_ERROR, Message: fmt.Sprintf("No URI to download. Unsupported uri:%s", uris[0])}
+	}
+
+	options := addUriOptions{}
+	if len(params) > 1 {
+		err = decodeParam(params, 1, &options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dir := options.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+
+	out := options.Out
+	if out == "" {
+		out = path.Base(uri.Path)
+		if out == "/" || out == "." {
+			out = "index.html"
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.nextGid++
+	gid := fmt.Sprintf("%016x", server.nextGid)
+
+	download := &download{
+		status: Status{
+			Gid:    gid,
+			Uri:    uris[0],
+			Path:   filepath.Join(dir, out),
+			Status: STATUS_WAITING,
+		},
+		dir:       dir,
+		userAgent: options.UserAgent,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	server.downloads[gid] = download
+	server.queue = append(server.queue, download)
+	server.schedule()
+
+	return gid, nil
+}
+
+func (server *Server) tellStatus(params []json.RawMessage) (interface{}, error) {
+	gid := ""
+	err := decodeParam(params, 0, &gid)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	if len(params) > 1 {
+		err = decodeParam(params, 1, &keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	status := server.Download(gid)
+	if status == nil {
+		return nil, &RpcError{Code: RPC_CODE_ERROR, Message: fmt.Sprintf("GID %s is not found", gid)}
+	}
+
+	result := server.statusResult(status)
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	filtered := map[string]interface{}{}
+	for _, key := range keys {
+		if value, ok := result[key]; ok {
+			filtered[key] = value
+		}
+	}
+
+	return filtered, nil
+}
+
+// statusResult encodes the status like aria2, with all the numbers as strings
+func (server *Server) statusResult(status *Status) map[string]interface{} {
+	connections := "0"
+	downloadSpeed := int64(0)
+	if status.Status == STATUS_ACTIVE {
+		connections = "1"
+		downloadSpeed = server.downloadSpeed(status.Gid)
+	}
+
+	numPieces := (status.TotalLength + PIECE_LENGTH - 1) / PIECE_LENGTH
+
+	uriStatus := "waiting"
+	if status.Status != STATUS_WAITING {
+		uriStatus = "used"
+	}
+
+	result := map[string]interface{}{
+		"gid":             status.Gid,
+		"status":          status.Status,
+		"totalLength":     strconv.FormatInt(status.TotalLength, 10),
+		"completedLength": strconv.FormatInt(status.CompletedLength, 10),
+		"uploadLength":    "0",
+		"downloadSpeed":   strconv.FormatInt(downloadSpeed, 10),
+		"uploadSpeed":     "0",
+		"connections":     connections,
+		"numPieces":       strconv.FormatInt(numPieces, 10),
+		"pieceLength":     strconv.Itoa(PIECE_LENGTH),
+		"dir":             filepath.Dir(status.Path),
+		"files": []map[string]interface{}{
+			{
+				"index":           "1",
+				"path":            status.Path,
+				"length":          strconv.FormatInt(status.TotalLength, 10),
+				"completedLength": strconv.FormatInt(status.CompletedLength, 10),
+				"selected":        "true",
+				"uris": []map[string]string{
+					{"uri": status.Uri, "status": uriStatus},
+				},
+			},
+		},
+	}
+
+	if status.Status == STATUS_ERROR {
+		result["errorCode"] = status.ErrorCode
+		result["errorMessage"] = status.ErrorMessage
+	}
+
+	return result
+}
+
+func (server *Server) downloadSpeed(gid string) int64 {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	download := server.downloads[gid]
+	if download == nil {
+		return 0
+	}
+
+	elapsed := time.Since(download.startedAt).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return int64(float64(download.status.CompletedLength) / elapsed)
+}
+
+func (server *Server) remove(params []json.RawMessage) (interface{}, error) {
+	gid := ""
+	err := decodeParam(params, 0, &gid)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	download := server.downloads[gid]
+	if download == nil {
+		return nil, &RpcError{Code: RPC_CODE_ERROR, Message: fmt.Sprintf("Active Download not found for GID#%s", gid)}
+	}
+
+	switch download.status.Status {
+	case STATUS_WAITING, STATUS_ACTIVE:
+	default:
+		return nil, &RpcError{Code: RPC_CODE_ERROR, Message: fmt.Sprintf("Active Download not found for GID#%s", gid)}
+	}
+
+	download.cancel()
+	download.status.Status = STATUS_REMOVED
+	server.dequeue(download)
+	server.schedule()
+
+	return gid, nil
+}
+
+func (server *Server) changeGlobalOption(params []json.RawMessage) (interface{}, error) {
+	options := map[string]string{}
+	err := decodeParam(params, 0, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for option, value := range options {
+		switch option {
+		case "max-concurrent-downloads":
+			maxConcurrentDownloads, err := strconv.Atoi(value)
+			if err != nil || maxConcurrentDownloads < 1 {
+				return nil, &RpcError{Code: RPC_CODE_ERROR, Message: fmt.Sprintf("We encountered a problem while processing the option '--%s'.", option)}
+			}
+			server.maxConcurrentDownloads = maxConcurrentDownloads
+		default:
+			// the other options are accepted and ignored
+		}
+	}
+
+	server.schedule()
+
+	return "OK", nil
+}
+
+// schedule starts waiting downloads while there are free slots, the caller holds the mutex
+func (server *Server) schedule() {
+	active := 0
+	for _, download := range server.queue {
+		if download.status.Status == STATUS_ACTIVE {
+			active++
+		}
+	}
+
+	for _, download := range server.queue {
+		if active >= server.maxConcurrentDownloads {
+			return
+		}
+
+		if download.status.Status != STATUS_WAITING {
+			continue
+		}
+
+		download.status.Status = STATUS_ACTIVE
+		download.startedAt = time.Now()
+		active++
+		go server.run(download)
+	}
+}
+
+// dequeue removes a download which is not waiting nor active anymore, the caller holds the mutex
+func (server *Server) dequeue(download *download) {
+	for i, queued := range server.queue {
+		if queued == download {
+			server.queue = append(server.queue[:i], server.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (server *Server) run(download *download) {
+	errorCode, err := server.fetch(download)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	// a removed download keeps its status
+	if download.status.Status == STATUS_ACTIVE {
+		if err != nil {
+			download.status.Status = STATUS_ERROR
+			download.status.ErrorCode = errorCode
+			download.status.ErrorMessage = err.Error()
+		} else {
+			download.status.Status = STATUS_COMPLETE
+		}
+	}
+
+	server.dequeue(download)
+	server.schedule()
+}
+
+// fetch downloads the uri into the path of the download, it returns the aria2 error code of a failure
+func (server *Server) fetch(download *download) (string, error) {
+	server.mutex.Lock()
+	uri := download.status.Uri
+	filePath := download.status.Path
+	userAgent := download.userAgent
+	server.mutex.Unlock()
+
+	// like aria2 without --allow-overwrite
+	_, err := os.Stat(filePath)
+	if err == nil {
+		return ERROR_CODE_FILE_EXISTS, fmt.Errorf("File %s exists, but a control file(*.aria2) does not exist. Download was canceled in order to prevent your file from being truncated to 0.", filePath)
+	}
+
+	request, err := http.NewRequestWithContext(download.ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return ERROR_CODE_UNKNOWN, err
+	}
+	if userAgent != "" {
+		request.Header.Set("User-Agent", userAgent)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return ERROR_CODE_UNKNOWN, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return ERROR_CODE_RESOURCE_NOT_FOUND, errors.New("Resource not found")
+	case response.StatusCode != http.StatusOK:
+		return ERROR_CODE_HTTP_RESPONSE, fmt.Errorf("The response status is not successful. status=%d", response.StatusCode)
+	}
+
+	server.mutex.Lock()
+	download.status.TotalLength = response.ContentLength
+	server.mutex.Unlock()
+
+	err = os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return ERROR_CODE_FILE_IO, err
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return ERROR_CODE_FILE_IO, err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, downloadBufferSize)
+	completed := int64(0)
+	for {
+		n, readErr := response.Body.Read(buffer)
+		if n > 0 {
+			_, err = file.Write(buffer[:n])
+			if err != nil {
+				return ERROR_CODE_FILE_IO, err
+			}
+
+			completed += int64(n)
+			server.mutex.Lock()
+			download.status.CompletedLength = completed
+			if download.status.TotalLength < completed {
+				download.status.TotalLength = completed
+			}
+			startedAt := download.startedAt
+			server.mutex.Unlock()
+
+			server.throttle(download.ctx, startedAt, completed)
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return ERROR_CODE_UNKNOWN, readErr
+		}
+	}
+
+	return "", nil
+}
+
+// throttle waits until completed bytes are within DownloadRate since startedAt
+func (server *Server) throttle(ctx context.Context, startedAt time.Time, completed int64) {
+	if server.DownloadRate <= 0 {
+		return
+	}
+
+	expected := time.Duration(float64(completed) / float64(server.DownloadRate) * float64(time.Second))
+	wait := expected - time.Since(startedAt)
+	if wait <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,204 @@
+// Package aria2test provides an in-process fake aria2 daemon speaking JSON-RPC over HTTP. It really downloads
+// the uris it is given into the requested directory, so download workflows can be tested end to end offline.
+package aria2test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-swan-lib/internal/faketest"
+)
+
+const (
+	METHOD_ADD_URI              = "aria2.addUri"
+	METHOD_TELL_STATUS          = "aria2.tellStatus"
+	METHOD_REMOVE               = "aria2.remove"
+	METHOD_CHANGE_GLOBAL_OPTION = "aria2.changeGlobalOption"
+
+	TOKEN_PREFIX = "token:"
+
+	RPC_CODE_PARSE_ERROR      = -32700
+	RPC_CODE_METHOD_NOT_FOUND = -32601
+	RPC_CODE_INVALID_PARAMS   = -32602
+	RPC_CODE_ERROR            = 1
+
+	MAX_CONCURRENT_DOWNLOADS_DEFAULT = 5
+)
+
+// RpcError is the JSON-RPC error of an answer
+type RpcError = faketest.RpcError
+
+// Fault is injected in the answers to a method, besides Delay, HttpStatus and Drop it applies RpcError
+type Fault = faketest.Fault
+
+type request = faketest.Request
+
+type response struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Id      interface{} `json:"id"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *RpcError   `json:"error,omitempty"`
+}
+
+// Server is a fake aria2 daemon, it is safe for concurrent use
+type Server struct {
+	URL string // JSON-RPC endpoint, Host and Port give what client.GetAria2Client needs
+
+	// DownloadRate limits the speed of every download in bytes per second, 0 means unlimited.
+	// A low rate lets tests observe the active status and the progress of downloads.
+	DownloadRate int64
+
+	faketest.Faults
+
+	httpServer             *httptest.Server
+	secret                 string
+	mutex                  sync.Mutex
+	downloads              map[string]*download
+	queue                  []*download
+	maxConcurrentDownloads int
+	nextGid                uint64
+}
+
+// NewServer starts a fake aria2 daemon requiring secret as rpc-secret, an empty secret disables authentication
+func NewServer(secret string) *Server {
+	server := &Server{
+		secret:                 secret,
+		downloads:              map[string]*download{},
+		maxConcurrentDownloads: MAX_CONCURRENT_DOWNLOADS_DEFAULT,
+		nextGid:                uint64(time.Now().UnixNano()),
+	}
+
+	server.httpServer = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	server.URL = server.httpServer.URL + "/jsonrpc"
+
+	return server
+}
+
+func (server *Server) Host() string {
+	host, _, _ := net.SplitHostPort(server.httpServer.Listener.Addr().String())
+	return host
+}
+
+func (server *Server) Port() int {
+	_, port, _ := net.SplitHostPort(server.httpServer.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return portNum
+}
+
+// Close stops the downloads in progress and the server
+func (server *Server) Close() {
+	server.mutex.Lock()
+	for _, download := range server.downloads {
+		download.cancel()
+	}
+	server.mutex.Unlock()
+
+	server.httpServer.Close()
+}
+
+// InjectFault makes the calls to method fail, an empty method affects all the methods
+func (server *Server) InjectFault(method string, fault Fault) {
+	server.SetFault(method, fault)
+}
+
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/jsonrpc" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	req := &request{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, response{JsonRpc: "2.0", Error: &RpcError{Code: RPC_CODE_PARSE_ERROR, Message: "Parse error."}})
+		return
+	}
+
+	fault := server.TakeFault(req.Method)
+	if fault.Interrupt(w) {
+		return
+	}
+	if fault != nil && fault.RpcError != nil {
+		writeJson(w, http.StatusOK, response{JsonRpc: "2.0", Id: req.Id, Error: fault.RpcError})
+		return
+	}
+
+	result, err := server.answer(req)
+	if err != nil {
+		rpcError, ok := err.(*RpcError)
+		if !ok {
+			rpcError = &RpcError{Code: RPC_CODE_ERROR, Message: err.Error()}
+		}
+		// like aria2, errors are answered with 400
+		writeJson(w, http.StatusBadRequest, response{JsonRpc: "2.0", Id: req.Id, Error: rpcError})
+		return
+	}
+
+	writeJson(w, http.StatusOK, response{JsonRpc: "2.0", Id: req.Id, Result: result})
+}
+
+func (server *Server) answer(req *request) (interface{}, error) {
+	params := req.Params
+	if server.secret != "" {
+		token := ""
+		if len(params) > 0 {
+			json.Unmarshal(params[0], &token)
+		}
+		if token != TOKEN_PREFIX+server.secret {
+			return nil, &RpcError{Code: RPC_CODE_ERROR, Message: "Unauthorized"}
+		}
+		params = params[1:]
+	} else if len(params) > 0 {
+		// the token is optional without a secret
+		token := ""
+		if json.Unmarshal(params[0], &token) == nil && strings.HasPrefix(token, TOKEN_PREFIX) {
+			params = params[1:]
+		}
+	}
+
+	switch req.Method {
+	case METHOD_ADD_URI:
+		return server.addUri(params)
+	case METHOD_TELL_STATUS:
+		return server.tellStatus(params)
+	case METHOD_REMOVE:
+		return server.remove(params)
+	case METHOD_CHANGE_GLOBAL_OPTION:
+		return server.changeGlobalOption(params)
+	}
+
+	return nil, &RpcError{Code: RPC_CODE_METHOD_NOT_FOUND, Message: fmt.Sprintf("No such method: %s", req.Method)}
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(value)
+}
+
+func decodeParam(params []json.RawMessage, index int, value interface{}) error {
+	if index >= len(params) {
+		return &RpcError{Code: RPC_CODE_INVALID_PARAMS, Message: fmt.Sprintf("missing param %d", index)}
+	}
+
+	err := json.Unmarshal(params[index], value)
+	if err != nil {
+		return &RpcError{Code: RPC_CODE_INVALID_PARAMS, Message: fmt.Sprintf("invalid param %d: %s", index, err.Error())}
+	}
+
+	return nil
+}
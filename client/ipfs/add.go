@@ -0,0 +1,201 @@
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	// content types of the parts of an add request, like go-ipfs-files writes them
+	IPFS_CONTENT_TYPE_FILE      = "application/octet-stream"
+	IPFS_CONTENT_TYPE_DIRECTORY = "application/x-directory"
+	IPFS_CONTENT_TYPE_SYMLINK   = "application/symlink"
+)
+
+type AddOptions struct {
+	WrapDirectory bool // wrap what is added in a directory, the root is then that directory
+	CidVersion    int  // 1 for CID v1, 0 means the ipfs default CID v0
+	RawLeaves     bool // raw leaves are already the ipfs default with CID v1
+	NoPin         bool // ipfs pins what is added unless set
+}
+
+// AddEntry is a file or a directory added, Name is its path relative to the parent of what was added
+type AddEntry struct {
+	Name string
+	Hash string
+	Size uint64
+}
+
+type AddResult struct {
+	Root    *AddEntry   // what was added, or the wrapping directory
+	Entries []*AddEntry // in the order ipfs answered them, the root last
+}
+
+// Add adds a file, a symlink or a directory with everything below it
+func (ipfsClient *IpfsClient) Add(ctx context.Context, srcPath string, addOptions AddOptions) (*AddResult, error) {
+	args := url.Values{
+		"wrap-with-directory": {strconv.FormatBool(addOptions.WrapDirectory)},
+		"pin":                 {strconv.FormatBool(!addOptions.NoPin)},
+		"progress":            {"false"},
+		"stream-channels":     {"true"},
+	}
+	if addOptions.CidVersion != 0 {
+		args.Set("cid-version", strconv.Itoa(addOptions.CidVersion))
+	}
+	if addOptions.RawLeaves {
+		args.Set("raw-leaves", "true")
+	}
+
+	addResult, err := ipfsClient.add(ctx, ipfsClient.commandUrl("add", args), srcPath)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return addResult, nil
+}
+
+// add sends srcPath to the full url of the add command, its options included
+func (ipfsClient *IpfsClient) add(ctx context.Context, apiUrlFull, srcPath string) (*AddResult, error) {
+	srcFileInfo, err := os.Lstat(srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	bodyWriter := multipart.NewWriter(pipeWriter)
+	go func() {
+		err := writeAddParts(bodyWriter, srcPath, filepath.Base(srcPath), srcFileInfo)
+		if err == nil {
+			err = bodyWriter.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	response, err := ipfsClient.request(ctx, apiUrlFull, pipeReader, bodyWriter.FormDataContentType())
+	if err != nil {
+		pipeReader.Close()
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	addResult := &AddResult{}
+	err = readStream(response, apiUrlFull, func(object json.RawMessage) error {
+		result := struct {
+			Name  string
+			Hash  string
+			Size  json.Number // a string in the answers of ipfs
+			Bytes int64       // progress objects have no hash
+		}{}
+		err := json.Unmarshal(object, &result)
+		if err != nil {
+			return err
+		}
+		if result.Hash == "" {
+			return nil
+		}
+
+		addEntry := &AddEntry{Name: result.Name, Hash: result.Hash}
+		if result.Size != "" {
+			size, err := strconv.ParseUint(result.Size.String(), 10, 64)
+			if err != nil {
+				return err
+			}
+			addEntry.Size = size
+		}
+
+		addResult.Entries = append(addResult.Entries, addEntry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addResult.Entries) == 0 {
+		err := fmt.Errorf("nothing added, url:%s", apiUrlFull)
+		return nil, err
+	}
+
+	addResult.Root = addResult.Entries[len(addResult.Entries)-1]
+	return addResult, nil
+}
+
+// writeAddParts writes the part of srcPath named name, then the parts of what is below it when it is a directory
+func writeAddParts(bodyWriter *multipart.Writer, srcPath, name string, fileInfo fs.FileInfo) error {
+	header := textproto.MIMEHeader{}
+	// the names are escaped like go-ipfs-files does, ipfs unescapes them
+	header.Set("Content-Disposition", fmt.Sprintf("form-data; name=\"file\"; filename=\"%s\"", url.QueryEscape(name)))
+
+	switch mode := fileInfo.Mode(); {
+	case mode.IsRegular():
+		header.Set("Content-Type", IPFS_CONTENT_TYPE_FILE)
+		partWriter, err := bodyWriter.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(srcPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(partWriter, file)
+		return err
+	case mode.IsDir():
+		header.Set("Content-Type", IPFS_CONTENT_TYPE_DIRECTORY)
+		_, err := bodyWriter.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		entries, err := os.ReadDir(srcPath)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			entryPath := filepath.Join(srcPath, entry.Name())
+			entryInfo, err := os.Lstat(entryPath)
+			if err != nil {
+				return err
+			}
+
+			err = writeAddParts(bodyWriter, entryPath, path.Join(name, entry.Name()), entryInfo)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(srcPath)
+		if err != nil {
+			return err
+		}
+
+		header.Set("Content-Type", IPFS_CONTENT_TYPE_SYMLINK)
+		partWriter, err := bodyWriter.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(partWriter, target)
+		return err
+	default:
+		err := fmt.Errorf("unsupported file type:%s, path:%s", mode.Type().String(), srcPath)
+		return err
+	}
+}
@@ -0,0 +1,171 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestIpfs returns a client of a fake ipfs api answering every command with handler
+func newTestIpfs(t *testing.T, handler http.HandlerFunc) *IpfsClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	ipfsClient, err := GetClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ipfsClient
+}
+
+// addPart is a part of an add request, Filename is as sent, escaped
+type addPart struct {
+	Filename    string
+	ContentType string
+	Content     string
+}
+
+func readAddParts(t *testing.T, r *http.Request) []addPart {
+	multipartReader, err := r.MultipartReader()
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+
+	parts := []addPart{}
+	for {
+		part, err := multipartReader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+
+		_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+
+		parts = append(parts, addPart{Filename: params["filename"], ContentType: part.Header.Get("Content-Type"), Content: string(content)})
+	}
+}
+
+func TestAddWritesNestedDirectoriesAndSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "my dir")
+	for path, content := range map[string]string{"a b.txt": "a", "sub/c&d=%": "cd", "sub/deeper/é": "e"} {
+		filePath := filepath.Join(srcPath, filepath.FromSlash(path))
+		err := os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filePath, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.Symlink("../a b.txt", filepath.Join(srcPath, "sub", "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []addPart
+	ipfsClient := newTestIpfs(t, func(w http.ResponseWriter, r *http.Request) {
+		parts = readAddParts(t, r)
+		fmt.Fprintln(w, `{"Name":"my dir/a b.txt","Hash":"QmA","Size":"9"}`)
+		fmt.Fprintln(w, `{"Name":"my dir","Hash":"QmRoot","Size":"200"}`)
+	})
+
+	_, err = ipfsClient.Add(context.Background(), srcPath, AddOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []addPart{
+		{Filename: "my+dir", ContentType: IPFS_CONTENT_TYPE_DIRECTORY},
+		{Filename: "my+dir%2Fa+b.txt", ContentType: IPFS_CONTENT_TYPE_FILE, Content: "a"},
+		{Filename: "my+dir%2Fsub", ContentType: IPFS_CONTENT_TYPE_DIRECTORY},
+		{Filename: "my+dir%2Fsub%2Fc%26d%3D%25", ContentType: IPFS_CONTENT_TYPE_FILE, Content: "cd"},
+		{Filename: "my+dir%2Fsub%2Fdeeper", ContentType: IPFS_CONTENT_TYPE_DIRECTORY},
+		{Filename: "my+dir%2Fsub%2Fdeeper%2F%C3%A9", ContentType: IPFS_CONTENT_TYPE_FILE, Content: "e"},
+		{Filename: "my+dir%2Fsub%2Flink", ContentType: IPFS_CONTENT_TYPE_SYMLINK, Content: "../a b.txt"},
+	}
+	if fmt.Sprint(parts) != fmt.Sprint(want) {
+		t.Fatalf("parts:\n got %v\nwant %v", parts, want)
+	}
+}
+
+func TestAddRootIsLastEntry(t *testing.T) {
+	srcPath := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(srcPath, []byte("content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var query string
+	ipfsClient := newTestIpfs(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		io.Copy(io.Discard, r.Body)
+		fmt.Fprintln(w, `{"Name":"file","Bytes":3}`)
+		fmt.Fprintln(w, `{"Name":"file","Bytes":7}`)
+		fmt.Fprintln(w, `{"Name":"file","Hash":"bafkfile","Size":"7"}`)
+		fmt.Fprintln(w, `{"Name":"","Bytes":7}`)
+		fmt.Fprintln(w, `{"Name":"","Hash":"bafywrap","Size":"60"}`)
+	})
+
+	addResult, err := ipfsClient.Add(context.Background(), srcPath, AddOptions{WrapDirectory: true, CidVersion: 1, NoPin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(addResult.Entries) != 2 || *addResult.Entries[0] != (AddEntry{Name: "file", Hash: "bafkfile", Size: 7}) {
+		t.Fatalf("entries: %v", addResult.Entries)
+	}
+	if *addResult.Root != (AddEntry{Hash: "bafywrap", Size: 60}) {
+		t.Fatalf("root: %+v", addResult.Root)
+	}
+	for _, arg := range []string{"wrap-with-directory=true", "pin=false", "cid-version=1"} {
+		if !strings.Contains(query, arg) {
+			t.Fatalf("query %s without %s", query, arg)
+		}
+	}
+}
+
+// ipfs reports the errors happening once the response started in the X-Stream-Error trailer
+func TestAddStreamErrorTrailer(t *testing.T) {
+	srcPath := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(srcPath, []byte("content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipfsClient := newTestIpfs(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Trailer", IPFS_STREAM_ERROR_TRAILER)
+		fmt.Fprintln(w, `{"Name":"file","Hash":"bafkfile","Size":"7"}`)
+		w.Header().Set(IPFS_STREAM_ERROR_TRAILER, "write failed: no space left on device")
+	})
+
+	_, err = ipfsClient.Add(context.Background(), srcPath, AddOptions{})
+	var ipfsAPIError *IpfsAPIError
+	if !errors.As(err, &ipfsAPIError) || ipfsAPIError.StatusCode != http.StatusOK || ipfsAPIError.Message != "write failed: no space left on device" {
+		t.Fatalf("got %v, want an *IpfsAPIError with the trailer", err)
+	}
+}
@@ -0,0 +1,273 @@
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/filswan/go-swan-lib/client/web"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/filswan/go-swan-lib/utils"
+)
+
+const (
+	IPFS_API_PATH = "api/v0"
+
+	// type of the error objects ipfs writes in its responses
+	IPFS_ERROR_TYPE = "error"
+)
+
+// IpfsClient calls the http api of an ipfs (kubo) node, ApiUrl is its root like http://127.0.0.1:5001
+type IpfsClient struct {
+	ApiUrl      string
+	HttpClient  *web.Client      // nil means web.DefaultClient()
+	RetryPolicy *web.RetryPolicy // nil means the retry policy of HttpClient, only read commands are retried
+}
+
+type IdInfo struct {
+	ID              string
+	PublicKey       string
+	Addresses       []string
+	AgentVersion    string
+	ProtocolVersion string
+}
+
+type FilesStat struct {
+	Hash           string
+	Size           uint64
+	CumulativeSize uint64
+	Blocks         int
+	Type           string // file or directory
+}
+
+func GetClient(apiUrl string) (*IpfsClient, error) {
+	if len(apiUrl) == 0 {
+		err := fmt.Errorf("ipfs api url is required")
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	ipfsClient := &IpfsClient{
+		ApiUrl: apiUrl,
+	}
+
+	return ipfsClient, nil
+}
+
+func (ipfsClient *IpfsClient) httpClient() *web.Client {
+	httpClient := ipfsClient.HttpClient
+	if httpClient == nil {
+		httpClient = web.DefaultClient()
+	}
+
+	if ipfsClient.RetryPolicy != nil {
+		httpClient = httpClient.WithRetryPolicy(ipfsClient.RetryPolicy)
+	}
+
+	return httpClient
+}
+
+func (ipfsClient *IpfsClient) commandUrl(command string, args url.Values) string {
+	apiUrlFull := utils.UrlJoin(ipfsClient.ApiUrl, IPFS_API_PATH, command)
+	if len(args) > 0 {
+		apiUrlFull = apiUrlFull + "?" + args.Encode()
+	}
+
+	return apiUrlFull
+}
+
+// request posts to the ipfs api, the only method it accepts, and returns the response when the status is 200.
+// Requests without body sent with a ctx marked by web.WithIdempotent are retried, the caller closes the response body.
+func (ipfsClient *IpfsClient) request(ctx context.Context, apiUrlFull string, body io.Reader, contentType string) (*http.Response, error) {
+	httpClient := ipfsClient.httpClient()
+	retryable := body == nil && web.IsIdempotent(ctx, http.MethodPost)
+
+	var response *http.Response
+	err := httpClient.RetryPolicy().Retry(ctx, func(attempt int) (bool, error) {
+		if attempt > 1 {
+			logs.Log().Info("retrying ", apiUrlFull, ", attempt:", attempt)
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiUrlFull, body)
+		if err != nil {
+			return false, err
+		}
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+
+		response, err = httpClient.Do(request)
+		if err != nil {
+			return retryable && ctx.Err() == nil, err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			err := newIpfsAPIError(apiUrlFull, response)
+			return retryable && httpClient.RetryPolicy().IsRetryableStatus(response.StatusCode), err
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// newIpfsAPIError reads the error ipfs answered, a json object or plain text, and closes the response body
+func newIpfsAPIError(apiUrlFull string, response *http.Response) *IpfsAPIError {
+	defer response.Body.Close()
+
+	ipfsAPIError := &IpfsAPIError{
+		Url:        apiUrlFull,
+		StatusCode: response.StatusCode,
+	}
+
+	responseBody, err := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if err != nil {
+		return ipfsAPIError
+	}
+
+	errorObject := struct {
+		Message string
+		Code    int
+	}{}
+	if json.Unmarshal(responseBody, &errorObject) == nil && errorObject.Message != "" {
+		ipfsAPIError.Message = errorObject.Message
+		ipfsAPIError.Code = errorObject.Code
+	} else {
+		ipfsAPIError.Message = strings.TrimSpace(string(responseBody))
+	}
+
+	return ipfsAPIError
+}
+
+// command sends a command without body and decodes each json object of its response with onObject
+func (ipfsClient *IpfsClient) command(ctx context.Context, command string, args url.Values, onObject func(object json.RawMessage) error) error {
+	apiUrlFull := ipfsClient.commandUrl(command, args)
+
+	response, err := ipfsClient.request(ctx, apiUrlFull, nil, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	return readStream(response, apiUrlFull, onObject)
+}
+
+// readStream decodes the json objects of a response one by one, like the ndjson streamed by add, pin ls or dag import.
+// An error object in the stream or in the trailer fails it.
+func readStream(response *http.Response, apiUrlFull string, onObject func(object json.RawMessage) error) error {
+	decoder := json.NewDecoder(newStreamReader(response, apiUrlFull))
+	for {
+		var object json.RawMessage
+		err := decoder.Decode(&object)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		errorObject := struct {
+			Message string
+			Code    int
+			Type    string
+		}{}
+		if json.Unmarshal(object, &errorObject) == nil && errorObject.Type == IPFS_ERROR_TYPE {
+			err := &IpfsAPIError{
+				Url:        apiUrlFull,
+				StatusCode: response.StatusCode,
+				Message:    errorObject.Message,
+				Code:       errorObject.Code,
+			}
+			return err
+		}
+
+		err = onObject(object)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// streamReader reads the body of a response, ipfs reports the errors happening once the response started in a trailer
+type streamReader struct {
+	response   *http.Response
+	apiUrlFull string
+}
+
+func newStreamReader(response *http.Response, apiUrlFull string) *streamReader {
+	return &streamReader{response: response, apiUrlFull: apiUrlFull}
+}
+
+func (streamReader *streamReader) Read(p []byte) (int, error) {
+	n, err := streamReader.response.Body.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+
+	if streamErr := streamReader.response.Trailer.Get(IPFS_STREAM_ERROR_TRAILER); streamErr != "" {
+		err := &IpfsAPIError{
+			Url:        streamReader.apiUrlFull,
+			StatusCode: streamReader.response.StatusCode,
+			Message:    streamErr,
+		}
+		return n, err
+	}
+
+	return n, io.EOF
+}
+
+func (streamReader *streamReader) Close() error {
+	return streamReader.response.Body.Close()
+}
+
+// decodeLast sets result to the last json object of the response
+func decodeLast(result interface{}) func(object json.RawMessage) error {
+	return func(object json.RawMessage) error {
+		return json.Unmarshal(object, result)
+	}
+}
+
+func (ipfsClient *IpfsClient) Id(ctx context.Context) (*IdInfo, error) {
+	idInfo := &IdInfo{}
+	err := ipfsClient.command(web.WithIdempotent(ctx), "id", nil, decodeLast(idInfo))
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return idInfo, nil
+}
+
+// Cat streams the content of a file, ipfsPath is a cid or a path like /ipfs/<cid>/dir/file.
+// The caller closes the reader, an error of ipfs while streaming is returned by its Read.
+func (ipfsClient *IpfsClient) Cat(ctx context.Context, ipfsPath string) (io.ReadCloser, error) {
+	apiUrlFull := ipfsClient.commandUrl("cat", url.Values{"arg": {ipfsPath}})
+
+	response, err := ipfsClient.request(web.WithIdempotent(ctx), apiUrlFull, nil, "")
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return newStreamReader(response, apiUrlFull), nil
+}
+
+// FilesStat stats a path of the mutable file system, or /ipfs/<cid>
+func (ipfsClient *IpfsClient) FilesStat(ctx context.Context, mfsPath string) (*FilesStat, error) {
+	filesStat := &FilesStat{}
+	err := ipfsClient.command(web.WithIdempotent(ctx), "files/stat", url.Values{"arg": {mfsPath}}, decodeLast(filesStat))
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return filesStat, nil
+}
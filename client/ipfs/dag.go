@@ -0,0 +1,329 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/filswan/go-swan-lib/car"
+	"github.com/filswan/go-swan-lib/client/web"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/filswan/go-swan-lib/utils"
+)
+
+const (
+	// suffix of the file a car is exported to before it is checked and renamed
+	EXPORT_TEMP_FILE_SUFFIX      = ".part"
+	EXPORT_PROGRESS_LOG_INTERVAL = 1024 * 1024 * 1024
+	IPFS_STREAM_ERROR_TRAILER    = "X-Stream-Error"
+)
+
+// ExportProgress is called as the car is written with the bytes written so far
+type ExportProgress func(bytesWritten int64)
+
+type DagStat struct {
+	Size      uint64
+	NumBlocks uint64
+}
+
+type DagImportRoot struct {
+	Cid         string
+	PinErrorMsg string // why the root could not be pinned, empty when it is
+}
+
+// DagStat returns the size and the number of blocks of a dag, ipfs before 0.21 answers them at the top level,
+// later versions in DagStats
+func (ipfsClient *IpfsClient) DagStat(ctx context.Context, cid string) (*DagStat, error) {
+	stat := struct {
+		DagStat
+		DagStats []DagStat
+	}{}
+
+	args := url.Values{
+		"arg":      {cid},
+		"progress": {"false"},
+	}
+	err := ipfsClient.command(web.WithIdempotent(ctx), "dag/stat", args, decodeLast(&stat))
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	if len(stat.DagStats) > 0 {
+		return &stat.DagStats[0], nil
+	}
+
+	return &stat.DagStat, nil
+}
+
+// DagImport imports the blocks of a car file and returns its roots, pinned when pinRoots is set
+func (ipfsClient *IpfsClient) DagImport(ctx context.Context, carFileFullPath string, pinRoots bool) ([]*DagImportRoot, error) {
+	carFile, err := os.Open(carFileFullPath)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+	defer carFile.Close()
+
+	body, contentType := newFileBody(filepath.Base(carFileFullPath), carFile)
+	apiUrlFull := ipfsClient.commandUrl("dag/import", url.Values{"pin-roots": {strconv.FormatBool(pinRoots)}})
+
+	response, err := ipfsClient.request(ctx, apiUrlFull, body, contentType)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	roots := []*DagImportRoot{}
+	err = readStream(response, apiUrlFull, func(object json.RawMessage) error {
+		result := struct {
+			Root *struct {
+				Cid struct {
+					Cid string `json:"/"`
+				}
+				PinErrorMsg string
+			}
+		}{}
+		err := json.Unmarshal(object, &result)
+		if err != nil {
+			return err
+		}
+
+		// the stats of the import come in objects without root
+		if result.Root != nil {
+			roots = append(roots, &DagImportRoot{Cid: result.Root.Cid.Cid, PinErrorMsg: result.Root.PinErrorMsg})
+		}
+		return nil
+	})
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return roots, nil
+}
+
+// DagExport streams the car of the dag of cid to a temporary file next to carFileFullPath, renamed to it once
+// the root of the car is checked to be cid. Nothing is exported when carFileFullPath already is the whole car of
+// the dag: its root is cid, its blocks hash to their cids, and their number and size are the ones of DagStat.
+// dag/export cannot resume, the temporary file of an interrupted export is discarded. A nil progress logs the
+// progress every EXPORT_PROGRESS_LOG_INTERVAL bytes.
+func (ipfsClient *IpfsClient) DagExport(ctx context.Context, cid string, carFileFullPath string, progress ExportProgress) error {
+	root, err := car.ParseCid(cid)
+	if err != nil {
+		logs.Log().Error(err)
+		return err
+	}
+
+	if utils.IsFileExistsFullPath(carFileFullPath) {
+		err := ipfsClient.checkCarFile(ctx, cid, carFileFullPath, root)
+		if err == nil {
+			logs.Log().Info(carFileFullPath, " already exported from:", cid)
+			return nil
+		}
+		logs.Log().Warn(carFileFullPath, " exists but is not the whole car of:", cid, ", exporting it again, ", err)
+	}
+
+	tempFileFullPath := carFileFullPath + EXPORT_TEMP_FILE_SUFFIX
+	if fileInfo, err := os.Stat(tempFileFullPath); err == nil {
+		logs.Log().Warn("discarding ", fileInfo.Size(), " bytes of an interrupted export:", tempFileFullPath)
+	}
+
+	bytesWritten, err := ipfsClient.exportDag(ctx, cid, tempFileFullPath, progress)
+	if err != nil {
+		logs.Log().Error(err)
+		os.Remove(tempFileFullPath)
+		return err
+	}
+
+	err = checkCarFileRoot(tempFileFullPath, root)
+	if err != nil {
+		logs.Log().Error(err)
+		os.Remove(tempFileFullPath)
+		return err
+	}
+
+	err = os.Rename(tempFileFullPath, carFileFullPath)
+	if err != nil {
+		logs.Log().Error(err)
+		os.Remove(tempFileFullPath)
+		return err
+	}
+
+	logs.Log().Info(bytesWritten, " bytes have been written to:", carFileFullPath)
+	return nil
+}
+
+// exportDag streams the response of dag/export to carFileFullPath, created or truncated, and syncs it
+func (ipfsClient *IpfsClient) exportDag(ctx context.Context, cid string, carFileFullPath string, progress ExportProgress) (int64, error) {
+	args := url.Values{
+		"arg":      {cid},
+		"progress": {"false"},
+	}
+	apiUrlFull := ipfsClient.commandUrl("dag/export", args)
+
+	// nothing is written before the response starts, it is safe to retry
+	response, err := ipfsClient.request(web.WithIdempotent(ctx), apiUrlFull, nil, "")
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	carFile, err := os.Create(carFileFullPath)
+	if err != nil {
+		return 0, err
+	}
+	defer carFile.Close()
+
+	if progress == nil {
+		progress = logExportProgress(carFileFullPath)
+	}
+
+	bytesWritten, err := io.Copy(&progressWriter{writer: carFile, progress: progress}, newStreamReader(response, apiUrlFull))
+	if err != nil {
+		err := fmt.Errorf("dag export of %s failed after %d bytes:%s", cid, bytesWritten, err.Error())
+		return bytesWritten, err
+	}
+
+	err = carFile.Sync()
+	if err != nil {
+		return bytesWritten, err
+	}
+
+	return bytesWritten, carFile.Close()
+}
+
+// checkCarFile reads all the blocks of carFileFullPath and checks they are the dag of root as ipfs stats it,
+// a car cut at a section boundary by an older export only differs from the dag by its number of blocks
+func (ipfsClient *IpfsClient) checkCarFile(ctx context.Context, cid string, carFileFullPath string, root car.Cid) error {
+	carStat, err := car.ReadCarFileStat(carFileFullPath)
+	if err != nil {
+		err := fmt.Errorf("cannot read car file:%s, %s", carFileFullPath, err.Error())
+		return err
+	}
+
+	err = checkRoots(carFileFullPath, carStat.Roots, root)
+	if err != nil {
+		return err
+	}
+
+	dagStat, err := ipfsClient.DagStat(ctx, cid)
+	if err != nil {
+		return err
+	}
+
+	if carStat.NumBlocks != dagStat.NumBlocks || carStat.Size != dagStat.Size {
+		err := fmt.Errorf("car file:%s has %d blocks of %d bytes, the dag of %s has %d blocks of %d bytes",
+			carFileFullPath, carStat.NumBlocks, carStat.Size, cid, dagStat.NumBlocks, dagStat.Size)
+		return err
+	}
+
+	return nil
+}
+
+func checkCarFileRoot(carFileFullPath string, root car.Cid) error {
+	roots, err := car.ReadCarFileRoots(carFileFullPath)
+	if err != nil {
+		err := fmt.Errorf("cannot read the root of car file:%s, %s", carFileFullPath, err.Error())
+		return err
+	}
+
+	return checkRoots(carFileFullPath, roots, root)
+}
+
+func checkRoots(carFileFullPath string, roots []car.Cid, root car.Cid) error {
+	if len(roots) != 1 || !roots[0].Equals(root) {
+		err := fmt.Errorf("root of car file:%s is %v, expecting:%s", carFileFullPath, roots, root.String())
+		return err
+	}
+
+	return nil
+}
+
+func logExportProgress(carFileFullPath string) ExportProgress {
+	nextLog := int64(EXPORT_PROGRESS_LOG_INTERVAL)
+	return func(bytesWritten int64) {
+		if bytesWritten >= nextLog {
+			logs.Log().Info(bytesWritten, " bytes have been exported to:", carFileFullPath)
+			nextLog = bytesWritten - bytesWritten%EXPORT_PROGRESS_LOG_INTERVAL + EXPORT_PROGRESS_LOG_INTERVAL
+		}
+	}
+}
+
+type progressWriter struct {
+	writer       io.Writer
+	progress     ExportProgress
+	bytesWritten int64
+}
+
+func (progressWriter *progressWriter) Write(p []byte) (int, error) {
+	n, err := progressWriter.writer.Write(p)
+	progressWriter.bytesWritten += int64(n)
+	progressWriter.progress(progressWriter.bytesWritten)
+	return n, err
+}
+
+// blockPut adds a block to ipfs and checks it is stored under the cid of the block
+func (ipfsClient *IpfsClient) blockPut(ctx context.Context, block car.Block) error {
+	codec := "dag-pb"
+	if block.Cid.Codec() == car.CODEC_RAW {
+		codec = "raw"
+	}
+
+	args := url.Values{
+		"cid-codec": {codec},
+		"mhtype":    {"sha2-256"},
+		"mhlen":     {"32"},
+	}
+	apiUrlFull := ipfsClient.commandUrl("block/put", args)
+
+	body, contentType := newFileBody("", bytes.NewReader(block.Data))
+	response, err := ipfsClient.request(ctx, apiUrlFull, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	result := struct {
+		Key string
+	}{}
+	err = readStream(response, apiUrlFull, decodeLast(&result))
+	if err != nil {
+		return err
+	}
+
+	keyCid, err := car.ParseCid(result.Key)
+	if err != nil || !keyCid.Equals(block.Cid) {
+		err := fmt.Errorf("unexpected cid after block put:%s, expecting:%s", result.Key, block.Cid.String())
+		return err
+	}
+
+	return nil
+}
+
+// newFileBody streams reader as the single file of a multipart body
+func newFileBody(fileName string, reader io.Reader) (io.Reader, string) {
+	pipeReader, pipeWriter := io.Pipe()
+	bodyWriter := multipart.NewWriter(pipeWriter)
+
+	go func() {
+		fileWriter, err := bodyWriter.CreateFormFile("file", fileName)
+		if err == nil {
+			_, err = io.Copy(fileWriter, reader)
+		}
+		if err == nil {
+			err = bodyWriter.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	return pipeReader, bodyWriter.FormDataContentType()
+}
@@ -0,0 +1,189 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/car"
+)
+
+// testDag is the car of a small file served by a fake ipfs answering dag/stat and dag/export
+type testDag struct {
+	root     string
+	carBytes []byte
+	exports  atomic.Int32
+}
+
+func newTestDag(t *testing.T) (*testDag, *IpfsClient) {
+	srcFilePath := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(srcFilePath, bytes.Repeat([]byte("dag"), 4096), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carFilePath := filepath.Join(t.TempDir(), "file.car")
+	fileDesc, err := car.GenerateCarFile(srcFilePath, carFilePath, car.Config{ChunkSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carBytes, err := os.ReadFile(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carStat, err := car.ReadCarFileStat(carFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDag := &testDag{root: fileDesc.PayloadCid, carBytes: carBytes}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/dag/stat", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DagStat{Size: carStat.Size, NumBlocks: carStat.NumBlocks})
+	})
+	mux.HandleFunc("/api/v0/dag/export", func(w http.ResponseWriter, r *http.Request) {
+		testDag.exports.Add(1)
+		w.Write(carBytes)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ipfsClient, err := GetClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testDag, ipfsClient
+}
+
+// withoutLastSection returns the car without its last block, as left by an export interrupted between two blocks
+func withoutLastSection(t *testing.T, carBytes []byte) []byte {
+	offset := 0
+	lastSection := 0
+	for offset < len(carBytes) {
+		lastSection = offset
+		size, n := binary.Uvarint(carBytes[offset:])
+		if n <= 0 {
+			t.Fatal("invalid car")
+		}
+		offset += n + int(size)
+	}
+
+	return carBytes[:lastSection]
+}
+
+func TestDagExport(t *testing.T) {
+	testDag, ipfsClient := newTestDag(t)
+	carFilePath := filepath.Join(t.TempDir(), "export.car")
+
+	err := ipfsClient.DagExport(context.Background(), testDag.root, carFilePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exports := testDag.exports.Load(); exports != 1 {
+		t.Fatalf("dag/export calls: got %d, want 1", exports)
+	}
+
+	err = ipfsClient.DagExport(context.Background(), testDag.root, carFilePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exports := testDag.exports.Load(); exports != 1 {
+		t.Fatalf("complete car exported again, dag/export calls: got %d, want 1", exports)
+	}
+}
+
+func TestDagExportReplacesIncompleteCar(t *testing.T) {
+	corrupted := func(carBytes []byte) []byte {
+		carBytes = append([]byte{}, carBytes...)
+		carBytes[len(carBytes)-1] ^= 0xff
+		return carBytes
+	}
+
+	incompleteCars := map[string]func(carBytes []byte) []byte{
+		"cut in a block":     func(carBytes []byte) []byte { return carBytes[:len(carBytes)-10] },
+		"cut between blocks": func(carBytes []byte) []byte { return withoutLastSection(t, carBytes) },
+		"block not matching": corrupted,
+	}
+
+	for name, incompleteCar := range incompleteCars {
+		t.Run(name, func(t *testing.T) {
+			testDag, ipfsClient := newTestDag(t)
+			carFilePath := filepath.Join(t.TempDir(), "export.car")
+
+			roots, err := car.ReadRoots(bytes.NewReader(incompleteCar(testDag.carBytes)))
+			if err != nil || len(roots) != 1 {
+				t.Fatalf("the incomplete car lost its header: %v, %v", roots, err)
+			}
+
+			err = os.WriteFile(carFilePath, incompleteCar(testDag.carBytes), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ipfsClient.DagExport(context.Background(), testDag.root, carFilePath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exports := testDag.exports.Load(); exports != 1 {
+				t.Fatalf("dag/export calls: got %d, want 1", exports)
+			}
+
+			carBytes, err := os.ReadFile(carFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(carBytes, testDag.carBytes) {
+				t.Fatal("incomplete car not replaced by the export")
+			}
+		})
+	}
+}
+
+func TestDagImport(t *testing.T) {
+	testDag, _ := newTestDag(t)
+	carFilePath := filepath.Join(t.TempDir(), "file.car")
+	err := os.WriteFile(carFilePath, testDag.carBytes, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path, pinRoots, fileName string
+	var carBytes []byte
+	ipfsClient := newTestIpfs(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		pinRoots = r.URL.Query().Get("pin-roots")
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		fileName = fileHeader.Filename
+		carBytes, _ = io.ReadAll(file)
+
+		fmt.Fprintf(w, `{"Root":{"Cid":{"/":"%s"},"PinErrorMsg":""}}`+"\n", testDag.root)
+		fmt.Fprintln(w, `{"Stats":{"BlockCount":4,"BlockBytesCount":12288}}`)
+	})
+
+	roots, err := ipfsClient.DagImport(context.Background(), carFilePath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || *roots[0] != (DagImportRoot{Cid: testDag.root}) {
+		t.Fatalf("roots: %v", roots)
+	}
+	if path != "/api/v0/dag/import" || pinRoots != "true" || fileName != "file.car" || !bytes.Equal(carBytes, testDag.carBytes) {
+		t.Fatalf("dag/import request: %s, pin-roots:%s, file:%s of %d bytes", path, pinRoots, fileName, len(carBytes))
+	}
+}
@@ -0,0 +1,41 @@
+package ipfs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/filswan/go-swan-lib/client/web"
+)
+
+// IpfsAPIError is returned when the ipfs api answers with an error, before or while streaming its response
+type IpfsAPIError struct {
+	Url        string
+	StatusCode int // http status, 200 for an error reported in the stream
+	Message    string
+	Code       int
+}
+
+func (ipfsAPIError *IpfsAPIError) Error() string {
+	if ipfsAPIError.Message == "" {
+		return fmt.Sprintf("ipfs api http status:%d, url:%s", ipfsAPIError.StatusCode, ipfsAPIError.Url)
+	}
+	return fmt.Sprintf("ipfs api error:%s, url:%s", ipfsAPIError.Message, ipfsAPIError.Url)
+}
+
+// Is matches web.ErrUnauthorized, web.ErrPermissionDenied, and web.ErrNotFound for a missing block, path or pin
+func (ipfsAPIError *IpfsAPIError) Is(target error) bool {
+	message := strings.ToLower(ipfsAPIError.Message)
+
+	switch target {
+	case web.ErrUnauthorized:
+		return ipfsAPIError.StatusCode == http.StatusUnauthorized
+	case web.ErrPermissionDenied:
+		return ipfsAPIError.StatusCode == http.StatusForbidden
+	case web.ErrNotFound:
+		return ipfsAPIError.StatusCode == http.StatusNotFound ||
+			strings.Contains(message, "not found") || strings.Contains(message, "not pinned") || strings.Contains(message, "no link named")
+	}
+
+	return false
+}
@@ -1,47 +1,45 @@
 package ipfs
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
-	"github.com/filswan/go-swan-lib/utils"
 )
 
+// IpfsUploadFileByWebApi adds a file with the full url of the add command, like
+// http://127.0.0.1:5001/api/v0/add?stream-channels=true&pin=true, and returns its cid
 func IpfsUploadFileByWebApi(apiUrl, filefullpath string) (*string, error) {
-	response, err := web.HttpUploadFileByStream(apiUrl, filefullpath)
+	return IpfsUploadFileByWebApiWithContext(context.Background(), apiUrl, filefullpath)
+}
+
+func IpfsUploadFileByWebApiWithContext(ctx context.Context, apiUrl, filefullpath string) (*string, error) {
+	addResult, err := (&IpfsClient{}).add(ctx, apiUrl, filefullpath)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
-	fileHash := utils.GetFieldStrFromJson(response, "Hash")
-	//logs.GetLogger().Info(carFileHash)
-
+	fileHash := addResult.Root.Hash
 	if fileHash == constants.EMPTY_STRING {
-		err := fmt.Errorf("cannot get file hash from response:%s", response)
-		//logs.GetLogger().Error(err)
+		err := fmt.Errorf("cannot get file hash from response of:%s", apiUrl)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	return &fileHash, nil
 }
 
+// Export2CarFile exports the dag of fileHash to a car file, see IpfsClient.DagExport
 func Export2CarFile(apiUrl, fileHash string, carFileFullPath string) error {
-	apiUrlFull := utils.UrlJoin(apiUrl, "api/v0/dag/export")
-	apiUrlFull = apiUrlFull + "?arg=" + fileHash + "&progress=false"
-	carFileContent, err := web.HttpPostNoToken(apiUrlFull, "")
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return err
-	}
+	return Export2CarFileWithContext(context.Background(), apiUrl, fileHash, carFileFullPath)
+}
 
-	bytesWritten, err := utils.CreateFileWithByteContents(carFileFullPath, carFileContent)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return err
-	}
-	logs.GetLogger().Info(bytesWritten, " bytes have been written to:", carFileFullPath)
-	return nil
+func Export2CarFileWithContext(ctx context.Context, apiUrl, fileHash string, carFileFullPath string) error {
+	return Export2CarFileWithProgress(ctx, apiUrl, fileHash, carFileFullPath, nil)
+}
+
+func Export2CarFileWithProgress(ctx context.Context, apiUrl, fileHash string, carFileFullPath string, progress ExportProgress) error {
+	return (&IpfsClient{ApiUrl: apiUrl}).DagExport(ctx, fileHash, carFileFullPath, progress)
 }
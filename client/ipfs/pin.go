@@ -0,0 +1,112 @@
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/filswan/go-swan-lib/client/web"
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	PIN_TYPE_ALL       = "all"
+	PIN_TYPE_DIRECT    = "direct"
+	PIN_TYPE_INDIRECT  = "indirect"
+	PIN_TYPE_RECURSIVE = "recursive"
+)
+
+type PinInfo struct {
+	Cid  string
+	Type string
+}
+
+// PinAdd pins a cid, with the dag below it when recursive, and returns the cids pinned
+func (ipfsClient *IpfsClient) PinAdd(ctx context.Context, cid string, recursive bool) ([]string, error) {
+	args := url.Values{
+		"arg":       {cid},
+		"recursive": {strconv.FormatBool(recursive)},
+		"progress":  {"false"},
+	}
+
+	return ipfsClient.pin(ctx, "pin/add", args)
+}
+
+// PinRm removes the pin of a cid and returns the cids unpinned, recursive must match how it was pinned
+func (ipfsClient *IpfsClient) PinRm(ctx context.Context, cid string, recursive bool) ([]string, error) {
+	args := url.Values{
+		"arg":       {cid},
+		"recursive": {strconv.FormatBool(recursive)},
+	}
+
+	return ipfsClient.pin(ctx, "pin/rm", args)
+}
+
+func (ipfsClient *IpfsClient) pin(ctx context.Context, command string, args url.Values) ([]string, error) {
+	pins := []string{}
+	err := ipfsClient.command(ctx, command, args, func(object json.RawMessage) error {
+		result := struct {
+			Pins []string
+		}{}
+		err := json.Unmarshal(object, &result)
+		if err != nil {
+			return err
+		}
+
+		pins = append(pins, result.Pins...)
+		return nil
+	})
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return pins, nil
+}
+
+// PinLs lists the pins of pinType, PIN_TYPE_ALL when empty, of cid or of all the cids when cid is empty.
+// A cid not pinned fails with an error matching web.ErrNotFound.
+func (ipfsClient *IpfsClient) PinLs(ctx context.Context, cid, pinType string) ([]*PinInfo, error) {
+	if pinType == "" {
+		pinType = PIN_TYPE_ALL
+	}
+
+	args := url.Values{
+		"type":   {pinType},
+		"stream": {"true"},
+	}
+	if cid != "" {
+		args.Set("arg", cid)
+	}
+
+	pinInfos := []*PinInfo{}
+	err := ipfsClient.command(web.WithIdempotent(ctx), "pin/ls", args, func(object json.RawMessage) error {
+		// streamed pins are objects of their own, older versions answer all of them in Keys
+		result := struct {
+			PinInfo
+			Keys map[string]struct {
+				Type string
+			}
+		}{}
+		err := json.Unmarshal(object, &result)
+		if err != nil {
+			return err
+		}
+
+		if result.Cid != "" {
+			pinInfos = append(pinInfos, &PinInfo{Cid: result.Cid, Type: result.Type})
+		}
+		for pinCid, pin := range result.Keys {
+			pinInfos = append(pinInfos, &PinInfo{Cid: pinCid, Type: pin.Type})
+		}
+
+		return nil
+	})
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return pinInfos, nil
+}
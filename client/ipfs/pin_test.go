@@ -0,0 +1,65 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/client/web"
+)
+
+const testPinCid = "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+
+func checkCommand(t *testing.T, path string, query url.Values, wantPath string, wantQuery url.Values) {
+	if path != wantPath || query.Encode() != wantQuery.Encode() {
+		t.Fatalf("request: got %s?%s, want %s?%s", path, query.Encode(), wantPath, wantQuery.Encode())
+	}
+}
+
+func TestPinArgs(t *testing.T) {
+	var path string
+	var query url.Values
+	ipfsClient := newTestIpfs(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		query = r.URL.Query()
+		if path == "/api/v0/pin/ls" {
+			fmt.Fprintf(w, `{"Cid":"%s","Type":"recursive"}`+"\n", testPinCid)
+			return
+		}
+		fmt.Fprintf(w, `{"Pins":["%s"]}`+"\n", testPinCid)
+	})
+
+	pins, err := ipfsClient.PinAdd(context.Background(), testPinCid, true)
+	if err != nil || len(pins) != 1 || pins[0] != testPinCid {
+		t.Fatalf("pin/add: %v, %v", pins, err)
+	}
+	checkCommand(t, path, query, "/api/v0/pin/add", url.Values{"arg": {testPinCid}, "recursive": {"true"}, "progress": {"false"}})
+
+	_, err = ipfsClient.PinRm(context.Background(), testPinCid, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCommand(t, path, query, "/api/v0/pin/rm", url.Values{"arg": {testPinCid}, "recursive": {"false"}})
+
+	pinInfos, err := ipfsClient.PinLs(context.Background(), testPinCid, "")
+	if err != nil || len(pinInfos) != 1 || *pinInfos[0] != (PinInfo{Cid: testPinCid, Type: PIN_TYPE_RECURSIVE}) {
+		t.Fatalf("pin/ls: %v, %v", pinInfos, err)
+	}
+	checkCommand(t, path, query, "/api/v0/pin/ls", url.Values{"arg": {testPinCid}, "type": {PIN_TYPE_ALL}, "stream": {"true"}})
+}
+
+func TestPinLsNotPinned(t *testing.T) {
+	ipfsClient := newTestIpfs(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `{"Message":"path '%s' is not pinned","Code":0,"Type":"error"}`+"\n", testPinCid)
+	})
+
+	_, err := ipfsClient.PinLs(context.Background(), testPinCid, PIN_TYPE_RECURSIVE)
+	var ipfsAPIError *IpfsAPIError
+	if !errors.As(err, &ipfsAPIError) || !errors.Is(err, web.ErrNotFound) {
+		t.Fatalf("got %v, want an *IpfsAPIError matching web.ErrNotFound", err)
+	}
+}
@@ -10,7 +10,7 @@ import (
 func LevelDbPut(dbFilepath, key string, value interface{}) error {
 	db, err := leveldb.OpenFile(dbFilepath, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 	defer db.Close()
@@ -19,14 +19,14 @@ func LevelDbPut(dbFilepath, key string, value interface{}) error {
 	switch valType := value.(type) {
 	case string:
 		valStr = valType
-		logs.GetLogger().Info("this is already string")
+		logs.Log().Info("this is already string")
 	default:
 		valStr = utils.ToJson(value)
 	}
 
 	err = db.Put([]byte(key), []byte(valStr), nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -36,14 +36,14 @@ func LevelDbPut(dbFilepath, key string, value interface{}) error {
 func LevelDbGet(dbFilepath, key string) ([]byte, error) {
 	db, err := leveldb.OpenFile(dbFilepath, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	defer db.Close()
 
 	data, err := db.Get([]byte(key), nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -53,14 +53,14 @@ func LevelDbGet(dbFilepath, key string) ([]byte, error) {
 func LevelDbDelete(dbFilepath, key, value string) error {
 	db, err := leveldb.OpenFile(dbFilepath, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 	defer db.Close()
 
 	err = db.Delete([]byte("key"), nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -1,10 +1,9 @@
 package lotus
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 
-	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/logs"
 )
 
@@ -18,9 +17,13 @@ type AuthVerify struct {
 }
 
 func LotusCheckAuth(apiUrl, token, expectedAuth string) (bool, error) {
-	auths, err := LotusAuthVerify(apiUrl, token)
+	return LotusCheckAuthWithContext(context.Background(), apiUrl, token, expectedAuth)
+}
+
+func LotusCheckAuthWithContext(ctx context.Context, apiUrl, token, expectedAuth string) (bool, error) {
+	auths, err := LotusAuthVerifyWithContext(ctx, apiUrl, token)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return false, err
 	}
 	for _, auth := range auths {
@@ -32,45 +35,27 @@ func LotusCheckAuth(apiUrl, token, expectedAuth string) (bool, error) {
 }
 
 func LotusAuthVerify(apiUrl, token string) ([]string, error) {
+	return LotusAuthVerifyWithContext(context.Background(), apiUrl, token)
+}
+
+func LotusAuthVerifyWithContext(ctx context.Context, apiUrl, token string) ([]string, error) {
 	if len(apiUrl) == 0 {
 		err := fmt.Errorf("api url is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	if len(token) == 0 {
 		err := fmt.Errorf("token is required")
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	var params []interface{}
-	params = append(params, token)
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  FILECOIN_AUTH_VERIFY,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
-
-	//here the api url should be miner's api url, need to change later on
-	response, err := web.HttpGetNoToken(apiUrl, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	authVerify := &AuthVerify{}
-	err = json.Unmarshal(response, authVerify)
+	//here the api url should be miner's api url, need to change later on
+	err := NewRpcClient(apiUrl, "").Call(ctx, FILECOIN_AUTH_VERIFY, &authVerify.Result, token)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if authVerify.Error != nil {
-		err := fmt.Errorf("error, code:%d, message:%s", authVerify.Error.Code, authVerify.Error.Message)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
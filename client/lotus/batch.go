@@ -0,0 +1,204 @@
+package lotus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	LOTUS_JSON_RPC_CODE_PARSE_ERROR     = -32700
+	LOTUS_JSON_RPC_CODE_INVALID_REQUEST = -32600
+)
+
+// errBatchRejected means the node does not accept batch requests, the calls are sent one by one instead.
+// The node then executed none of the calls, so they can be resent even when they are not idempotent.
+var errBatchRejected = errors.New("batch request rejected")
+
+// BatchCall is one call of a batch, Err is set after the batch is sent
+type BatchCall struct {
+	Method string
+	Params []interface{}
+	Result interface{} // decoded result, nil means the result is ignored
+	Err    error
+}
+
+// BatchCaller sends several JSON-RPC calls at once
+type BatchCaller interface {
+	CallBatch(ctx context.Context, calls []*BatchCall) error
+}
+
+func NewBatchCall(method string, result interface{}, params ...interface{}) *BatchCall {
+	batchCall := &BatchCall{
+		Method: method,
+		Params: params,
+		Result: result,
+	}
+
+	return batchCall
+}
+
+// CallBatch sends calls as JSON-RPC batches of at most MaxBatchSize calls and sets the Err of each call.
+// When the node rejects batches, the remaining calls are sent one by one. A batch failing otherwise, e.g. on a
+// timeout, is not resent one call at a time: the node may have executed it, each of its calls gets the error.
+// The returned error is only set when ctx is done before all the calls are sent.
+func (rpcClient *RpcClient) CallBatch(ctx context.Context, calls []*BatchCall) error {
+	maxBatchSize := rpcClient.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = LOTUS_JSON_RPC_BATCH_SIZE_DEFAULT
+	}
+
+	batchRejected := false
+	for start := 0; start < len(calls); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		batch := calls[start:end]
+
+		if batchRejected || len(batch) == 1 {
+			callEach(ctx, rpcClient, batch)
+		} else {
+			err := rpcClient.sendBatch(ctx, batch)
+			if errors.Is(err, errBatchRejected) {
+				logs.Log().Info("sending ", len(batch), " calls one by one, ", err)
+				batchRejected = true
+				callEach(ctx, rpcClient, batch)
+			}
+		}
+
+		if ctx.Err() != nil {
+			for _, call := range calls[end:] {
+				call.Err = ctx.Err()
+			}
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (rpcClient *RpcClient) sendBatch(ctx context.Context, calls []*BatchCall) error {
+	if len(rpcClient.ApiUrl) == 0 {
+		err := fmt.Errorf("api url is required")
+		logs.Log().Error(err)
+		setBatchErr(calls, err)
+		return err
+	}
+
+	idempotent := true
+	requests := make([]LotusJsonRpcParams, len(calls))
+	for i, call := range calls {
+		params := call.Params
+		if params == nil {
+			params = []interface{}{}
+		}
+
+		requests[i] = LotusJsonRpcParams{
+			JsonRpc: LOTUS_JSON_RPC_VERSION,
+			Method:  call.Method,
+			Params:  params,
+			Id:      nextRpcId(),
+		}
+		idempotent = idempotent && IsIdempotentMethod(call.Method)
+	}
+
+	httpClient, retryPolicy := rpcClient.httpClient()
+
+	var rpcResps []*rpcResponse
+	err := retryPolicy.Retry(ctx, func(attempt int) (bool, error) {
+		if attempt > 1 {
+			logs.Log().Info("retrying batch of ", len(calls), " calls, attempt:", attempt)
+		}
+
+		response, err := httpClient.Request(ctx, http.MethodPost, rpcClient.ApiUrl, rpcClient.AccessToken, requests)
+		if err != nil {
+			logs.Log().Error(err)
+			if isBatchRejection(err) {
+				return false, fmt.Errorf("%w, %s", errBatchRejected, err.Error())
+			}
+			return idempotent && retryPolicy.IsRetryableError(err), err
+		}
+
+		rpcResps = nil
+		err = json.Unmarshal(response, &rpcResps)
+		if err != nil {
+			return false, fmt.Errorf("%w, response is not an array:%s", errBatchRejected, err.Error())
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		if !errors.Is(err, errBatchRejected) {
+			setBatchErr(calls, err)
+		}
+		return err
+	}
+
+	rpcRespsById := map[int]*rpcResponse{}
+	for _, rpcResp := range rpcResps {
+		if rpcResp != nil {
+			rpcRespsById[rpcResp.Id] = rpcResp
+		}
+	}
+
+	for i, call := range calls {
+		rpcResp, ok := rpcRespsById[requests[i].Id]
+		if !ok {
+			call.Err = fmt.Errorf("%s, no response in batch for request id:%d", call.Method, requests[i].Id)
+			logs.Log().Error(call.Err)
+			continue
+		}
+
+		call.Err = decodeRpcResult(rpcResp, requests[i], call.Result)
+	}
+
+	return nil
+}
+
+// isBatchRejection reports whether err is the http error of a node refusing to parse a batch
+func isBatchRejection(err error) bool {
+	rpcError := statusRpcError(err)
+	if rpcError == nil {
+		return false
+	}
+
+	switch rpcError.Code {
+	case LOTUS_JSON_RPC_CODE_PARSE_ERROR, LOTUS_JSON_RPC_CODE_INVALID_REQUEST:
+		return true
+	}
+
+	return false
+}
+
+func setBatchErr(calls []*BatchCall, err error) {
+	for _, call := range calls {
+		call.Err = err
+	}
+}
+
+func callEach(ctx context.Context, rpcCaller RpcCaller, calls []*BatchCall) {
+	for _, call := range calls {
+		if ctx.Err() != nil {
+			call.Err = ctx.Err()
+			continue
+		}
+
+		call.Err = rpcCaller.Call(ctx, call.Method, call.Result, call.Params...)
+	}
+}
+
+// callBatch sends calls in batches when rpcCaller supports it, one by one otherwise
+func callBatch(ctx context.Context, rpcCaller RpcCaller, calls []*BatchCall) error {
+	batchCaller, ok := rpcCaller.(BatchCaller)
+	if ok {
+		return batchCaller.CallBatch(ctx, calls)
+	}
+
+	callEach(ctx, rpcCaller, calls)
+	return ctx.Err()
+}
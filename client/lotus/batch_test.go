@@ -0,0 +1,145 @@
+package lotus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filswan/go-swan-lib/client/lotus/lotustest"
+	"github.com/filswan/go-swan-lib/client/web"
+)
+
+func newVersionCalls(count int) []*BatchCall {
+	calls := make([]*BatchCall, count)
+	for i := range calls {
+		calls[i] = NewBatchCall(LOTUS_VERSION, &LotusVersionResult{})
+	}
+
+	return calls
+}
+
+func TestCallBatchDoesNotResendNonIdempotentCallsAfterTimeout(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.InjectFault(lotustest.METHOD_WALLET_NEW, lotustest.Fault{Delay: time.Second})
+
+	httpClient, err := web.NewClient(web.ClientConfig{Timeout: 500 * time.Millisecond, RetryPolicy: web.DefaultRetryPolicy()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcClient := NewRpcClient(server.ApiUrl(), "")
+	rpcClient.HttpClient = httpClient
+
+	calls := []*BatchCall{
+		NewBatchCall(LOTUS_WALLET_NEW, new(string), lotustest.KEY_TYPE_SECP256K1),
+		NewBatchCall(LOTUS_WALLET_NEW, new(string), lotustest.KEY_TYPE_SECP256K1),
+	}
+	err = rpcClient.CallBatch(context.Background(), calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, call := range calls {
+		if call.Err == nil {
+			t.Errorf("call %d: timed out batch reported success", i)
+		}
+	}
+
+	// let the server finish the batch it received
+	time.Sleep(2500 * time.Millisecond)
+	if calls := server.Calls(lotustest.METHOD_WALLET_NEW); calls != 2 {
+		t.Fatalf("WalletNew calls: got %d, want 2", calls)
+	}
+	if wallets := len(server.Wallets()); wallets != 2 {
+		t.Fatalf("wallets created: got %d, want 2", wallets)
+	}
+}
+
+func TestCallBatchFallsBackWhenBatchesRejected(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.DisableBatch = true
+
+	rpcClient := NewRpcClient(server.ApiUrl(), "")
+	rpcClient.MaxBatchSize = 2
+	calls := newVersionCalls(5)
+	err := rpcClient.CallBatch(context.Background(), calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, call := range calls {
+		if call.Err != nil {
+			t.Errorf("call %d: %v", i, call.Err)
+		}
+	}
+	if calls := server.Calls(lotustest.METHOD_VERSION); calls != 5 {
+		t.Fatalf("Version calls: got %d, want 5", calls)
+	}
+}
+
+func TestCallBatchKeepsBatchingAfterTransientError(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.InjectFault(lotustest.METHOD_VERSION, lotustest.Fault{HttpStatus: http.StatusServiceUnavailable, Times: 1})
+
+	rpcClient := NewRpcClient(server.ApiUrl(), "")
+	rpcClient.MaxBatchSize = 2
+	calls := newVersionCalls(4)
+	err := rpcClient.CallBatch(context.Background(), calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, call := range calls {
+		if i < 2 && call.Err == nil {
+			t.Errorf("call %d: failed batch reported success", i)
+		}
+		if i >= 2 && call.Err != nil {
+			t.Errorf("call %d: %v", i, call.Err)
+		}
+	}
+	// one call of the failed batch reached the fault, the next batch was sent whole
+	if calls := server.Calls(lotustest.METHOD_VERSION); calls != 3 {
+		t.Fatalf("Version calls: got %d, want 3", calls)
+	}
+}
+
+func TestCallBatchFallsBackOnHttpParseError(t *testing.T) {
+	batches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(string(body), "[") {
+			batches++
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`)
+			return
+		}
+
+		request := LotusJsonRpcParams{}
+		json.Unmarshal(body, &request)
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": request.Id, "result": map[string]string{"Version": "test"}})
+	}))
+	defer server.Close()
+
+	rpcClient := NewRpcClient(server.URL, "")
+	rpcClient.MaxBatchSize = 2
+	calls := newVersionCalls(4)
+	err := rpcClient.CallBatch(context.Background(), calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, call := range calls {
+		if call.Err != nil {
+			t.Errorf("call %d: %v", i, call.Err)
+		}
+	}
+	if batches != 1 {
+		t.Fatalf("batches sent: got %d, want 1", batches)
+	}
+}
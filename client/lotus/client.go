@@ -2,11 +2,13 @@ package lotus
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/constants"
@@ -39,8 +41,17 @@ const (
 )
 
 type LotusClient struct {
-	ApiUrl      string
-	AccessToken string
+	ApiUrl       string
+	AccessToken  string
+	HttpClient   *web.Client      // nil means web.DefaultClient()
+	RetryPolicy  *web.RetryPolicy // nil means the retry policy of HttpClient
+	Transport    RpcCaller        // e.g. a WsClient, nil means JSON-RPC over HTTP
+	MaxBatchSize int              // calls per batch of the bulk helpers, 0 means LOTUS_JSON_RPC_BATCH_SIZE_DEFAULT
+}
+
+type TipSet struct {
+	Cids   []Cid
+	Height int64
 }
 
 type ClientCalcCommP struct {
@@ -62,7 +73,7 @@ type ClientImport struct {
 func LotusGetClient(apiUrl, accessToken string) (*LotusClient, error) {
 	if len(apiUrl) == 0 {
 		err := fmt.Errorf("config lotus api_url is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -74,6 +85,18 @@ func LotusGetClient(apiUrl, accessToken string) (*LotusClient, error) {
 	return lotusClient, nil
 }
 
+func (lotusClient *LotusClient) rpcClient() RpcCaller {
+	if lotusClient.Transport != nil {
+		return lotusClient.Transport
+	}
+
+	rpcClient := NewRpcClient(lotusClient.ApiUrl, lotusClient.AccessToken)
+	rpcClient.HttpClient = lotusClient.HttpClient
+	rpcClient.RetryPolicy = lotusClient.RetryPolicy
+	rpcClient.MaxBatchSize = lotusClient.MaxBatchSize
+	return rpcClient
+}
+
 type ClientMinerQuery struct {
 	LotusJsonRpcResult
 	Result struct {
@@ -120,40 +143,40 @@ type ClientDealCostStatus struct {
 }
 
 func (lotusClient *LotusClient) LotusClientGetDealInfo(dealCid string) (*ClientDealCostStatus, error) {
-	var params []interface{}
-	cid := Cid{Cid: dealCid}
-	params = append(params, cid)
+	return lotusClient.LotusClientGetDealInfoWithContext(context.Background(), dealCid)
+}
 
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CLIENT_GET_DEAL_INFO,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
+func (lotusClient *LotusClient) LotusClientGetDealInfoWithContext(ctx context.Context, dealCid string) (*ClientDealCostStatus, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_DEAL_CID: dealCid})
 
-	response, err := web.HttpGetNoToken(lotusClient.ApiUrl, jsonRpcParams)
+	clientDealInfo := &ClientDealInfo{}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_CLIENT_GET_DEAL_INFO, &clientDealInfo.Result, Cid{Cid: dealCid})
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
-	clientDealInfo := &ClientDealInfo{}
-	err = json.Unmarshal(response, clientDealInfo)
+	clientDealCostStatus, err := newClientDealCostStatus(dealCid, clientDealInfo)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
-	if clientDealInfo.Error != nil {
-		err := fmt.Errorf("deal:%s,code:%d,message:%s", dealCid, clientDealInfo.Error.Code, clientDealInfo.Error.Message)
-		logs.GetLogger().Error(err)
+	dealStatus, err := lotusClient.LotusGetDealStatusWithContext(ctx, clientDealInfo.Result.State)
+	if err != nil {
+		logger.Error(err)
 		return nil, err
 	}
+	clientDealCostStatus.Status = *dealStatus
+
+	return clientDealCostStatus, nil
+}
 
+// newClientDealCostStatus fills everything but Status
+func newClientDealCostStatus(dealCid string, clientDealInfo *ClientDealInfo) (*ClientDealCostStatus, error) {
 	pricePerEpoch, err := decimal.NewFromString(clientDealInfo.Result.PricePerEpoch)
 	if err != nil {
 		err := fmt.Errorf("deal:%s,%s", dealCid, err.Error())
-		logs.GetLogger().Error(err)
 		return nil, err
 	}
 	duration := decimal.NewFromInt(int64(clientDealInfo.Result.Duration))
@@ -181,13 +204,6 @@ func (lotusClient *LotusClient) LotusClientGetDealInfo(dealCid string) (*ClientD
 		}
 	}
 
-	dealStatus, err := lotusClient.LotusGetDealStatus(clientDealInfo.Result.State)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	clientDealCostStatus.Status = *dealStatus
 	clientDealCostStatus.Message = clientDealInfo.Result.Message
 	clientDealCostStatus.DealId = clientDealInfo.Result.DealID
 	clientDealCostStatus.Verified = clientDealInfo.Result.Verified
@@ -195,6 +211,88 @@ func (lotusClient *LotusClient) LotusClientGetDealInfo(dealCid string) (*ClientD
 	return &clientDealCostStatus, nil
 }
 
+type DealInfoResult struct {
+	DealCid  string
+	DealInfo *ClientDealCostStatus
+	Err      error
+}
+
+// GetDealInfos is LotusClientGetDealInfo for many deals, using JSON-RPC batches.
+// The error of each deal is in its result, the returned error is only set when ctx is done.
+func (lotusClient *LotusClient) GetDealInfos(dealCids []string) ([]DealInfoResult, error) {
+	return lotusClient.GetDealInfosWithContext(context.Background(), dealCids)
+}
+
+func (lotusClient *LotusClient) GetDealInfosWithContext(ctx context.Context, dealCids []string) ([]DealInfoResult, error) {
+	rpcClient := lotusClient.rpcClient()
+
+	clientDealInfos := make([]*ClientDealInfo, len(dealCids))
+	calls := make([]*BatchCall, len(dealCids))
+	for i, dealCid := range dealCids {
+		clientDealInfos[i] = &ClientDealInfo{}
+		calls[i] = NewBatchCall(LOTUS_CLIENT_GET_DEAL_INFO, &clientDealInfos[i].Result, Cid{Cid: dealCid})
+	}
+
+	err := callBatch(ctx, rpcClient, calls)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	// the status names come from StorageDealStatus, lotus is asked once per distinct state it does not know
+	dealStatuses := map[int]*string{}
+	statusCalls := []*BatchCall{}
+	for i, call := range calls {
+		state := clientDealInfos[i].Result.State
+		if call.Err != nil || dealStatuses[state] != nil {
+			continue
+		}
+
+		dealStatuses[state] = new(string)
+		if state >= 0 && StorageDealStatus(state).IsKnown() {
+			*dealStatuses[state] = StorageDealStatus(state).String()
+			continue
+		}
+		statusCalls = append(statusCalls, NewBatchCall(LOTUS_CLIENT_GET_DEAL_STATUS, dealStatuses[state], state))
+	}
+
+	err = callBatch(ctx, rpcClient, statusCalls)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	statusErrs := map[int]error{}
+	for _, statusCall := range statusCalls {
+		statusErrs[statusCall.Params[0].(int)] = statusCall.Err
+	}
+
+	dealInfoResults := make([]DealInfoResult, len(dealCids))
+	for i, dealCid := range dealCids {
+		dealInfoResults[i].DealCid = dealCid
+		if calls[i].Err != nil {
+			dealInfoResults[i].Err = fmt.Errorf("deal:%s,%w", dealCid, calls[i].Err)
+			continue
+		}
+
+		state := clientDealInfos[i].Result.State
+		if statusErrs[state] != nil {
+			dealInfoResults[i].Err = fmt.Errorf("deal:%s,%w", dealCid, statusErrs[state])
+			continue
+		}
+
+		clientDealCostStatus, err := newClientDealCostStatus(dealCid, clientDealInfos[i])
+		if err != nil {
+			dealInfoResults[i].Err = err
+			continue
+		}
+		clientDealCostStatus.Status = *dealStatuses[state]
+		dealInfoResults[i].DealInfo = clientDealCostStatus
+	}
+
+	return dealInfoResults, nil
+}
+
 func GetDealCost(dealCost ClientDealCostStatus) string {
 	if dealCost.DealProposalAccepted != "" {
 		return dealCost.DealProposalAccepted
@@ -208,36 +306,19 @@ func GetDealCost(dealCost ClientDealCostStatus) string {
 }
 
 func (lotusClient *LotusClient) LotusClientMinerQuery(minerFid string) (*string, error) {
-	var params []interface{}
-	params = append(params, minerFid)
-	params = append(params, nil)
-	params = append(params, nil)
+	return lotusClient.LotusClientMinerQueryWithContext(context.Background(), minerFid)
+}
 
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CLIENT_MINER_QUERY,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
+func (lotusClient *LotusClient) LotusClientMinerQueryWithContext(ctx context.Context, minerFid string) (*string, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MINER_FID: minerFid})
 
-	timeOutSecond := constants.HTTP_API_TIMEOUT_SECOND
-	response, err := web.HttpGetNoTokenTimeout(lotusClient.ApiUrl, jsonRpcParams, &timeOutSecond)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
+	ctx, cancel := context.WithTimeout(ctx, constants.HTTP_API_TIMEOUT_SECOND*time.Second)
+	defer cancel()
 
 	clientMinerQuery := &ClientMinerQuery{}
-	err = json.Unmarshal(response, clientMinerQuery)
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_CLIENT_MINER_QUERY, &clientMinerQuery.Result, minerFid, nil, nil)
 	if err != nil {
-		err := fmt.Errorf("miner:%s,%s", minerFid, err.Error())
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if clientMinerQuery.Error != nil {
-		err := fmt.Errorf("miner:%s,code:%d,message:%s", minerFid, clientMinerQuery.Error.Code, clientMinerQuery.Error.Message)
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
@@ -263,52 +344,37 @@ type MinerConfig struct {
 }
 
 func (lotusClient *LotusClient) LotusClientQueryAsk(minerFid string) (*MinerConfig, error) {
-	minerPeerId, err := lotusClient.LotusClientMinerQuery(minerFid)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	var params []interface{}
-	params = append(params, minerPeerId)
-	params = append(params, minerFid)
+	return lotusClient.LotusClientQueryAskWithContext(context.Background(), minerFid)
+}
 
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CLIENT_QUERY_ASK,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
+func (lotusClient *LotusClient) LotusClientQueryAskWithContext(ctx context.Context, minerFid string) (*MinerConfig, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MINER_FID: minerFid})
 
-	timeOutSecond := constants.HTTP_API_TIMEOUT_SECOND
-	response, err := web.HttpGetNoTokenTimeout(lotusClient.ApiUrl, jsonRpcParams, &timeOutSecond)
+	minerPeerId, err := lotusClient.LotusClientMinerQueryWithContext(ctx, minerFid)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, constants.HTTP_API_TIMEOUT_SECOND*time.Second)
+	defer cancel()
+
 	clientQueryAsk := &ClientQueryAsk{}
-	err = json.Unmarshal(response, clientQueryAsk)
+	err = lotusClient.rpcClient().Call(ctx, LOTUS_CLIENT_QUERY_ASK, &clientQueryAsk.Result, minerPeerId, minerFid)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if clientQueryAsk.Error != nil {
-		err := fmt.Errorf("miner:%s,code:%d,message:%s", minerFid, clientQueryAsk.Error.Code, clientQueryAsk.Error.Message)
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	price, err := decimal.NewFromString(clientQueryAsk.Result.Price)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	verifiedPrice, err := decimal.NewFromString(clientQueryAsk.Result.VerifiedPrice)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
@@ -323,101 +389,46 @@ func (lotusClient *LotusClient) LotusClientQueryAsk(minerFid string) (*MinerConf
 }
 
 func (lotusClient *LotusClient) LotusGetCurrentEpoch() (*int64, error) {
-	var params []interface{}
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CHAIN_HEAD,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
+	return lotusClient.LotusGetCurrentEpochWithContext(context.Background())
+}
 
-	response, err := web.HttpPostNoToken(lotusClient.ApiUrl, jsonRpcParams)
+func (lotusClient *LotusClient) LotusGetCurrentEpochWithContext(ctx context.Context) (*int64, error) {
+	tipSet := &TipSet{}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_CHAIN_HEAD, tipSet)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	result := utils.GetFieldMapFromJson(response, "result")
-	if result == nil {
-		err := fmt.Errorf("failed to get result from:%s", lotusClient.ApiUrl)
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	height := result["Height"]
-	if height == nil {
-		err := fmt.Errorf("failed to get height from:%s", lotusClient.ApiUrl)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
-	heightFloat := height.(float64)
-	heightInt64 := int64(heightFloat)
-	return &heightInt64, nil
+	return &tipSet.Height, nil
 }
 
 // "lotus-miner storage-deals list -v | grep -a " + dealCid
+// the name comes from StorageDealStatus, lotus is asked only for a state it does not know
 func (lotusClient *LotusClient) LotusGetDealStatus(state int) (*string, error) {
-	var params []interface{}
-	params = append(params, state)
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CLIENT_GET_DEAL_STATUS,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
+	return lotusClient.LotusGetDealStatusWithContext(context.Background(), state)
+}
 
-	response, err := web.HttpPostNoToken(lotusClient.ApiUrl, jsonRpcParams)
+func (lotusClient *LotusClient) LotusGetDealStatusWithContext(ctx context.Context, state int) (*string, error) {
+	result, err := dealStatusName(ctx, lotusClient.rpcClient(), state)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	result := utils.GetFieldStrFromJson(response, "result")
-	if result == "" {
-		logs.GetLogger().Error("no response from:", lotusClient.ApiUrl)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
-	return &result, nil
+	return result, nil
 }
 
 // "lotus client commP " + carFilePath
 func (lotusClient *LotusClient) LotusClientCalcCommP(filepath string) (*string, error) {
-	var params []interface{}
-	params = append(params, filepath)
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CLIENT_CALC_COMM_P,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
-
-	response, err := web.HttpPost(lotusClient.ApiUrl, lotusClient.AccessToken, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
+	return lotusClient.LotusClientCalcCommPWithContext(context.Background(), filepath)
+}
 
+func (lotusClient *LotusClient) LotusClientCalcCommPWithContext(ctx context.Context, filepath string) (*string, error) {
 	clientCalcCommP := &ClientCalcCommP{}
-	err = json.Unmarshal(response, clientCalcCommP)
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_CLIENT_CALC_COMM_P, &clientCalcCommP.Result, filepath)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if clientCalcCommP.Error != nil {
-		err := fmt.Errorf("get piece CID failed for:%s, error code:%d, message:%s", filepath, clientCalcCommP.Error.Code, clientCalcCommP.Error.Message)
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if clientCalcCommP.Result == nil {
-		err := fmt.Errorf("no result from:%s", lotusClient.ApiUrl)
-		logs.GetLogger().Error()
+		logs.Log().Error("get piece CID failed for:", filepath, ",", err)
 		return nil, err
 	}
 
@@ -432,42 +443,19 @@ type ClientFileParam struct {
 
 // "lotus client import --car " + carFilePath
 func (lotusClient *LotusClient) LotusClientImport(filepath string, isCar bool) (*string, error) {
-	var params []interface{}
+	return lotusClient.LotusClientImportWithContext(context.Background(), filepath, isCar)
+}
+
+func (lotusClient *LotusClient) LotusClientImportWithContext(ctx context.Context, filepath string, isCar bool) (*string, error) {
 	clientFileParam := ClientFileParam{
 		Path:  filepath,
 		IsCAR: isCar,
 	}
-	params = append(params, clientFileParam)
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CLIENT_IMPORT,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
-
-	response, err := web.HttpGet(lotusClient.ApiUrl, lotusClient.AccessToken, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
 
 	clientImport := &ClientImport{}
-	err = json.Unmarshal(response, clientImport)
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_CLIENT_IMPORT, &clientImport.Result, clientFileParam)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if clientImport.Error != nil {
-		err := fmt.Errorf("lotus import file %s failed, error code:%d, message:%s", filepath, clientImport.Error.Code, clientImport.Error.Message)
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if clientImport.Result == nil {
-		err := fmt.Errorf("lotus import file %s failed, result is null from %s", filepath, lotusClient.ApiUrl)
-		logs.GetLogger().Error(err)
+		logs.Log().Error("lotus import file ", filepath, " failed,", err)
 		return nil, err
 	}
 
@@ -478,38 +466,18 @@ func (lotusClient *LotusClient) LotusClientImport(filepath string, isCar bool) (
 
 // "lotus client generate-car " + srcFilePath + " " + destCarFilePath
 func (lotusClient *LotusClient) LotusClientGenCar(srcFilePath, destCarFilePath string, srcFilePathIsCar bool) error {
-	var params []interface{}
+	return lotusClient.LotusClientGenCarWithContext(context.Background(), srcFilePath, destCarFilePath, srcFilePathIsCar)
+}
+
+func (lotusClient *LotusClient) LotusClientGenCarWithContext(ctx context.Context, srcFilePath, destCarFilePath string, srcFilePathIsCar bool) error {
 	clientFileParam := ClientFileParam{
 		Path:  srcFilePath,
 		IsCAR: srcFilePathIsCar,
 	}
-	params = append(params, clientFileParam)
-	params = append(params, destCarFilePath)
 
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CLIENT_GEN_CAR,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
-
-	response, err := web.HttpGet(lotusClient.ApiUrl, lotusClient.AccessToken, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return err
-	}
-
-	//logs.GetLogger().Info(response)
-	lotusJsonRpcResult := &LotusJsonRpcResult{}
-	err = json.Unmarshal(response, lotusJsonRpcResult)
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_CLIENT_GEN_CAR, nil, clientFileParam, destCarFilePath)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return err
-	}
-
-	if lotusJsonRpcResult.Error != nil {
-		err := fmt.Errorf("error, code:%d, message:%s", lotusJsonRpcResult.Error.Code, lotusJsonRpcResult.Error.Message)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -540,15 +508,19 @@ type ClientStartDeal struct {
 }
 
 func (lotusClient *LotusClient) CheckDuration(duration int, startEpoch int64) error {
+	return lotusClient.CheckDurationWithContext(context.Background(), duration, startEpoch)
+}
+
+func (lotusClient *LotusClient) CheckDurationWithContext(ctx context.Context, duration int, startEpoch int64) error {
 	if duration < constants.DURATION_MIN || duration > constants.DURATION_MAX {
 		err := fmt.Errorf("deal duration out of bounds (min, max, provided): %d, %d, %d", constants.DURATION_MIN, constants.DURATION_MAX, duration)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
-	currentEpoch, err := lotusClient.LotusGetCurrentEpoch()
+	currentEpoch, err := lotusClient.LotusGetCurrentEpochWithContext(ctx)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 	endEpoch := startEpoch + (int64)(duration)
@@ -556,7 +528,7 @@ func (lotusClient *LotusClient) CheckDuration(duration int, startEpoch int64) er
 	epoch2EndfromNow := endEpoch - *currentEpoch
 	if epoch2EndfromNow >= constants.DURATION_MAX {
 		err := fmt.Errorf("invalid deal end epoch %d: cannot be more than %d past current epoch %d", endEpoch, constants.DURATION_MAX, *currentEpoch)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -564,27 +536,31 @@ func (lotusClient *LotusClient) CheckDuration(duration int, startEpoch int64) er
 }
 
 func (lotusClient *LotusClient) CheckDealConfig(dealConfig *model.DealConfig) (*decimal.Decimal, error) {
+	return lotusClient.CheckDealConfigWithContext(context.Background(), dealConfig)
+}
+
+func (lotusClient *LotusClient) CheckDealConfigWithContext(ctx context.Context, dealConfig *model.DealConfig) (*decimal.Decimal, error) {
 	if dealConfig == nil {
 		err := fmt.Errorf("parameter dealConfig is nil")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	if dealConfig.SenderWallet == "" {
 		err := fmt.Errorf("wallet should be set")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
-	minerConfig, err := lotusClient.LotusClientQueryAsk(dealConfig.MinerFid)
+	minerConfig, err := lotusClient.LotusClientQueryAskWithContext(ctx, dealConfig.MinerFid)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	if dealConfig.FileSize < minerConfig.MinPieceSize || dealConfig.FileSize > minerConfig.MaxPieceSize {
 		err := fmt.Errorf("payload cid:%s, file size:%d is outside of miner:%s's range:[%d,%d]", dealConfig.PayloadCid, dealConfig.FileSize, dealConfig.MinerFid, minerConfig.MinPieceSize, minerConfig.MaxPieceSize)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -596,12 +572,12 @@ func (lotusClient *LotusClient) CheckDealConfig(dealConfig *model.DealConfig) (*
 	} else {
 		minerPrice = minerConfig.Price.Div(e18)
 	}
-	logs.GetLogger().Info("miner: ", dealConfig.MinerFid, ", price: ", minerPrice)
+	logs.Log().Info("miner: ", dealConfig.MinerFid, ", price: ", minerPrice)
 
 	priceCmp := dealConfig.MaxPrice.Cmp(minerPrice)
 	if priceCmp < 0 {
 		err := fmt.Errorf("miner price:%s > deal max price:%s", minerPrice.String(), dealConfig.MaxPrice.String())
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -609,9 +585,9 @@ func (lotusClient *LotusClient) CheckDealConfig(dealConfig *model.DealConfig) (*
 		dealConfig.Duration = constants.DURATION_DEFAULT
 	}
 
-	err = lotusClient.CheckDuration(dealConfig.Duration, dealConfig.StartEpoch)
+	err = lotusClient.CheckDurationWithContext(ctx, dealConfig.Duration, dealConfig.StartEpoch)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -620,33 +596,51 @@ func (lotusClient *LotusClient) CheckDealConfig(dealConfig *model.DealConfig) (*
 
 // LotusClientStartDeal starts deal after check config
 func (lotusClient *LotusClient) LotusClientStartDeal(dealConfig *model.DealConfig) (*string, error) {
-	minerPrice, err := lotusClient.CheckDealConfig(dealConfig)
+	return lotusClient.LotusClientStartDealWithContext(context.Background(), dealConfig)
+}
+
+func (lotusClient *LotusClient) LotusClientStartDealWithContext(ctx context.Context, dealConfig *model.DealConfig) (*string, error) {
+	minerPrice, err := lotusClient.CheckDealConfigWithContext(ctx, dealConfig)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	pieceSize, sectorSize := utils.CalculatePieceSize(dealConfig.FileSize, false)
 	cost := utils.CalculateRealCost(sectorSize, *minerPrice)
 	epochPrice := cost.Mul(decimal.NewFromFloat(constants.LOTUS_PRICE_MULTIPLE_1E18))
-	return lotusClient.StartDeal(pieceSize, *epochPrice.BigInt(), dealConfig)
+
+	// fail before proposing rather than at the StorageDealReserveClientFunds stage
+	_, err = lotusClient.CheckDealEscrowWithContext(ctx, dealConfig.SenderWallet, *epochPrice.BigInt(), dealConfig.Duration)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return lotusClient.StartDealWithContext(ctx, pieceSize, *epochPrice.BigInt(), dealConfig)
 }
 
 // LotusClientStartDeal starts deal with config
 func (lotusClient *LotusClient) StartDeal(pieceSize int64, epochPrice big.Int, dealConfig *model.DealConfig) (*string, error) {
+	return lotusClient.StartDealWithContext(context.Background(), pieceSize, epochPrice, dealConfig)
+}
+
+func (lotusClient *LotusClient) StartDealWithContext(ctx context.Context, pieceSize int64, epochPrice big.Int, dealConfig *model.DealConfig) (*string, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MINER_FID: dealConfig.MinerFid})
+
 	if !dealConfig.SkipConfirmation {
-		logs.GetLogger().Info("Do you confirm to submit the deal?")
-		logs.GetLogger().Info("Press Y/y to continue, other key to quit")
+		logger.Info("Do you confirm to submit the deal?")
+		logger.Info("Press Y/y to continue, other key to quit")
 		reader := bufio.NewReader(os.Stdin)
 		response, err := reader.ReadString('\n')
 		if err != nil {
-			logs.GetLogger().Error(err)
+			logger.Error(err)
 			return nil, err
 		}
 
 		response = strings.TrimRight(response, "\n")
 
 		if !strings.EqualFold(response, "Y") {
-			logs.GetLogger().Info("Your input is ", response, ". Now give up submit the deal.")
+			logger.Info("Your input is ", response, ". Now give up submit the deal.")
 			return nil, nil
 		}
 	}
@@ -678,32 +672,10 @@ func (lotusClient *LotusClient) StartDeal(pieceSize int64, epochPrice big.Int, d
 		VerifiedDeal:      dealConfig.VerifiedDeal,
 	}
 
-	var params []interface{}
-	params = append(params, clientStartDealParam)
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_CLIENT_START_DEAL,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
-
-	response, err := web.HttpGet(lotusClient.ApiUrl, lotusClient.AccessToken, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
 	clientStartDeal := &ClientStartDeal{}
-	err = json.Unmarshal([]byte(response), clientStartDeal)
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_CLIENT_START_DEAL, &clientStartDeal.Result, clientStartDealParam)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if clientStartDeal.Error != nil {
-		err := fmt.Errorf("error, code:%d, message:%s", clientStartDeal.Error.Code, clientStartDeal.Error.Message)
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
@@ -711,66 +683,121 @@ func (lotusClient *LotusClient) StartDeal(pieceSize int64, epochPrice big.Int, d
 }
 
 func (lotusClient *LotusClient) LotusGetDealById(dealId uint64) (*DealInfo, error) {
-	var params []interface{}
-	params = append(params, dealId)
-	params = append(params, []interface{}{})
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_STATE_STORAGE_DEAL,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
+	return lotusClient.LotusGetDealByIdWithContext(context.Background(), dealId)
+}
 
-	response, err := web.HttpGet(lotusClient.ApiUrl, lotusClient.AccessToken, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
+func (lotusClient *LotusClient) LotusGetDealByIdWithContext(ctx context.Context, dealId uint64) (*DealInfo, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_DEAL_ID: dealId})
 
 	deal := &MarketStorageDeal{}
-	err = json.Unmarshal(response, deal)
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_STATE_STORAGE_DEAL, &deal.Result, dealId, []interface{}{})
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	return &deal.Result, nil
 }
 
+// a claim that does not exist is returned with an empty result
 func (lotusClient *LotusClient) LotusStateClaim(minerFid string, claimId uint64) (*ClaimInfo, error) {
-	var params []interface{}
-	params = append(params, minerFid)
-	params = append(params, claimId)
-	params = append(params, nil)
+	return lotusClient.LotusStateClaimWithContext(context.Background(), minerFid, claimId)
+}
 
-	jsonRpcParams := LotusJsonRpcParams{
+func (lotusClient *LotusClient) LotusStateClaimWithContext(ctx context.Context, minerFid string, claimId uint64) (*ClaimInfo, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MINER_FID: minerFid})
+
+	ctx, cancel := context.WithTimeout(ctx, constants.HTTP_API_TIMEOUT_SECOND*time.Second)
+	defer cancel()
+
+	claimInfo := &ClaimInfo{
 		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_STATE_CLAIM,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
+	}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_STATE_CLAIM, &claimInfo.Result, minerFid, claimId, nil)
+	if err != nil && !errors.Is(err, ErrNilResult) {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return claimInfo, nil
+}
+
+type DealByIdResult struct {
+	DealId uint64
+	Deal   *DealInfo
+	Err    error
+}
+
+// GetDealsById is LotusGetDealById for many deals, using JSON-RPC batches.
+// The error of each deal is in its result, the returned error is only set when ctx is done.
+func (lotusClient *LotusClient) GetDealsById(dealIds []uint64) ([]DealByIdResult, error) {
+	return lotusClient.GetDealsByIdWithContext(context.Background(), dealIds)
+}
+
+func (lotusClient *LotusClient) GetDealsByIdWithContext(ctx context.Context, dealIds []uint64) ([]DealByIdResult, error) {
+	dealByIdResults := make([]DealByIdResult, len(dealIds))
+	calls := make([]*BatchCall, len(dealIds))
+	for i, dealId := range dealIds {
+		dealByIdResults[i].DealId = dealId
+		dealByIdResults[i].Deal = &DealInfo{}
+		calls[i] = NewBatchCall(LOTUS_STATE_STORAGE_DEAL, dealByIdResults[i].Deal, dealId, []interface{}{})
 	}
 
-	timeOutSecond := constants.HTTP_API_TIMEOUT_SECOND
-	response, err := web.HttpGetNoTokenTimeout(lotusClient.ApiUrl, jsonRpcParams, &timeOutSecond)
+	err := callBatch(ctx, lotusClient.rpcClient(), calls)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
-	claimInfo := &ClaimInfo{}
-	err = json.Unmarshal(response, claimInfo)
+	for i, call := range calls {
+		if call.Err != nil {
+			dealByIdResults[i].Deal = nil
+			dealByIdResults[i].Err = fmt.Errorf("deal id:%d,%w", dealIds[i], call.Err)
+		}
+	}
+
+	return dealByIdResults, nil
+}
+
+type ClaimResult struct {
+	ClaimId uint64
+	Claim   *ClaimInfo
+	Err     error
+}
+
+// GetClaims is LotusStateClaim for many claims of a miner, using JSON-RPC batches.
+// The error of each claim is in its result, the returned error is only set when ctx is done.
+func (lotusClient *LotusClient) GetClaims(minerFid string, claimIds []uint64) ([]ClaimResult, error) {
+	return lotusClient.GetClaimsWithContext(context.Background(), minerFid, claimIds)
+}
+
+func (lotusClient *LotusClient) GetClaimsWithContext(ctx context.Context, minerFid string, claimIds []uint64) ([]ClaimResult, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MINER_FID: minerFid})
+
+	claimResults := make([]ClaimResult, len(claimIds))
+	calls := make([]*BatchCall, len(claimIds))
+	for i, claimId := range claimIds {
+		claimResults[i].ClaimId = claimId
+		claimResults[i].Claim = &ClaimInfo{
+			JsonRpc: LOTUS_JSON_RPC_VERSION,
+		}
+		calls[i] = NewBatchCall(LOTUS_STATE_CLAIM, &claimResults[i].Claim.Result, minerFid, claimId, nil)
+	}
+
+	err := callBatch(ctx, lotusClient.rpcClient(), calls)
 	if err != nil {
-		err := fmt.Errorf("miner:%s,%s", minerFid, err.Error())
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
-	if claimInfo.Error != nil {
-		err := fmt.Errorf("miner:%s,code:%d,message:%s", minerFid, claimInfo.Error.Code, claimInfo.Error.Message)
-		logs.GetLogger().Error(err)
-		return nil, err
+	for i, call := range calls {
+		if call.Err != nil && !errors.Is(call.Err, ErrNilResult) {
+			claimResults[i].Claim = nil
+			claimResults[i].Err = fmt.Errorf("miner:%s,claim id:%d,%w", minerFid, claimIds[i], call.Err)
+		}
 	}
-	return claimInfo, nil
+
+	return claimResults, nil
 }
 
 type ClaimInfo struct {
@@ -788,14 +815,14 @@ type ClaimInfo struct {
 		TermStart int64  `json:"TermStart"`
 		Sector    uint64 `json:"Sector"`
 	} `json:"result"`
-	Error *JsonRpcError `json:"error"`
+	Error *RPCError `json:"error"`
 }
 
 type MarketStorageDeal struct {
-	Id      int           `json:"id"`
-	JsonRpc string        `json:"jsonrpc"`
-	Result  DealInfo      `json:"result"`
-	Error   *JsonRpcError `json:"error"`
+	Id      int       `json:"id"`
+	JsonRpc string    `json:"jsonrpc"`
+	Result  DealInfo  `json:"result"`
+	Error   *RPCError `json:"error"`
 }
 
 type DealInfo struct {
@@ -0,0 +1,42 @@
+package lotus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/client/lotus/lotustest"
+	"github.com/filswan/go-swan-lib/client/web"
+)
+
+func TestGetDealInfos(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.SetClientDeal(lotustest.ClientDeal{ProposalCid: "bafyactive", State: int(STORAGE_DEAL_ACTIVE), PricePerEpoch: "10", Duration: 100, DealID: 7})
+	server.SetClientDeal(lotustest.ClientDeal{ProposalCid: "bafysealing", State: int(STORAGE_DEAL_SEALING), PricePerEpoch: "0", Duration: 100, Verified: true})
+
+	dealInfoResults, err := newTestLotusClient(t, server, "").GetDealInfos([]string{"bafyactive", "bafymissing", "bafysealing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dealInfoResults) != 3 {
+		t.Fatalf("results: got %d, want 3", len(dealInfoResults))
+	}
+
+	active := dealInfoResults[0]
+	if active.Err != nil || active.DealInfo.Status != "StorageDealActive" || active.DealInfo.CostComputed != "1000" || active.DealInfo.DealId != 7 {
+		t.Fatalf("active deal: %+v, %v", active.DealInfo, active.Err)
+	}
+
+	if !errors.Is(dealInfoResults[1].Err, web.ErrNotFound) {
+		t.Fatalf("missing deal: got %v, want web.ErrNotFound", dealInfoResults[1].Err)
+	}
+
+	sealing := dealInfoResults[2]
+	if sealing.Err != nil || sealing.DealInfo.Status != "StorageDealSealing" || !sealing.DealInfo.Verified {
+		t.Fatalf("sealing deal: %+v, %v", sealing.DealInfo, sealing.Err)
+	}
+
+	if calls := server.Calls(lotustest.METHOD_CLIENT_GET_DEAL_STATUS); calls != 0 {
+		t.Fatalf("ClientGetDealStatus calls for known states: got %d, want 0", calls)
+	}
+}
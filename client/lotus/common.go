@@ -1,11 +1,8 @@
 package lotus
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
 
-	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/logs"
 )
 
@@ -22,16 +19,21 @@ type LotusJsonRpcParams struct {
 }
 
 type LotusJsonRpcResult struct {
-	Id      int           `json:"id"`
-	JsonRpc string        `json:"jsonrpc"`
-	Error   *JsonRpcError `json:"error"`
+	Id      int       `json:"id"`
+	JsonRpc string    `json:"jsonrpc"`
+	Error   *RPCError `json:"error"`
 }
 
-type JsonRpcError struct {
+// RPCError is an error returned by the lotus node, see RPCError.Is for the sentinel errors it matches
+type RPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	Method  string `json:"-"`
 }
 
+// Deprecated: use RPCError
+type JsonRpcError = RPCError
+
 type Cid struct {
 	Cid string `json:"/"`
 }
@@ -54,33 +56,15 @@ type LotusVersionResponse struct {
 //when using lotus node api url it returns version of lotus node
 //when using lotus miner api url it returns version of lotus miner
 func LotusVersion(apiUrl string) (*string, error) {
-	var params []interface{}
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_VERSION,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
-
-	//here the api url should be miner's api url, need to change later on
-	response, err := web.HttpGetNoToken(apiUrl, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
+	return LotusVersionWithContext(context.Background(), apiUrl)
+}
 
+func LotusVersionWithContext(ctx context.Context, apiUrl string) (*string, error) {
 	lotusVersionResponse := &LotusVersionResponse{}
-	err = json.Unmarshal(response, lotusVersionResponse)
+	//here the api url should be miner's api url, need to change later on
+	err := NewRpcClient(apiUrl, "").Call(ctx, LOTUS_VERSION, &lotusVersionResponse.Result)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if lotusVersionResponse.Error != nil {
-		msg := fmt.Sprintf("error, code:%d, message:%s", lotusVersionResponse.Error.Code, lotusVersionResponse.Error.Message)
-		err := errors.New(msg)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -0,0 +1,111 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/filswan/go-swan-lib/commp"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/filswan/go-swan-lib/model"
+)
+
+const (
+	LOTUS_STATE_VERIFIED_CLIENT_STATUS = "Filecoin.StateVerifiedClientStatus"
+)
+
+// ErrInsufficientDatacap is returned when the datacap of a wallet does not cover the verified deals planned
+var ErrInsufficientDatacap = errors.New("insufficient datacap")
+
+// LotusStateVerifiedClientStatus returns the remaining datacap of wallet in bytes, 0 when it is not a verified client
+func (lotusClient *LotusClient) LotusStateVerifiedClientStatus(wallet string) (*big.Int, error) {
+	return lotusClient.LotusStateVerifiedClientStatusWithContext(context.Background(), wallet)
+}
+
+func (lotusClient *LotusClient) LotusStateVerifiedClientStatusWithContext(ctx context.Context, wallet string) (*big.Int, error) {
+	wallet = strings.Trim(wallet, " ")
+	if wallet == "" {
+		err := fmt.Errorf("invalid wallet")
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	// lotus answers null for a wallet without datacap
+	var result string
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_STATE_VERIFIED_CLIENT_STATUS, &result, wallet, []interface{}{})
+	if err != nil {
+		if errors.Is(err, ErrNilResult) {
+			return big.NewInt(0), nil
+		}
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	datacap, ok := new(big.Int).SetString(result, 10)
+	if !ok {
+		err := fmt.Errorf("invalid datacap:%s of wallet:%s", result, wallet)
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return datacap, nil
+}
+
+// DatacapRequired returns the datacap used by the verified deals of dealConfigs, the size of their padded pieces,
+// the others use none
+func DatacapRequired(dealConfigs []*model.DealConfig) *big.Int {
+	datacap := big.NewInt(0)
+	for _, dealConfig := range dealConfigs {
+		if dealConfig != nil && dealConfig.VerifiedDeal {
+			fileSize := uint64(0)
+			if dealConfig.FileSize > 0 {
+				fileSize = uint64(dealConfig.FileSize)
+			}
+			datacap.Add(datacap, new(big.Int).SetUint64(commp.PaddedPieceSize(fileSize)))
+		}
+	}
+
+	return datacap
+}
+
+// CheckDatacap returns the remaining datacap of wallet, with an error matching ErrInsufficientDatacap when it is less
+// than the sum of paddedPieceSizes, the padded piece sizes of the verified deals planned
+func (lotusClient *LotusClient) CheckDatacap(wallet string, paddedPieceSizes []int64) (*big.Int, error) {
+	return lotusClient.CheckDatacapWithContext(context.Background(), wallet, paddedPieceSizes)
+}
+
+func (lotusClient *LotusClient) CheckDatacapWithContext(ctx context.Context, wallet string, paddedPieceSizes []int64) (*big.Int, error) {
+	required := big.NewInt(0)
+	for _, paddedPieceSize := range paddedPieceSizes {
+		required.Add(required, big.NewInt(paddedPieceSize))
+	}
+
+	return lotusClient.checkDatacap(ctx, wallet, required)
+}
+
+// CheckDealsDatacap is CheckDatacap for the verified deals of dealConfigs sent from wallet
+func (lotusClient *LotusClient) CheckDealsDatacap(wallet string, dealConfigs []*model.DealConfig) (*big.Int, error) {
+	return lotusClient.CheckDealsDatacapWithContext(context.Background(), wallet, dealConfigs)
+}
+
+func (lotusClient *LotusClient) CheckDealsDatacapWithContext(ctx context.Context, wallet string, dealConfigs []*model.DealConfig) (*big.Int, error) {
+	return lotusClient.checkDatacap(ctx, wallet, DatacapRequired(dealConfigs))
+}
+
+func (lotusClient *LotusClient) checkDatacap(ctx context.Context, wallet string, required *big.Int) (*big.Int, error) {
+	datacap, err := lotusClient.LotusStateVerifiedClientStatusWithContext(ctx, wallet)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	if datacap.Cmp(required) < 0 {
+		err := fmt.Errorf("wallet:%s, datacap:%s, required:%s, %w", wallet, datacap.String(), required.String(), ErrInsufficientDatacap)
+		logs.Log().Error(err)
+		return datacap, err
+	}
+
+	return datacap, nil
+}
@@ -0,0 +1,94 @@
+package lotus
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/client/lotus/lotustest"
+	"github.com/filswan/go-swan-lib/model"
+)
+
+const testDatacapWallet = "f1datacapwallet"
+
+func TestLotusStateVerifiedClientStatus(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	lotusClient := newTestLotusClient(t, server, "")
+
+	// lotus answers null for a wallet which is not a verified client
+	datacap, err := lotusClient.LotusStateVerifiedClientStatus(testDatacapWallet)
+	if err != nil || datacap.Sign() != 0 {
+		t.Fatalf("got %v, %v, want 0", datacap, err)
+	}
+	if calls := server.Calls(lotustest.METHOD_STATE_VERIFIED_CLIENT_STATUS); calls != 1 {
+		t.Fatalf("StateVerifiedClientStatus calls: got %d, want 1", calls)
+	}
+
+	server.SetDatacap(testDatacapWallet, big.NewInt(34359738368))
+	datacap, err = lotusClient.LotusStateVerifiedClientStatus(testDatacapWallet)
+	if err != nil || datacap.Cmp(big.NewInt(34359738368)) != 0 {
+		t.Fatalf("got %v, %v, want 34359738368", datacap, err)
+	}
+}
+
+func TestDatacapRequired(t *testing.T) {
+	dealConfigs := []*model.DealConfig{
+		{VerifiedDeal: true, FileSize: 0},
+		{VerifiedDeal: true, FileSize: 127},
+		{VerifiedDeal: true, FileSize: 128},
+		{VerifiedDeal: true, FileSize: 1000},
+		{VerifiedDeal: false, FileSize: 1 << 30},
+		nil,
+	}
+
+	// padded pieces of 128, 128, 256 and 1024 bytes
+	if datacap := DatacapRequired(dealConfigs); datacap.Cmp(big.NewInt(1536)) != 0 {
+		t.Fatalf("got %v, want 1536", datacap)
+	}
+}
+
+func TestCheckDatacap(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.SetDatacap(testDatacapWallet, big.NewInt(2048))
+	lotusClient := newTestLotusClient(t, server, "")
+
+	datacap, err := lotusClient.CheckDatacap(testDatacapWallet, []int64{1024, 1024})
+	if err != nil || datacap.Cmp(big.NewInt(2048)) != 0 {
+		t.Fatalf("got %v, %v, want 2048", datacap, err)
+	}
+
+	datacap, err = lotusClient.CheckDatacap(testDatacapWallet, []int64{1024, 2048})
+	if !errors.Is(err, ErrInsufficientDatacap) || datacap.Cmp(big.NewInt(2048)) != 0 {
+		t.Fatalf("got %v, %v, want 2048 with ErrInsufficientDatacap", datacap, err)
+	}
+
+	_, err = lotusClient.CheckDatacap("f1withoutdatacap", []int64{128})
+	if !errors.Is(err, ErrInsufficientDatacap) {
+		t.Fatalf("wallet without datacap: got %v, want ErrInsufficientDatacap", err)
+	}
+}
+
+func TestCheckDealsDatacap(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.SetDatacap(testDatacapWallet, big.NewInt(2048))
+	lotusClient := newTestLotusClient(t, server, "")
+
+	dealConfigs := []*model.DealConfig{
+		{VerifiedDeal: true, FileSize: 1000},
+		{VerifiedDeal: true, FileSize: 1000},
+		{VerifiedDeal: false, FileSize: 1000},
+	}
+	_, err := lotusClient.CheckDealsDatacap(testDatacapWallet, dealConfigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dealConfigs = append(dealConfigs, &model.DealConfig{VerifiedDeal: true, FileSize: 1})
+	datacap, err := lotusClient.CheckDealsDatacap(testDatacapWallet, dealConfigs)
+	if !errors.Is(err, ErrInsufficientDatacap) || datacap.Cmp(big.NewInt(2048)) != 0 {
+		t.Fatalf("got %v, %v, want 2048 with ErrInsufficientDatacap", datacap, err)
+	}
+}
@@ -0,0 +1,145 @@
+package lotus
+
+import (
+	"context"
+	"fmt"
+)
+
+// StorageDealStatus is the state of a storage deal, numbered like go-fil-markets numbers them
+type StorageDealStatus uint64
+
+const (
+	STORAGE_DEAL_UNKNOWN StorageDealStatus = iota
+	STORAGE_DEAL_PROPOSAL_NOT_FOUND
+	STORAGE_DEAL_PROPOSAL_REJECTED
+	STORAGE_DEAL_PROPOSAL_ACCEPTED
+	STORAGE_DEAL_STAGED
+	STORAGE_DEAL_SEALING
+	STORAGE_DEAL_FINALIZING
+	STORAGE_DEAL_ACTIVE
+	STORAGE_DEAL_EXPIRED
+	STORAGE_DEAL_SLASHED
+	STORAGE_DEAL_REJECTING
+	STORAGE_DEAL_FAILING
+	STORAGE_DEAL_FUNDS_RESERVED
+	STORAGE_DEAL_CHECK_FOR_ACCEPTANCE
+	STORAGE_DEAL_VALIDATING
+	STORAGE_DEAL_ACCEPT_WAIT
+	STORAGE_DEAL_START_DATA_TRANSFER
+	STORAGE_DEAL_TRANSFERRING
+	STORAGE_DEAL_WAITING_FOR_DATA
+	STORAGE_DEAL_VERIFY_DATA
+	STORAGE_DEAL_RESERVE_PROVIDER_FUNDS
+	STORAGE_DEAL_RESERVE_CLIENT_FUNDS
+	STORAGE_DEAL_PROVIDER_FUNDING
+	STORAGE_DEAL_CLIENT_FUNDING
+	STORAGE_DEAL_PUBLISH
+	STORAGE_DEAL_PUBLISHING
+	STORAGE_DEAL_ERROR
+	STORAGE_DEAL_PROVIDER_TRANSFER_AWAIT_RESTART
+	STORAGE_DEAL_CLIENT_TRANSFER_RESTART
+	STORAGE_DEAL_AWAITING_PRE_COMMIT
+)
+
+// storageDealStatusNames are the names lotus gives the states, indexed by state
+var storageDealStatusNames = []string{
+	"StorageDealUnknown",
+	"StorageDealProposalNotFound",
+	"StorageDealProposalRejected",
+	"StorageDealProposalAccepted",
+	"StorageDealStaged",
+	"StorageDealSealing",
+	"StorageDealFinalizing",
+	"StorageDealActive",
+	"StorageDealExpired",
+	"StorageDealSlashed",
+	"StorageDealRejecting",
+	"StorageDealFailing",
+	"StorageDealFundsReserved",
+	"StorageDealCheckForAcceptance",
+	"StorageDealValidating",
+	"StorageDealAcceptWait",
+	"StorageDealStartDataTransfer",
+	"StorageDealTransferring",
+	"StorageDealWaitingForData",
+	"StorageDealVerifyData",
+	"StorageDealReserveProviderFunds",
+	"StorageDealReserveClientFunds",
+	"StorageDealProviderFunding",
+	"StorageDealClientFunding",
+	"StorageDealPublish",
+	"StorageDealPublishing",
+	"StorageDealError",
+	"StorageDealProviderTransferAwaitRestart",
+	"StorageDealClientTransferRestart",
+	"StorageDealAwaitingPreCommit",
+}
+
+// ParseStorageDealStatus returns the state of a name as lotus gives it, false when the name is unknown
+func ParseStorageDealStatus(name string) (StorageDealStatus, bool) {
+	for state, stateName := range storageDealStatusNames {
+		if stateName == name {
+			return StorageDealStatus(state), true
+		}
+	}
+
+	return STORAGE_DEAL_UNKNOWN, false
+}
+
+// IsKnown reports whether the state is in the table of this package, newer versions of lotus may add states
+func (status StorageDealStatus) IsKnown() bool {
+	return uint64(status) < uint64(len(storageDealStatusNames))
+}
+
+// String returns the name lotus gives the state, StorageDealStatus(n) for a state not known
+func (status StorageDealStatus) String() string {
+	if !status.IsKnown() {
+		return fmt.Sprintf("StorageDealStatus(%d)", uint64(status))
+	}
+
+	return storageDealStatusNames[status]
+}
+
+// IsTerminal reports whether the deal cannot change state anymore
+func (status StorageDealStatus) IsTerminal() bool {
+	switch status {
+	case STORAGE_DEAL_PROPOSAL_NOT_FOUND, STORAGE_DEAL_PROPOSAL_REJECTED, STORAGE_DEAL_ERROR,
+		STORAGE_DEAL_EXPIRED, STORAGE_DEAL_SLASHED:
+		return true
+	}
+
+	return false
+}
+
+// IsFailure reports whether the deal failed or is failing, an expired deal did not fail
+func (status StorageDealStatus) IsFailure() bool {
+	switch status {
+	case STORAGE_DEAL_PROPOSAL_NOT_FOUND, STORAGE_DEAL_PROPOSAL_REJECTED, STORAGE_DEAL_REJECTING,
+		STORAGE_DEAL_FAILING, STORAGE_DEAL_ERROR, STORAGE_DEAL_SLASHED:
+		return true
+	}
+
+	return false
+}
+
+// IsActive reports whether the deal is active on chain, its data proven by the provider
+func (status StorageDealStatus) IsActive() bool {
+	return status == STORAGE_DEAL_ACTIVE
+}
+
+// dealStatusName returns the name of a state, asking lotus through rpcCaller only for a state not known
+func dealStatusName(ctx context.Context, rpcCaller RpcCaller, state int) (*string, error) {
+	status := StorageDealStatus(state)
+	if state >= 0 && status.IsKnown() {
+		name := status.String()
+		return &name, nil
+	}
+
+	var name string
+	err := rpcCaller.Call(ctx, LOTUS_CLIENT_GET_DEAL_STATUS, &name, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &name, nil
+}
@@ -0,0 +1,199 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	LOTUS_STATE_MARKET_BALANCE = "Filecoin.StateMarketBalance"
+	LOTUS_MARKET_ADD_BALANCE   = "Filecoin.MarketAddBalance"
+	LOTUS_MARKET_WITHDRAW      = "Filecoin.MarketWithdraw"
+	LOTUS_MARKET_GET_RESERVED  = "Filecoin.MarketGetReserved"
+)
+
+// ErrInsufficientFunds is returned when the escrow and the wallet of a client cannot pay for a deal
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// MarketBalance is the escrow of an address in the storage market actor, in attoFIL
+type MarketBalance struct {
+	Escrow *big.Int
+	Locked *big.Int // locked by the deals of the address
+}
+
+// DealEscrow is what a deal needs from the escrow of its client against what the client has, in attoFIL
+type DealEscrow struct {
+	Required      *big.Int // the price of the deal for its whole duration
+	Available     *big.Int // escrow neither locked by deals nor reserved by deals being made
+	WalletBalance *big.Int
+	Shortfall     *big.Int // what lotus adds to the escrow from the wallet when making the deal, 0 when none
+}
+
+// StateMarketBalance returns the escrow of address in the storage market
+func (lotusClient *LotusClient) StateMarketBalance(address string) (*MarketBalance, error) {
+	return lotusClient.StateMarketBalanceWithContext(context.Background(), address)
+}
+
+func (lotusClient *LotusClient) StateMarketBalanceWithContext(ctx context.Context, address string) (*MarketBalance, error) {
+	result := struct {
+		Escrow string
+		Locked string
+	}{}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_STATE_MARKET_BALANCE, &result, strings.Trim(address, " "), []interface{}{})
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	escrow, err := parseAttoFil(LOTUS_STATE_MARKET_BALANCE, result.Escrow)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	locked, err := parseAttoFil(LOTUS_STATE_MARKET_BALANCE, result.Locked)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return &MarketBalance{Escrow: escrow, Locked: locked}, nil
+}
+
+// MarketAddBalance sends amount from wallet to the escrow of address and returns the cid of the message
+func (lotusClient *LotusClient) MarketAddBalance(wallet, address string, amount *big.Int) (*string, error) {
+	return lotusClient.MarketAddBalanceWithContext(context.Background(), wallet, address, amount)
+}
+
+func (lotusClient *LotusClient) MarketAddBalanceWithContext(ctx context.Context, wallet, address string, amount *big.Int) (*string, error) {
+	return lotusClient.marketTransfer(ctx, LOTUS_MARKET_ADD_BALANCE, wallet, address, amount)
+}
+
+// MarketWithdraw sends amount from the escrow of address to wallet and returns the cid of the message,
+// lotus refuses to withdraw more than the escrow not locked
+func (lotusClient *LotusClient) MarketWithdraw(wallet, address string, amount *big.Int) (*string, error) {
+	return lotusClient.MarketWithdrawWithContext(context.Background(), wallet, address, amount)
+}
+
+func (lotusClient *LotusClient) MarketWithdrawWithContext(ctx context.Context, wallet, address string, amount *big.Int) (*string, error) {
+	return lotusClient.marketTransfer(ctx, LOTUS_MARKET_WITHDRAW, wallet, address, amount)
+}
+
+func (lotusClient *LotusClient) marketTransfer(ctx context.Context, method, wallet, address string, amount *big.Int) (*string, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		err := fmt.Errorf("%s, invalid amount:%v", method, amount)
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	messageCid := Cid{}
+	err := lotusClient.rpcClient().Call(ctx, method, &messageCid, strings.Trim(wallet, " "), strings.Trim(address, " "), amount.String())
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return &messageCid.Cid, nil
+}
+
+// MarketGetReserved returns the escrow of address reserved by the deals the node is making
+func (lotusClient *LotusClient) MarketGetReserved(address string) (*big.Int, error) {
+	return lotusClient.MarketGetReservedWithContext(context.Background(), address)
+}
+
+func (lotusClient *LotusClient) MarketGetReservedWithContext(ctx context.Context, address string) (*big.Int, error) {
+	var result string
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_MARKET_GET_RESERVED, &result, strings.Trim(address, " "))
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	reserved, err := parseAttoFil(LOTUS_MARKET_GET_RESERVED, result)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return reserved, nil
+}
+
+// EstimateDealEscrow returns the attoFIL a deal locks in the escrow of its client, its epoch price for its duration
+func EstimateDealEscrow(epochPrice big.Int, duration int) *big.Int {
+	return new(big.Int).Mul(&epochPrice, big.NewInt(int64(duration)))
+}
+
+// CheckDealEscrow compares the escrow a deal of epochPrice and duration needs to the escrow of wallet and reports
+// the shortfall lotus will add from the wallet, with an error matching ErrInsufficientFunds when the wallet cannot
+func (lotusClient *LotusClient) CheckDealEscrow(wallet string, epochPrice big.Int, duration int) (*DealEscrow, error) {
+	return lotusClient.CheckDealEscrowWithContext(context.Background(), wallet, epochPrice, duration)
+}
+
+func (lotusClient *LotusClient) CheckDealEscrowWithContext(ctx context.Context, wallet string, epochPrice big.Int, duration int) (*DealEscrow, error) {
+	dealEscrow := &DealEscrow{
+		Required:      EstimateDealEscrow(epochPrice, duration),
+		Available:     big.NewInt(0),
+		WalletBalance: big.NewInt(0),
+		Shortfall:     big.NewInt(0),
+	}
+
+	// a free deal, e.g. most verified deals, needs no escrow
+	if dealEscrow.Required.Sign() <= 0 {
+		return dealEscrow, nil
+	}
+
+	marketBalance, err := lotusClient.StateMarketBalanceWithContext(ctx, wallet)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	reserved, err := lotusClient.MarketGetReservedWithContext(ctx, wallet)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	dealEscrow.Available.Sub(marketBalance.Escrow, marketBalance.Locked)
+	dealEscrow.Available.Sub(dealEscrow.Available, reserved)
+	if dealEscrow.Available.Sign() < 0 {
+		dealEscrow.Available.SetInt64(0)
+	}
+
+	if dealEscrow.Available.Cmp(dealEscrow.Required) >= 0 {
+		return dealEscrow, nil
+	}
+	dealEscrow.Shortfall.Sub(dealEscrow.Required, dealEscrow.Available)
+
+	walletBalance, err := lotusClient.WalletBalanceWithContext(ctx, wallet)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+	dealEscrow.WalletBalance = walletBalance
+
+	if walletBalance.Cmp(dealEscrow.Shortfall) < 0 {
+		err := fmt.Errorf("wallet:%s, deal escrow required:%s FIL, available:%s FIL, shortfall:%s FIL, wallet balance:%s FIL, %w",
+			wallet, AttoFil2Fil(dealEscrow.Required), AttoFil2Fil(dealEscrow.Available), AttoFil2Fil(dealEscrow.Shortfall), AttoFil2Fil(walletBalance), ErrInsufficientFunds)
+		logs.Log().Error(err)
+		return dealEscrow, err
+	}
+
+	logs.Log().Warn("wallet:", wallet, ", escrow short of ", AttoFil2Fil(dealEscrow.Shortfall), " FIL for the deal, lotus will add it from the wallet")
+	return dealEscrow, nil
+}
+
+func parseAttoFil(method, value string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		err := fmt.Errorf("%s, invalid amount:%s", method, value)
+		return nil, err
+	}
+
+	return amount, nil
+}
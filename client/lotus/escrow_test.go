@@ -0,0 +1,109 @@
+package lotus
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/client/lotus/lotustest"
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const testEscrowWallet = "f1escrowwallet"
+
+// captureLogs makes the library log to the returned buffer until the end of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	logger, err := logs.NewLogger(logs.Config{Output: buf, NoLogFiles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := logs.Log()
+	logs.SetLogger(logger)
+	t.Cleanup(func() { logs.SetLogger(previous) })
+
+	return buf
+}
+
+// newTestEscrowServer has 1000 attoFIL of escrow with 200 locked and 100 reserved, 700 available
+func newTestEscrowServer(t *testing.T, walletBalance int64) *lotustest.Server {
+	server := lotustest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddWallet(testEscrowWallet, big.NewInt(walletBalance))
+	server.SetMarketBalance(testEscrowWallet, big.NewInt(1000), big.NewInt(200))
+	server.SetReserved(testEscrowWallet, big.NewInt(100))
+
+	return server
+}
+
+func checkDealEscrow(t *testing.T, dealEscrow *DealEscrow, required, available, walletBalance, shortfall int64) {
+	if dealEscrow == nil {
+		t.Fatal("no deal escrow")
+	}
+
+	got := []*big.Int{dealEscrow.Required, dealEscrow.Available, dealEscrow.WalletBalance, dealEscrow.Shortfall}
+	want := []int64{required, available, walletBalance, shortfall}
+	for i := range want {
+		if got[i].Cmp(big.NewInt(want[i])) != 0 {
+			t.Fatalf("deal escrow: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCheckDealEscrowSufficient(t *testing.T) {
+	server := newTestEscrowServer(t, 0)
+
+	dealEscrow, err := newTestLotusClient(t, server, "").CheckDealEscrow(testEscrowWallet, *big.NewInt(7), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDealEscrow(t, dealEscrow, 700, 700, 0, 0)
+
+	if calls := server.Calls(lotustest.METHOD_WALLET_BALANCE); calls != 0 {
+		t.Fatalf("WalletBalance calls without shortfall: got %d, want 0", calls)
+	}
+}
+
+func TestCheckDealEscrowShortfallCoveredByWallet(t *testing.T) {
+	server := newTestEscrowServer(t, 300)
+	logsBuf := captureLogs(t)
+
+	dealEscrow, err := newTestLotusClient(t, server, "").CheckDealEscrow(testEscrowWallet, *big.NewInt(10), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDealEscrow(t, dealEscrow, 1000, 700, 300, 300)
+
+	if !strings.Contains(logsBuf.String(), "level=warn") || strings.Contains(logsBuf.String(), "level=error") {
+		t.Fatalf("want a warning only, logs:\n%s", logsBuf.String())
+	}
+}
+
+func TestCheckDealEscrowInsufficientFunds(t *testing.T) {
+	server := newTestEscrowServer(t, 299)
+
+	dealEscrow, err := newTestLotusClient(t, server, "").CheckDealEscrow(testEscrowWallet, *big.NewInt(10), 100)
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got %v, want ErrInsufficientFunds", err)
+	}
+	checkDealEscrow(t, dealEscrow, 1000, 700, 299, 300)
+}
+
+func TestCheckDealEscrowFreeDeal(t *testing.T) {
+	server := newTestEscrowServer(t, 0)
+
+	dealEscrow, err := newTestLotusClient(t, server, "").CheckDealEscrow(testEscrowWallet, *big.NewInt(0), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDealEscrow(t, dealEscrow, 0, 0, 0, 0)
+
+	for _, method := range []string{lotustest.METHOD_STATE_MARKET_BALANCE, lotustest.METHOD_MARKET_GET_RESERVED, lotustest.METHOD_WALLET_BALANCE} {
+		if calls := server.Calls(method); calls != 0 {
+			t.Fatalf("%s calls for a free deal: got %d, want 0", method, calls)
+		}
+	}
+}
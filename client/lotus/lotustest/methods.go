@@ -0,0 +1,1416 @@
+package lotustest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	METHOD_VERSION                      = "Filecoin.Version"
+	METHOD_AUTH_VERIFY                  = "Filecoin.AuthVerify"
+	METHOD_CHAIN_HEAD                   = "Filecoin.ChainHead"
+	METHOD_CLIENT_MINER_QUERY_OFFER     = "Filecoin.ClientMinerQueryOffer"
+	METHOD_CLIENT_QUERY_ASK             = "Filecoin.ClientQueryAsk"
+	METHOD_CLIENT_GET_DEAL_INFO         = "Filecoin.ClientGetDealInfo"
+	METHOD_CLIENT_GET_DEAL_STATUS       = "Filecoin.ClientGetDealStatus"
+	METHOD_CLIENT_CALC_COMM_P           = "Filecoin.ClientCalcCommP"
+	METHOD_CLIENT_IMPORT                = "Filecoin.ClientImport"
+	METHOD_CLIENT_GEN_CAR               = "Filecoin.ClientGenCar"
+	METHOD_CLIENT_START_DEAL            = "Filecoin.ClientStartDeal"
+	METHOD_STATE_MARKET_STORAGE_DEAL    = "Filecoin.StateMarketStorageDeal"
+	METHOD_STATE_GET_CLAIM              = "Filecoin.StateGetClaim"
+	METHOD_STATE_VERIFIED_CLIENT_STATUS = "Filecoin.StateVerifiedClientStatus"
+	METHOD_WALLET_LIST                  = "Filecoin.WalletList"
+	METHOD_WALLET_BALANCE               = "Filecoin.WalletBalance"
+	METHOD_WALLET_DEFAULT_ADDRESS       = "Filecoin.WalletDefaultAddress"
+	METHOD_WALLET_NEW                   = "Filecoin.WalletNew"
+	METHOD_WALLET_HAS                   = "Filecoin.WalletHas"
+	METHOD_WALLET_VALIDATE_ADDRESS      = "Filecoin.WalletValidateAddress"
+	METHOD_STATE_MARKET_BALANCE         = "Filecoin.StateMarketBalance"
+	METHOD_MARKET_ADD_BALANCE           = "Filecoin.MarketAddBalance"
+	METHOD_MARKET_WITHDRAW              = "Filecoin.MarketWithdraw"
+	METHOD_MARKET_GET_RESERVED          = "Filecoin.MarketGetReserved"
+	METHOD_STATE_WAIT_MSG               = "Filecoin.StateWaitMsg"
+	METHOD_STATE_WAIT_MSG_LIMITED       = "Filecoin.StateWaitMsgLimited"
+	METHOD_STATE_SEARCH_MSG             = "Filecoin.StateSearchMsg"
+	METHOD_STATE_SEARCH_MSG_LIMITED     = "Filecoin.StateSearchMsgLimited"
+	METHOD_MPOOL_PENDING                = "Filecoin.MpoolPending"
+	METHOD_GAS_ESTIMATE_MESSAGE_GAS     = "Filecoin.GasEstimateMessageGas"
+	METHOD_MARKET_GET_ASK               = "Filecoin.MarketGetAsk"
+	METHOD_MARKET_LIST_INCOMPLETE_DEALS = "Filecoin.MarketListIncompleteDeals"
+	METHOD_MARKET_IMPORT_DEAL_DATA      = "Filecoin.MarketImportDealData"
+
+	// storage deal states of a new deal, as numbered by lotus
+	DEAL_STATE_CHECK_FOR_ACCEPTANCE = 13
+
+	KEY_TYPE_SECP256K1 = "secp256k1"
+	KEY_TYPE_BLS       = "bls"
+	ADDRESS_UNDEFINED  = "<empty>"
+
+	// methods of the storage market actor
+	ADDRESS_STORAGE_MARKET         = "f05"
+	METHOD_NUM_ADD_BALANCE         = 2
+	METHOD_NUM_WITHDRAW_BALANCE    = 3
+	GAS_LIMIT_ESTIMATE             = 10000000
+	GAS_FEE_CAP_ESTIMATE           = "100000"
+	GAS_PREMIUM_ESTIMATE           = "1000"
+	GAS_USED_DEFAULT               = 5000000
+	STATE_WAIT_MSG_POLL_INTERVAL   = 10 * time.Millisecond
+	STATE_WAIT_MSG_TIMEOUT_DEFAULT = 10 * time.Second
+	// lookback limit of the message methods without limit, like api.LookbackNoLimit of lotus
+	LOOKBACK_NO_LIMIT = -1
+)
+
+// addressPattern matches the addresses of mainnet and testnets, of any protocol
+var addressPattern = regexp.MustCompile(`^[ft](0[0-9]+|[1-4][a-z0-9]+)$`)
+
+// writeMethods need the write permission when authentication is enabled
+var writeMethods = map[string]bool{
+	METHOD_CLIENT_IMPORT:           true,
+	METHOD_CLIENT_GEN_CAR:          true,
+	METHOD_CLIENT_START_DEAL:       true,
+	METHOD_MARKET_IMPORT_DEAL_DATA: true,
+	METHOD_WALLET_LIST:             true,
+	METHOD_WALLET_DEFAULT_ADDRESS:  true,
+	METHOD_WALLET_NEW:              true,
+	METHOD_WALLET_HAS:              true,
+	METHOD_MARKET_ADD_BALANCE:      true,
+	METHOD_MARKET_WITHDRAW:         true,
+	METHOD_MARKET_GET_RESERVED:     true,
+}
+
+// dealStatusNames are the storage deal state names of lotus indexed by state
+var dealStatusNames = []string{
+	"StorageDealUnknown",
+	"StorageDealProposalNotFound",
+	"StorageDealProposalRejected",
+	"StorageDealProposalAccepted",
+	"StorageDealStaged",
+	"StorageDealSealing",
+	"StorageDealFinalizing",
+	"StorageDealActive",
+	"StorageDealExpired",
+	"StorageDealSlashed",
+	"StorageDealRejecting",
+	"StorageDealFailing",
+	"StorageDealFundsReserved",
+	"StorageDealCheckForAcceptance",
+	"StorageDealValidating",
+	"StorageDealAcceptWait",
+	"StorageDealStartDataTransfer",
+	"StorageDealTransferring",
+	"StorageDealWaitingForData",
+	"StorageDealVerifyData",
+	"StorageDealReserveProviderFunds",
+	"StorageDealReserveClientFunds",
+	"StorageDealProviderFunding",
+	"StorageDealClientFunding",
+	"StorageDealPublish",
+	"StorageDealPublishing",
+	"StorageDealError",
+	"StorageDealProviderTransferAwaitRestart",
+	"StorageDealClientTransferRestart",
+	"StorageDealAwaitingPreCommit",
+}
+
+type Cid struct {
+	Cid string `json:"/"`
+}
+
+// Ask is the storage ask of a miner
+type Ask struct {
+	PeerId        string
+	Price         string
+	VerifiedPrice string
+	MinPieceSize  int64
+	MaxPieceSize  int64
+}
+
+type DealStage struct {
+	Name string
+	Logs []string
+}
+
+// ClientDeal is a deal as seen by ClientGetDealInfo
+type ClientDeal struct {
+	ProposalCid   string
+	State         int
+	Message       string
+	Provider      string
+	PricePerEpoch string
+	Duration      int
+	DealID        int64
+	Verified      bool
+	Stages        []DealStage
+}
+
+// StorageDeal is a deal published on chain as seen by StateMarketStorageDeal
+type StorageDeal struct {
+	PieceCid         string
+	VerifiedDeal     bool
+	Client           string
+	Provider         string
+	StartEpoch       int
+	EndEpoch         int
+	SectorStartEpoch int
+	LastUpdatedEpoch int
+	SlashEpoch       int
+}
+
+type Claim struct {
+	Provider  uint64
+	Client    uint64
+	DataCid   string
+	Size      uint64
+	TermMin   int64
+	TermMax   int64
+	TermStart int64
+	Sector    uint64
+}
+
+// MarketDeal is a deal of the miner as seen by MarketListIncompleteDeals
+type MarketDeal struct {
+	ProposalCid string
+	State       int
+	Message     string
+	DealID      uint64
+	Client      string
+	Provider    string
+}
+
+// DealProposal is what ClientStartDeal received
+type DealProposal struct {
+	Data struct {
+		TransferType string
+		Root         Cid
+		PieceCid     *Cid
+		PieceSize    int
+	}
+	Wallet            string
+	Miner             string
+	EpochPrice        string
+	MinBlocksDuration int
+	DealStartEpoch    int
+	FastRetrieval     bool
+	VerifiedDeal      bool
+}
+
+// MarketBalance is the escrow of an address in the storage market, in attoFIL
+type MarketBalance struct {
+	Escrow *big.Int
+	Locked *big.Int
+}
+
+// ChainMessage is a message of the node, in the message pool until it lands on chain
+type ChainMessage struct {
+	Cid      string // generated when empty
+	From     string
+	To       string
+	Nonce    uint64
+	Value    string
+	Method   uint64
+	ExitCode int64 // of its receipt once landed
+	GasUsed  int64 // of its receipt once landed, GAS_USED_DEFAULT when 0
+	Return   []byte
+	Height   int64 // epoch it landed at
+	Landed   bool
+}
+
+// ImportedData is what MarketImportDealData received
+type ImportedData struct {
+	ProposalCid string
+	Path        string
+}
+
+type state struct {
+	version      string
+	height       int64
+	asks         map[string]Ask
+	marketAsk    *Ask
+	clientDeals  map[string]ClientDeal
+	storageDeals map[uint64]StorageDeal
+	claims       map[string]map[uint64]Claim
+	marketDeals  []MarketDeal
+	commPs       map[string]string
+	datacaps     map[string]*big.Int
+	wallets      []string
+	balances     map[string]*big.Int
+	wallet       string
+	escrows      map[string]*MarketBalance
+	messages     []*ChainMessage
+	reserved     map[string]*big.Int
+	proposals    []DealProposal
+	imports      []ImportedData
+	clientFiles  []string
+	nextCid      int
+}
+
+func newState() *state {
+	return &state{
+		version:      "1.23.0+fake",
+		asks:         map[string]Ask{},
+		clientDeals:  map[string]ClientDeal{},
+		storageDeals: map[uint64]StorageDeal{},
+		claims:       map[string]map[uint64]Claim{},
+		commPs:       map[string]string{},
+		datacaps:     map[string]*big.Int{},
+		balances:     map[string]*big.Int{},
+		escrows:      map[string]*MarketBalance{},
+		reserved:     map[string]*big.Int{},
+	}
+}
+
+func (state *state) newCid(kind string) string {
+	state.nextCid++
+	return fmt.Sprintf("bafyfake%s%d", kind, state.nextCid)
+}
+
+func (server *Server) SetVersion(version string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.version = version
+}
+
+func (server *Server) SetHeight(height int64) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.height = height
+}
+
+// AdvanceHeight adds epochs to the chain head and returns the new height
+func (server *Server) AdvanceHeight(epochs int64) int64 {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.height += epochs
+	return server.state.height
+}
+
+// SetAsk makes minerFid reachable with the given ask, an empty PeerId is derived from minerFid
+func (server *Server) SetAsk(minerFid string, ask Ask) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if ask.PeerId == "" {
+		ask.PeerId = "12D3KooFake" + minerFid
+	}
+	server.state.asks[minerFid] = ask
+}
+
+// SetMarketAsk sets the ask returned by MarketGetAsk
+func (server *Server) SetMarketAsk(ask Ask) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.marketAsk = &ask
+}
+
+func (server *Server) SetClientDeal(deal ClientDeal) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.clientDeals[deal.ProposalCid] = deal
+}
+
+func (server *Server) ClientDeal(proposalCid string) (ClientDeal, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	deal, ok := server.state.clientDeals[proposalCid]
+	return deal, ok
+}
+
+func (server *Server) SetStorageDeal(dealId uint64, deal StorageDeal) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.storageDeals[dealId] = deal
+}
+
+func (server *Server) SetClaim(minerFid string, claimId uint64, claim Claim) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.state.claims[minerFid] == nil {
+		server.state.claims[minerFid] = map[uint64]Claim{}
+	}
+	server.state.claims[minerFid][claimId] = claim
+}
+
+func (server *Server) SetMarketDeals(deals []MarketDeal) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.marketDeals = append([]MarketDeal{}, deals...)
+}
+
+// SetCommP sets the piece cid returned by ClientCalcCommP for path
+func (server *Server) SetCommP(path, pieceCid string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.commPs[path] = pieceCid
+}
+
+// SetDatacap sets the datacap of a verified client, nil or 0 makes wallet a client without datacap
+func (server *Server) SetDatacap(wallet string, datacap *big.Int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if datacap == nil || datacap.Sign() <= 0 {
+		delete(server.state.datacaps, wallet)
+		return
+	}
+	server.state.datacaps[wallet] = new(big.Int).Set(datacap)
+}
+
+// AddWallet adds a wallet with its key to the node, the first wallet added is the default one
+func (server *Server) AddWallet(wallet string, balance *big.Int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.addWallet(wallet)
+	server.state.setBalance(wallet, balance)
+}
+
+// SetBalance sets the balance in attoFIL of an address, a wallet of the node or not
+func (server *Server) SetBalance(address string, balance *big.Int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.setBalance(address, balance)
+}
+
+// SetDefaultWallet sets the wallet returned by WalletDefaultAddress, empty means none
+func (server *Server) SetDefaultWallet(wallet string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.wallet = wallet
+}
+
+// Wallets returns the wallets of the node, the ones created with WalletNew included
+func (server *Server) Wallets() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string{}, server.state.wallets...)
+}
+
+// SetMarketBalance sets the escrow of address, nil amounts are 0
+func (server *Server) SetMarketBalance(address string, escrow, locked *big.Int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.escrows[address] = &MarketBalance{Escrow: bigOrZero(escrow), Locked: bigOrZero(locked)}
+}
+
+// MarketBalance returns the escrow of address, after the funds added and withdrawn
+func (server *Server) MarketBalance(address string) MarketBalance {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	escrow := server.state.escrow(address)
+	return MarketBalance{Escrow: new(big.Int).Set(escrow.Escrow), Locked: new(big.Int).Set(escrow.Locked)}
+}
+
+// SetReserved sets the escrow of address reserved by the deals being made
+func (server *Server) SetReserved(address string, reserved *big.Int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.reserved[address] = bigOrZero(reserved)
+}
+
+// Balance returns the balance of address in attoFIL, after the funds added to and withdrawn from the escrow
+func (server *Server) Balance(address string) *big.Int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return new(big.Int).Set(server.state.balance(address))
+}
+
+// PushMessage adds a message to the message pool and returns its cid
+func (server *Server) PushMessage(message ChainMessage) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	message.Landed = false
+	return server.state.addMessage(message).Cid
+}
+
+// LandMessage includes a message of the message pool on chain at the current height, with the receipt it was pushed with
+func (server *Server) LandMessage(msgCid string) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	message := server.state.message(msgCid)
+	if message == nil || message.Landed {
+		return false
+	}
+
+	message.Landed = true
+	message.Height = server.state.height
+	return true
+}
+
+// Message returns a message pushed, landed or not
+func (server *Server) Message(msgCid string) (ChainMessage, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	message := server.state.message(msgCid)
+	if message == nil {
+		return ChainMessage{}, false
+	}
+	return *message, true
+}
+
+func (state *state) addMessage(message ChainMessage) *ChainMessage {
+	if message.Cid == "" {
+		message.Cid = state.newCid("message")
+	}
+	if message.Value == "" {
+		message.Value = "0"
+	}
+	if message.GasUsed == 0 {
+		message.GasUsed = GAS_USED_DEFAULT
+	}
+
+	state.messages = append(state.messages, &message)
+	return &message
+}
+
+func (state *state) message(msgCid string) *ChainMessage {
+	for _, message := range state.messages {
+		if message.Cid == msgCid {
+			return message
+		}
+	}
+	return nil
+}
+
+func (state *state) nonce(from string) uint64 {
+	nonce := uint64(0)
+	for _, message := range state.messages {
+		if message.From == from {
+			nonce++
+		}
+	}
+	return nonce
+}
+
+func (state *state) escrow(address string) *MarketBalance {
+	escrow, ok := state.escrows[address]
+	if !ok {
+		escrow = &MarketBalance{Escrow: big.NewInt(0), Locked: big.NewInt(0)}
+		state.escrows[address] = escrow
+	}
+	return escrow
+}
+
+func (state *state) balance(address string) *big.Int {
+	balance, ok := state.balances[address]
+	if !ok {
+		balance = big.NewInt(0)
+		state.balances[address] = balance
+	}
+	return balance
+}
+
+func bigOrZero(value *big.Int) *big.Int {
+	if value == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(value)
+}
+
+func (state *state) addWallet(wallet string) {
+	for _, existing := range state.wallets {
+		if existing == wallet {
+			return
+		}
+	}
+
+	state.wallets = append(state.wallets, wallet)
+	if state.wallet == "" {
+		state.wallet = wallet
+	}
+}
+
+func (state *state) setBalance(address string, balance *big.Int) {
+	if balance == nil {
+		delete(state.balances, address)
+		return
+	}
+	state.balances[address] = new(big.Int).Set(balance)
+}
+
+// Proposals returns the deals started with ClientStartDeal
+func (server *Server) Proposals() []DealProposal {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]DealProposal{}, server.state.proposals...)
+}
+
+// Imports returns the data imported with MarketImportDealData
+func (server *Server) Imports() []ImportedData {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]ImportedData{}, server.state.imports...)
+}
+
+// ClientFiles returns the paths imported with ClientImport
+func (server *Server) ClientFiles() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string{}, server.state.clientFiles...)
+}
+
+func (server *Server) registerMethods() {
+	methods := map[string]Handler{
+		METHOD_VERSION:                      server.version,
+		METHOD_AUTH_VERIFY:                  server.authVerify,
+		METHOD_CHAIN_HEAD:                   server.chainHead,
+		METHOD_CLIENT_MINER_QUERY_OFFER:     server.clientMinerQueryOffer,
+		METHOD_CLIENT_QUERY_ASK:             server.clientQueryAsk,
+		METHOD_CLIENT_GET_DEAL_INFO:         server.clientGetDealInfo,
+		METHOD_CLIENT_GET_DEAL_STATUS:       server.clientGetDealStatus,
+		METHOD_CLIENT_CALC_COMM_P:           server.clientCalcCommP,
+		METHOD_CLIENT_IMPORT:                server.clientImport,
+		METHOD_CLIENT_GEN_CAR:               server.clientGenCar,
+		METHOD_CLIENT_START_DEAL:            server.clientStartDeal,
+		METHOD_STATE_MARKET_STORAGE_DEAL:    server.stateMarketStorageDeal,
+		METHOD_STATE_GET_CLAIM:              server.stateGetClaim,
+		METHOD_STATE_VERIFIED_CLIENT_STATUS: server.stateVerifiedClientStatus,
+		METHOD_WALLET_LIST:                  server.walletList,
+		METHOD_WALLET_BALANCE:               server.walletBalance,
+		METHOD_WALLET_DEFAULT_ADDRESS:       server.walletDefaultAddress,
+		METHOD_WALLET_NEW:                   server.walletNew,
+		METHOD_WALLET_HAS:                   server.walletHas,
+		METHOD_WALLET_VALIDATE_ADDRESS:      server.walletValidateAddress,
+		METHOD_STATE_MARKET_BALANCE:         server.stateMarketBalance,
+		METHOD_MARKET_ADD_BALANCE:           server.marketAddBalance,
+		METHOD_MARKET_WITHDRAW:              server.marketWithdraw,
+		METHOD_MARKET_GET_RESERVED:          server.marketGetReserved,
+		METHOD_STATE_WAIT_MSG:               server.stateWaitMsg,
+		METHOD_STATE_WAIT_MSG_LIMITED:       server.stateWaitMsgLimited,
+		METHOD_STATE_SEARCH_MSG:             server.stateSearchMsg,
+		METHOD_STATE_SEARCH_MSG_LIMITED:     server.stateSearchMsgLimited,
+		METHOD_MPOOL_PENDING:                server.mpoolPending,
+		METHOD_GAS_ESTIMATE_MESSAGE_GAS:     server.gasEstimateMessageGas,
+		METHOD_MARKET_GET_ASK:               server.marketGetAsk,
+		METHOD_MARKET_LIST_INCOMPLETE_DEALS: server.marketListIncompleteDeals,
+		METHOD_MARKET_IMPORT_DEAL_DATA:      server.marketImportDealData,
+	}
+
+	for method, handler := range methods {
+		server.handlers[method] = handler
+	}
+}
+
+func (server *Server) version(params []json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	result := map[string]interface{}{
+		"Version":    server.state.version,
+		"APIVersion": 0x20000,
+		"BlockDelay": 30,
+	}
+	return result, nil
+}
+
+func (server *Server) authVerify(params []json.RawMessage) (interface{}, error) {
+	var token string
+	err := decodeParam(params, 0, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	perms, ok := server.tokens[token]
+	if !ok {
+		return nil, fmt.Errorf("JWT Verification failed: invalid token")
+	}
+
+	return perms, nil
+}
+
+func (server *Server) chainHead(params []json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	result := map[string]interface{}{
+		"Cids":   []Cid{{Cid: fmt.Sprintf("bafyfaketipset%d", server.state.height)}},
+		"Height": server.state.height,
+	}
+	return result, nil
+}
+
+func (server *Server) clientMinerQueryOffer(params []json.RawMessage) (interface{}, error) {
+	var minerFid string
+	err := decodeParam(params, 0, &minerFid)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	ask, ok := server.state.asks[minerFid]
+	if !ok {
+		return nil, fmt.Errorf("failed to query miner %s: miner not found", minerFid)
+	}
+
+	result := map[string]interface{}{
+		"Err": "",
+		"MinerPeer": map[string]interface{}{
+			"Address": minerFid,
+			"ID":      ask.PeerId,
+		},
+	}
+	return result, nil
+}
+
+func (server *Server) clientQueryAsk(params []json.RawMessage) (interface{}, error) {
+	var peerId, minerFid string
+	err := decodeParam(params, 0, &peerId)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeParam(params, 1, &minerFid)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	ask, ok := server.state.asks[minerFid]
+	if !ok || ask.PeerId != peerId {
+		return nil, fmt.Errorf("failed to query ask of miner %s: peer %s not found", minerFid, peerId)
+	}
+
+	return askResult(minerFid, ask), nil
+}
+
+func askResult(minerFid string, ask Ask) map[string]interface{} {
+	return map[string]interface{}{
+		"Price":         ask.Price,
+		"VerifiedPrice": ask.VerifiedPrice,
+		"MinPieceSize":  ask.MinPieceSize,
+		"MaxPieceSize":  ask.MaxPieceSize,
+		"Miner":         minerFid,
+	}
+}
+
+func (server *Server) clientGetDealInfo(params []json.RawMessage) (interface{}, error) {
+	var proposalCid Cid
+	err := decodeParam(params, 0, &proposalCid)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	deal, ok := server.state.clientDeals[proposalCid.Cid]
+	if !ok {
+		return nil, fmt.Errorf("failed to get deal info: datastore: key not found")
+	}
+
+	stages := []map[string]interface{}{}
+	for _, stage := range deal.Stages {
+		logs := []map[string]interface{}{}
+		for _, log := range stage.Logs {
+			logs = append(logs, map[string]interface{}{"Log": log})
+		}
+		stages = append(stages, map[string]interface{}{"Name": stage.Name, "Logs": logs})
+	}
+
+	result := map[string]interface{}{
+		"ProposalCid":   Cid{Cid: deal.ProposalCid},
+		"State":         deal.State,
+		"Message":       deal.Message,
+		"Provider":      deal.Provider,
+		"PricePerEpoch": deal.PricePerEpoch,
+		"Duration":      deal.Duration,
+		"DealID":        deal.DealID,
+		"Verified":      deal.Verified,
+		"DealStages":    map[string]interface{}{"Stages": stages},
+	}
+	return result, nil
+}
+
+func (server *Server) clientGetDealStatus(params []json.RawMessage) (interface{}, error) {
+	var dealState int
+	err := decodeParam(params, 0, &dealState)
+	if err != nil {
+		return nil, err
+	}
+
+	if dealState < 0 || dealState >= len(dealStatusNames) {
+		return nil, fmt.Errorf("no such deal state %d", dealState)
+	}
+
+	return dealStatusNames[dealState], nil
+}
+
+func (server *Server) clientCalcCommP(params []json.RawMessage) (interface{}, error) {
+	var path string
+	err := decodeParam(params, 0, &path)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	pieceCid, ok := server.state.commPs[path]
+	if !ok {
+		return nil, fmt.Errorf("open %s: no such file or directory", path)
+	}
+
+	result := map[string]interface{}{
+		"Root": Cid{Cid: pieceCid},
+		"Size": 0,
+	}
+	return result, nil
+}
+
+func (server *Server) clientImport(params []json.RawMessage) (interface{}, error) {
+	var fileRef struct {
+		Path  string
+		IsCAR bool
+	}
+	err := decodeParam(params, 0, &fileRef)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.state.clientFiles = append(server.state.clientFiles, fileRef.Path)
+	result := map[string]interface{}{
+		"Root":     Cid{Cid: server.state.newCid("root")},
+		"ImportID": len(server.state.clientFiles),
+	}
+	return result, nil
+}
+
+func (server *Server) clientGenCar(params []json.RawMessage) (interface{}, error) {
+	var fileRef struct {
+		Path  string
+		IsCAR bool
+	}
+	err := decodeParam(params, 0, &fileRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (server *Server) clientStartDeal(params []json.RawMessage) (interface{}, error) {
+	var proposal DealProposal
+	err := decodeParam(params, 0, &proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if _, ok := server.state.asks[proposal.Miner]; !ok {
+		return nil, fmt.Errorf("failed to get peer ID for miner %s: miner not found", proposal.Miner)
+	}
+
+	proposalCid := server.state.newCid("proposal")
+	server.state.proposals = append(server.state.proposals, proposal)
+	server.state.clientDeals[proposalCid] = ClientDeal{
+		ProposalCid:   proposalCid,
+		State:         DEAL_STATE_CHECK_FOR_ACCEPTANCE,
+		Provider:      proposal.Miner,
+		PricePerEpoch: proposal.EpochPrice,
+		Duration:      proposal.MinBlocksDuration,
+		Verified:      proposal.VerifiedDeal,
+	}
+
+	return Cid{Cid: proposalCid}, nil
+}
+
+func (server *Server) stateMarketStorageDeal(params []json.RawMessage) (interface{}, error) {
+	var dealId uint64
+	err := decodeParam(params, 0, &dealId)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	deal, ok := server.state.storageDeals[dealId]
+	if !ok {
+		return nil, fmt.Errorf("deal %d not found", dealId)
+	}
+
+	result := map[string]interface{}{
+		"Proposal": map[string]interface{}{
+			"PieceCID":     Cid{Cid: deal.PieceCid},
+			"VerifiedDeal": deal.VerifiedDeal,
+			"Client":       deal.Client,
+			"Provider":     deal.Provider,
+			"StartEpoch":   deal.StartEpoch,
+			"EndEpoch":     deal.EndEpoch,
+		},
+		"State": map[string]interface{}{
+			"SectorStartEpoch": deal.SectorStartEpoch,
+			"LastUpdatedEpoch": deal.LastUpdatedEpoch,
+			"SlashEpoch":       deal.SlashEpoch,
+		},
+	}
+	return result, nil
+}
+
+// stateGetClaim returns null for a claim that does not exist, like lotus
+func (server *Server) stateGetClaim(params []json.RawMessage) (interface{}, error) {
+	var minerFid string
+	var claimId uint64
+	err := decodeParam(params, 0, &minerFid)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeParam(params, 1, &claimId)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	claim, ok := server.state.claims[minerFid][claimId]
+	if !ok {
+		return nil, nil
+	}
+
+	result := map[string]interface{}{
+		"Provider":  claim.Provider,
+		"Client":    claim.Client,
+		"Data":      Cid{Cid: claim.DataCid},
+		"Size":      claim.Size,
+		"TermMin":   claim.TermMin,
+		"TermMax":   claim.TermMax,
+		"TermStart": claim.TermStart,
+		"Sector":    claim.Sector,
+	}
+	return result, nil
+}
+
+// stateVerifiedClientStatus returns null for a wallet without datacap, like lotus
+func (server *Server) stateVerifiedClientStatus(params []json.RawMessage) (interface{}, error) {
+	var wallet string
+	err := decodeParam(params, 0, &wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	datacap, ok := server.state.datacaps[wallet]
+	if !ok {
+		return nil, nil
+	}
+
+	return datacap.String(), nil
+}
+
+func (server *Server) walletList(params []json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string{}, server.state.wallets...), nil
+}
+
+// walletBalance returns 0 for an address without balance, like lotus for an address not on chain
+func (server *Server) walletBalance(params []json.RawMessage) (interface{}, error) {
+	address, err := decodeAddress(params)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	balance, ok := server.state.balances[address]
+	if !ok {
+		return "0", nil
+	}
+
+	return balance.String(), nil
+}
+
+// walletDefaultAddress answers the undefined address when there is no default wallet, like lotus
+func (server *Server) walletDefaultAddress(params []json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.state.wallet == "" {
+		return ADDRESS_UNDEFINED, nil
+	}
+
+	return server.state.wallet, nil
+}
+
+// walletNew creates an f1 address for secp256k1 and an f3 address for bls
+func (server *Server) walletNew(params []json.RawMessage) (interface{}, error) {
+	var keyType string
+	err := decodeParam(params, 0, &keyType)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	var wallet string
+	switch keyType {
+	case KEY_TYPE_SECP256K1:
+		wallet = fmt.Sprintf("f1fakewallet%d", len(server.state.wallets)+1)
+	case KEY_TYPE_BLS:
+		wallet = fmt.Sprintf("f3fakewallet%d", len(server.state.wallets)+1)
+	default:
+		return nil, fmt.Errorf("unknown key type: %s", keyType)
+	}
+
+	server.state.addWallet(wallet)
+	return wallet, nil
+}
+
+func (server *Server) walletHas(params []json.RawMessage) (interface{}, error) {
+	address, err := decodeAddress(params)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for _, wallet := range server.state.wallets {
+		if wallet == address {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (server *Server) walletValidateAddress(params []json.RawMessage) (interface{}, error) {
+	return decodeAddress(params)
+}
+
+func (server *Server) stateMarketBalance(params []json.RawMessage) (interface{}, error) {
+	address, err := decodeAddress(params)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	escrow := server.state.escrow(address)
+	result := map[string]interface{}{
+		"Escrow": escrow.Escrow.String(),
+		"Locked": escrow.Locked.String(),
+	}
+	return result, nil
+}
+
+// marketAddBalance moves the funds at once with a message landed at the current height,
+// lotus sends a message that lands in a later tipset
+func (server *Server) marketAddBalance(params []json.RawMessage) (interface{}, error) {
+	wallet, address, amount, err := decodeMarketTransfer(params)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	balance := server.state.balance(wallet)
+	if balance.Cmp(amount) < 0 {
+		return nil, fmt.Errorf("not enough funds to execute transaction from %s (free funds: %s)", wallet, balance.String())
+	}
+
+	balance.Sub(balance, amount)
+	escrow := server.state.escrow(address)
+	escrow.Escrow.Add(escrow.Escrow, amount)
+
+	message := server.state.marketMessage(wallet, amount, METHOD_NUM_ADD_BALANCE)
+	return Cid{Cid: message.Cid}, nil
+}
+
+func (server *Server) marketWithdraw(params []json.RawMessage) (interface{}, error) {
+	wallet, address, amount, err := decodeMarketTransfer(params)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	escrow := server.state.escrow(address)
+	available := new(big.Int).Sub(escrow.Escrow, escrow.Locked)
+	if available.Cmp(amount) < 0 {
+		return nil, fmt.Errorf("can't withdraw more funds than available; requested: %s; available: %s", amount.String(), available.String())
+	}
+
+	escrow.Escrow.Sub(escrow.Escrow, amount)
+	balance := server.state.balance(wallet)
+	balance.Add(balance, amount)
+
+	message := server.state.marketMessage(wallet, big.NewInt(0), METHOD_NUM_WITHDRAW_BALANCE)
+	return Cid{Cid: message.Cid}, nil
+}
+
+// marketMessage records the landed message of a transfer of the storage market
+func (state *state) marketMessage(wallet string, value *big.Int, method uint64) *ChainMessage {
+	message := state.addMessage(ChainMessage{
+		From:   wallet,
+		To:     ADDRESS_STORAGE_MARKET,
+		Nonce:  state.nonce(wallet),
+		Value:  value.String(),
+		Method: method,
+	})
+	message.Landed = true
+	message.Height = state.height
+	return message
+}
+
+func (server *Server) marketGetReserved(params []json.RawMessage) (interface{}, error) {
+	address, err := decodeAddress(params)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	reserved, ok := server.state.reserved[address]
+	if !ok {
+		return "0", nil
+	}
+
+	return reserved.String(), nil
+}
+
+// stateWaitMsg is StateWaitMsg of the v0 api, (cid, confidence), the v1 one also takes a limit and allowReplaced
+func (server *Server) stateWaitMsg(params []json.RawMessage) (interface{}, error) {
+	return server.waitMsg(METHOD_STATE_WAIT_MSG, params, false)
+}
+
+// stateWaitMsgLimited is StateWaitMsgLimited of the v0 api, (cid, confidence, limit)
+func (server *Server) stateWaitMsgLimited(params []json.RawMessage) (interface{}, error) {
+	return server.waitMsg(METHOD_STATE_WAIT_MSG_LIMITED, params, true)
+}
+
+// waitMsg polls until the message has landed with confidence epochs on top of it, a message landed more than
+// the lookback limit before the call is not found. Lotus waits as long as the client does, the fake gives up
+// after WaitMsgTimeout.
+func (server *Server) waitMsg(method string, params []json.RawMessage, limited bool) (interface{}, error) {
+	paramCount := 2
+	if limited {
+		paramCount = 3
+	}
+	err := checkParamCount(method, params, paramCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgCid Cid
+	var confidence int64
+	lookbackLimit := int64(LOOKBACK_NO_LIMIT)
+	err = decodeParam(params, 0, &msgCid)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeParam(params, 1, &confidence)
+	if err != nil {
+		return nil, err
+	}
+	if limited {
+		err = decodeParam(params, 2, &lookbackLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := server.WaitMsgTimeout
+	if timeout <= 0 {
+		timeout = STATE_WAIT_MSG_TIMEOUT_DEFAULT
+	}
+
+	server.mutex.Lock()
+	startHeight := server.state.height
+	server.mutex.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		server.mutex.Lock()
+		message := server.state.message(msgCid.Cid)
+		if message != nil && message.Landed && server.state.height >= message.Height+confidence &&
+			(lookbackLimit < 0 || message.Height >= startHeight-lookbackLimit) {
+			lookup := message.lookup()
+			server.mutex.Unlock()
+			return lookup, nil
+		}
+		server.mutex.Unlock()
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("waiting for message %s: timeout", msgCid.Cid)
+		}
+		time.Sleep(STATE_WAIT_MSG_POLL_INTERVAL)
+	}
+}
+
+// stateSearchMsg is StateSearchMsg of the v0 api, (cid), the v1 one also takes a tipset, a limit and allowReplaced
+func (server *Server) stateSearchMsg(params []json.RawMessage) (interface{}, error) {
+	return server.searchMsg(METHOD_STATE_SEARCH_MSG, params, false)
+}
+
+// stateSearchMsgLimited is StateSearchMsgLimited of the v0 api, (cid, limit)
+func (server *Server) stateSearchMsgLimited(params []json.RawMessage) (interface{}, error) {
+	return server.searchMsg(METHOD_STATE_SEARCH_MSG_LIMITED, params, true)
+}
+
+// searchMsg returns null for a message not landed within the lookback limit, like lotus
+func (server *Server) searchMsg(method string, params []json.RawMessage, limited bool) (interface{}, error) {
+	paramCount := 1
+	if limited {
+		paramCount = 2
+	}
+	err := checkParamCount(method, params, paramCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgCid Cid
+	lookbackLimit := int64(LOOKBACK_NO_LIMIT)
+	err = decodeParam(params, 0, &msgCid)
+	if err != nil {
+		return nil, err
+	}
+	if limited {
+		err = decodeParam(params, 1, &lookbackLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	message := server.state.message(msgCid.Cid)
+	if message == nil || !message.Landed {
+		return nil, nil
+	}
+	if lookbackLimit >= 0 && message.Height < server.state.height-lookbackLimit {
+		return nil, nil
+	}
+
+	return message.lookup(), nil
+}
+
+func (server *Server) mpoolPending(params []json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	signedMessages := []map[string]interface{}{}
+	for _, message := range server.state.messages {
+		if message.Landed {
+			continue
+		}
+
+		signedMessages = append(signedMessages, map[string]interface{}{
+			"Message": map[string]interface{}{
+				"Version":    0,
+				"To":         message.To,
+				"From":       message.From,
+				"Nonce":      message.Nonce,
+				"Value":      message.Value,
+				"GasLimit":   GAS_LIMIT_ESTIMATE,
+				"GasFeeCap":  GAS_FEE_CAP_ESTIMATE,
+				"GasPremium": GAS_PREMIUM_ESTIMATE,
+				"Method":     message.Method,
+				"Params":     nil,
+			},
+			"Signature": map[string]interface{}{
+				"Type": 1,
+				"Data": []byte("fake signature"),
+			},
+			"CID": Cid{Cid: message.Cid},
+		})
+	}
+
+	return signedMessages, nil
+}
+
+// gasEstimateMessageGas fills the nonce and the gas fields left unset with fixed estimates
+func (server *Server) gasEstimateMessageGas(params []json.RawMessage) (interface{}, error) {
+	message := map[string]interface{}{}
+	err := decodeParam(params, 0, &message)
+	if err != nil {
+		return nil, err
+	}
+
+	from, _ := message["From"].(string)
+	if !addressPattern.MatchString(from) {
+		return nil, &RpcError{Code: RPC_CODE_INVALID_PARAMS, Message: fmt.Sprintf("invalid address: %s", from)}
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	message["Nonce"] = server.state.nonce(from)
+	if gasLimit, _ := message["GasLimit"].(float64); gasLimit == 0 {
+		message["GasLimit"] = GAS_LIMIT_ESTIMATE
+	}
+	if gasFeeCap, _ := message["GasFeeCap"].(string); gasFeeCap == "" || gasFeeCap == "0" {
+		message["GasFeeCap"] = GAS_FEE_CAP_ESTIMATE
+	}
+	if gasPremium, _ := message["GasPremium"].(string); gasPremium == "" || gasPremium == "0" {
+		message["GasPremium"] = GAS_PREMIUM_ESTIMATE
+	}
+
+	return message, nil
+}
+
+func (message *ChainMessage) lookup() map[string]interface{} {
+	result := map[string]interface{}{
+		"Message": Cid{Cid: message.Cid},
+		"Receipt": map[string]interface{}{
+			"ExitCode":   message.ExitCode,
+			"Return":     message.Return,
+			"GasUsed":    message.GasUsed,
+			"EventsRoot": nil,
+		},
+		"ReturnDec": nil,
+		"TipSet":    []Cid{{Cid: fmt.Sprintf("bafyfaketipset%d", message.Height)}},
+		"Height":    message.Height,
+	}
+	return result
+}
+
+// decodeMarketTransfer decodes the wallet, the address and the amount of MarketAddBalance and MarketWithdraw
+func decodeMarketTransfer(params []json.RawMessage) (string, string, *big.Int, error) {
+	wallet, err := decodeAddress(params)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	address, err := decodeAddress(params[1:])
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var amountText string
+	err = decodeParam(params, 2, &amountText)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	amount, ok := new(big.Int).SetString(amountText, 10)
+	if !ok || amount.Sign() < 0 {
+		return "", "", nil, &RpcError{Code: RPC_CODE_INVALID_PARAMS, Message: fmt.Sprintf("invalid amount: %s", amountText)}
+	}
+
+	return wallet, address, amount, nil
+}
+
+// decodeAddress decodes the address of the first parameter, failing like lotus when it is invalid
+func decodeAddress(params []json.RawMessage) (string, error) {
+	var address string
+	err := decodeParam(params, 0, &address)
+	if err != nil {
+		return "", err
+	}
+
+	if !addressPattern.MatchString(address) {
+		return "", &RpcError{Code: RPC_CODE_INVALID_PARAMS, Message: fmt.Sprintf("invalid address: %s", address)}
+	}
+
+	return address, nil
+}
+
+func (server *Server) marketGetAsk(params []json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.state.marketAsk == nil {
+		return nil, fmt.Errorf("no ask configured")
+	}
+
+	ask := askResult("", *server.state.marketAsk)
+	ask["Timestamp"] = server.state.height
+	ask["Expiry"] = server.state.height + 10000
+	ask["SeqNo"] = 0
+
+	result := map[string]interface{}{
+		"Ask": ask,
+	}
+	return result, nil
+}
+
+func (server *Server) marketListIncompleteDeals(params []json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	deals := []map[string]interface{}{}
+	for _, deal := range server.state.marketDeals {
+		deals = append(deals, map[string]interface{}{
+			"ProposalCid": Cid{Cid: deal.ProposalCid},
+			"State":       deal.State,
+			"Message":     deal.Message,
+			"DealID":      deal.DealID,
+			"Proposal": map[string]interface{}{
+				"Client":   deal.Client,
+				"Provider": deal.Provider,
+			},
+		})
+	}
+
+	return deals, nil
+}
+
+func (server *Server) marketImportDealData(params []json.RawMessage) (interface{}, error) {
+	var proposalCid Cid
+	var path string
+	err := decodeParam(params, 0, &proposalCid)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeParam(params, 1, &path)
+	if err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	found := false
+	for _, deal := range server.state.marketDeals {
+		if deal.ProposalCid == proposalCid.Cid {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to get deal %s: deal not found", proposalCid.Cid)
+	}
+
+	server.state.imports = append(server.state.imports, ImportedData{ProposalCid: proposalCid.Cid, Path: path})
+	return nil, nil
+}
+
+// Methods returns the methods the server answers
+func (server *Server) Methods() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	methods := []string{}
+	for method := range server.handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	return methods
+}
@@ -0,0 +1,290 @@
+// Package lotustest provides an in-process fake lotus node speaking JSON-RPC over HTTP,
+// with programmable state and fault injection, for hermetic tests of code using the lotus package.
+package lotustest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-swan-lib/internal/faketest"
+)
+
+const (
+	PERM_READ  = "read"
+	PERM_WRITE = "write"
+	PERM_SIGN  = "sign"
+	PERM_ADMIN = "admin"
+
+	RPC_CODE_PARSE_ERROR      = -32700
+	RPC_CODE_METHOD_NOT_FOUND = -32601
+	RPC_CODE_INVALID_PARAMS   = -32602
+	RPC_CODE_SERVER_ERROR     = 1
+)
+
+// RpcError is returned by a handler to answer with a JSON-RPC error
+type RpcError = faketest.RpcError
+
+// Handler answers a JSON-RPC method, a returned error that is not a *RpcError is sent with RPC_CODE_SERVER_ERROR
+type Handler func(params []json.RawMessage) (interface{}, error)
+
+// Fault is injected in the answers to a method, besides Delay, HttpStatus and Drop it applies RpcError
+type Fault = faketest.Fault
+
+type request = faketest.Request
+
+type response struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Id      interface{} `json:"id"`
+	Result  interface{} `json:"result"`
+	Error   *RpcError   `json:"error,omitempty"`
+}
+
+// Server is a fake lotus node, it is safe for concurrent use
+type Server struct {
+	URL string // base url of the node, the JSON-RPC endpoint is URL + "/rpc/v0"
+
+	// DisableBatch makes the server reject JSON-RPC batches like an old node
+	DisableBatch bool
+	// WaitMsgTimeout bounds the wait of StateWaitMsg, 0 means STATE_WAIT_MSG_TIMEOUT_DEFAULT
+	WaitMsgTimeout time.Duration
+
+	faketest.Faults
+
+	httpServer *httptest.Server
+	mutex      sync.Mutex
+	handlers   map[string]Handler
+	tokens     map[string][]string
+	state      *state
+}
+
+// NewServer starts a fake lotus node with an empty chain at height 0 and no authentication
+func NewServer() *Server {
+	server := &Server{
+		handlers: map[string]Handler{},
+		tokens:   map[string][]string{},
+		state:    newState(),
+	}
+	server.registerMethods()
+
+	server.httpServer = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	server.URL = server.httpServer.URL
+
+	return server
+}
+
+// ApiUrl is the JSON-RPC endpoint to configure LotusClient and LotusMarket with
+func (server *Server) ApiUrl() string {
+	return server.URL + "/rpc/v0"
+}
+
+func (server *Server) Close() {
+	server.httpServer.Close()
+}
+
+// Handle replaces the handler of method, it may be used for methods the server does not implement
+func (server *Server) Handle(method string, handler Handler) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.handlers[method] = handler
+}
+
+// InjectFault makes the calls to method fail, an empty method affects all the methods
+func (server *Server) InjectFault(method string, fault Fault) {
+	server.SetFault(method, fault)
+}
+
+// AddToken enables authentication: once a token is added, requests with an unknown bearer token are rejected,
+// requests without a token can only read, and methods changing state need the write permission
+func (server *Server) AddToken(token string, perms ...string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.tokens[token] = perms
+}
+
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	perms, ok := server.authenticate(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		server.serveBatch(w, body, perms)
+		return
+	}
+
+	req := &request{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		writeResponse(w, response{JsonRpc: "2.0", Error: &RpcError{Code: RPC_CODE_PARSE_ERROR, Message: err.Error()}})
+		return
+	}
+
+	fault := server.TakeFault(req.Method)
+	if fault.Interrupt(w) {
+		return
+	}
+
+	writeResponse(w, server.answer(req, perms, fault))
+}
+
+func (server *Server) serveBatch(w http.ResponseWriter, body []byte, perms []string) {
+	if server.DisableBatch {
+		writeJson(w, response{JsonRpc: "2.0", Error: &RpcError{Code: RPC_CODE_PARSE_ERROR, Message: "batch requests are not supported"}})
+		return
+	}
+
+	reqs := []*request{}
+	err := json.Unmarshal(body, &reqs)
+	if err != nil {
+		writeJson(w, response{JsonRpc: "2.0", Error: &RpcError{Code: RPC_CODE_PARSE_ERROR, Message: err.Error()}})
+		return
+	}
+
+	resps := []response{}
+	for _, req := range reqs {
+		fault := server.TakeFault(req.Method)
+		// transport faults affect the whole batch
+		if fault.Interrupt(w) {
+			return
+		}
+
+		resps = append(resps, server.answer(req, perms, fault))
+	}
+
+	writeJson(w, resps)
+}
+
+func (server *Server) answer(req *request, perms []string, fault *Fault) response {
+	resp := response{
+		JsonRpc: "2.0",
+		Id:      req.Id,
+	}
+
+	if fault != nil && fault.RpcError != nil {
+		resp.Error = fault.RpcError
+		return resp
+	}
+
+	server.mutex.Lock()
+	handler := server.handlers[req.Method]
+	server.mutex.Unlock()
+
+	if handler == nil {
+		resp.Error = &RpcError{Code: RPC_CODE_METHOD_NOT_FOUND, Message: fmt.Sprintf("method '%s' not found", req.Method)}
+		return resp
+	}
+
+	if perms != nil && writeMethods[req.Method] && !hasPerm(perms, PERM_WRITE) {
+		methodName := strings.TrimPrefix(req.Method, "Filecoin.")
+		resp.Error = &RpcError{Code: RPC_CODE_SERVER_ERROR, Message: fmt.Sprintf("missing permission to invoke '%s' (need '%s')", methodName, PERM_WRITE)}
+		return resp
+	}
+
+	result, err := handler(req.Params)
+	if err != nil {
+		rpcError, ok := err.(*RpcError)
+		if !ok {
+			rpcError = &RpcError{Code: RPC_CODE_SERVER_ERROR, Message: err.Error()}
+		}
+		resp.Error = rpcError
+		return resp
+	}
+
+	resp.Result = result
+	return resp
+}
+
+// authenticate returns the permissions of the bearer token, nil when authentication is disabled
+func (server *Server) authenticate(r *http.Request) ([]string, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(server.tokens) == 0 {
+		return nil, true
+	}
+
+	// like lotus, requests without a token can read
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return []string{PERM_READ}, true
+	}
+
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	perms, ok := server.tokens[token]
+	if !ok {
+		return nil, false
+	}
+
+	return append([]string{}, perms...), true
+}
+
+func hasPerm(perms []string, perm string) bool {
+	for _, p := range perms {
+		if p == perm || p == PERM_ADMIN {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeJson(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeResponse answers a single request, like lotus the requests it cannot dispatch are answered with http 500
+func writeResponse(w http.ResponseWriter, resp response) {
+	if resp.Error != nil {
+		switch resp.Error.Code {
+		case RPC_CODE_PARSE_ERROR, RPC_CODE_METHOD_NOT_FOUND, RPC_CODE_INVALID_PARAMS:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+	}
+
+	writeJson(w, resp)
+}
+
+// checkParamCount fails like lotus does when a method is called with another number of params than it takes
+func checkParamCount(method string, params []json.RawMessage, count int) error {
+	if len(params) != count {
+		return &RpcError{Code: RPC_CODE_INVALID_PARAMS, Message: fmt.Sprintf("wrong param count (method '%s'): %d != %d", method, len(params), count)}
+	}
+
+	return nil
+}
+
+func decodeParam(params []json.RawMessage, index int, value interface{}) error {
+	if index >= len(params) {
+		return &RpcError{Code: RPC_CODE_INVALID_PARAMS, Message: fmt.Sprintf("missing param %d", index)}
+	}
+
+	err := json.Unmarshal(params[index], value)
+	if err != nil {
+		return &RpcError{Code: RPC_CODE_INVALID_PARAMS, Message: fmt.Sprintf("invalid param %d: %s", index, err.Error())}
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package lotus
+
+import (
+	"os"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+// TestMain silences the library so that the tests do not write logs under the package directory
+func TestMain(m *testing.M) {
+	logs.SetLogger(logs.NopLogger{})
+	os.Exit(m.Run())
+}
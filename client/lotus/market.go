@@ -1,13 +1,12 @@
 package lotus
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/logs"
-	"github.com/filswan/go-swan-lib/utils"
 )
 
 const (
@@ -20,6 +19,9 @@ type LotusMarket struct {
 	ApiUrl       string
 	AccessToken  string
 	ClientApiUrl string
+	HttpClient   *web.Client      // nil means web.DefaultClient()
+	RetryPolicy  *web.RetryPolicy // nil means the retry policy of HttpClient
+	Transport    RpcCaller        // e.g. a WsClient, nil means JSON-RPC over HTTP
 }
 
 type MarketGetAsk struct {
@@ -43,7 +45,7 @@ type MarketGetAskResultAsk struct {
 func GetLotusMarket(apiUrl, accessToken, clientApiUrl string) (*LotusMarket, error) {
 	if len(apiUrl) == 0 {
 		err := fmt.Errorf("lotus market api url is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -56,34 +58,42 @@ func GetLotusMarket(apiUrl, accessToken, clientApiUrl string) (*LotusMarket, err
 	return lotusMarket, nil
 }
 
-// "lotus client query-ask " + minerFid
-func (lotusMarket *LotusMarket) LotusMarketGetAsk() (*MarketGetAskResultAsk, error) {
-	var params []interface{}
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_MARKET_GET_ASK,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
+func (lotusMarket *LotusMarket) rpcClient() RpcCaller {
+	if lotusMarket.Transport != nil {
+		return lotusMarket.Transport
 	}
 
-	//here the api url should be miner's api url, need to change later on
-	response, err := web.HttpGetNoToken(lotusMarket.ApiUrl, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
+	rpcClient := NewRpcClient(lotusMarket.ApiUrl, lotusMarket.AccessToken)
+	rpcClient.HttpClient = lotusMarket.HttpClient
+	rpcClient.RetryPolicy = lotusMarket.RetryPolicy
+	return rpcClient
+}
+
+// dealStatusName returns the name of a deal state from StorageDealStatus, asking the lotus client at ClientApiUrl
+// only for a state not known, when ClientApiUrl is set
+func (lotusMarket *LotusMarket) dealStatusName(ctx context.Context, state int) (*string, error) {
+	if lotusMarket.ClientApiUrl == "" {
+		name := StorageDealStatus(state).String()
+		return &name, nil
 	}
 
+	rpcClient := NewRpcClient(lotusMarket.ClientApiUrl, "")
+	rpcClient.HttpClient = lotusMarket.HttpClient
+	rpcClient.RetryPolicy = lotusMarket.RetryPolicy
+	return dealStatusName(ctx, rpcClient, state)
+}
+
+// "lotus client query-ask " + minerFid
+func (lotusMarket *LotusMarket) LotusMarketGetAsk() (*MarketGetAskResultAsk, error) {
+	return lotusMarket.LotusMarketGetAskWithContext(context.Background())
+}
+
+func (lotusMarket *LotusMarket) LotusMarketGetAskWithContext(ctx context.Context) (*MarketGetAskResultAsk, error) {
 	marketGetAsk := &MarketGetAsk{}
-	err = json.Unmarshal(response, marketGetAsk)
+	//here the api url should be miner's api url, need to change later on
+	err := lotusMarket.rpcClient().Call(ctx, LOTUS_MARKET_GET_ASK, &marketGetAsk.Result)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	if marketGetAsk.Error != nil {
-		err := fmt.Errorf("%d,%s", marketGetAsk.Error.Code, marketGetAsk.Error.Message)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -95,10 +105,10 @@ type DealCid struct {
 }
 
 type MarketListIncompleteDeals struct {
-	Id      int           `json:"id"`
-	JsonRpc string        `json:"jsonrpc"`
-	Result  []Deal        `json:"result"`
-	Error   *JsonRpcError `json:"error"`
+	Id      int       `json:"id"`
+	JsonRpc string    `json:"jsonrpc"`
+	Result  []Deal    `json:"result"`
+	Error   *RPCError `json:"error"`
 }
 
 type Deal struct {
@@ -113,24 +123,14 @@ type Deal struct {
 }
 
 func (lotusMarket *LotusMarket) LotusGetDeals() ([]Deal, error) {
-	var params []interface{}
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_MARKET_LIST_INCOMPLETE_DEALS,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
-
-	response, err := web.HttpGet(lotusMarket.ApiUrl, lotusMarket.AccessToken, jsonRpcParams)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
+	return lotusMarket.LotusGetDealsWithContext(context.Background())
+}
 
+func (lotusMarket *LotusMarket) LotusGetDealsWithContext(ctx context.Context) ([]Deal, error) {
 	deals := &MarketListIncompleteDeals{}
-	err = json.Unmarshal(response, deals)
-	if err != nil {
-		logs.GetLogger().Error(err)
+	err := lotusMarket.rpcClient().Call(ctx, LOTUS_MARKET_LIST_INCOMPLETE_DEALS, &deals.Result)
+	if err != nil && !errors.Is(err, ErrNilResult) {
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -138,25 +138,26 @@ func (lotusMarket *LotusMarket) LotusGetDeals() ([]Deal, error) {
 }
 
 func (lotusMarket *LotusMarket) LotusGetDealOnChainStatusFromDeals(deals []Deal, dealCid string) (string, uint64, *string, *string, error) {
+	return lotusMarket.LotusGetDealOnChainStatusFromDealsWithContext(context.Background(), deals, dealCid)
+}
+
+func (lotusMarket *LotusMarket) LotusGetDealOnChainStatusFromDealsWithContext(ctx context.Context, deals []Deal, dealCid string) (string, uint64, *string, *string, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_DEAL_CID: dealCid})
+
 	if len(deals) == 0 {
 		err := fmt.Errorf("deal list is empty")
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return "", 0, nil, nil, err
 	}
 
-	lotusClient, err := LotusGetClient(lotusMarket.ClientApiUrl, "")
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return "", 0, nil, nil, err
-	}
 	for _, deal := range deals {
 		if deal.ProposalCid.DealCid != dealCid {
 			continue
 		}
 
-		status, err := lotusClient.LotusGetDealStatus(deal.State)
+		status, err := lotusMarket.dealStatusName(ctx, deal.State)
 		if err != nil {
-			logs.GetLogger().Error(err)
+			logger.Error(err)
 			return "", 0, nil, nil, err
 		}
 
@@ -168,51 +169,42 @@ func (lotusMarket *LotusMarket) LotusGetDealOnChainStatusFromDeals(deals []Deal,
 
 // "lotus-miner storage-deals list -v | grep -a " + dealCid
 func (lotusMarket *LotusMarket) LotusGetDealOnChainStatus(dealCid string) (string, uint64, *string, *string, error) {
-	deals, err := lotusMarket.LotusGetDeals()
+	return lotusMarket.LotusGetDealOnChainStatusWithContext(context.Background(), dealCid)
+}
+
+func (lotusMarket *LotusMarket) LotusGetDealOnChainStatusWithContext(ctx context.Context, dealCid string) (string, uint64, *string, *string, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_DEAL_CID: dealCid})
+
+	deals, err := lotusMarket.LotusGetDealsWithContext(ctx)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return "", 0, nil, nil, err
 	}
 
-	minerId, dealId, status, message, err := lotusMarket.LotusGetDealOnChainStatusFromDeals(deals, dealCid)
+	minerId, dealId, status, message, err := lotusMarket.LotusGetDealOnChainStatusFromDealsWithContext(ctx, deals, dealCid)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return "", 0, nil, nil, err
 	}
 	return minerId, dealId, status, message, nil
 }
 
 func (lotusMarket *LotusMarket) LotusImportData(dealCid string, filepath string) error {
-	var params []interface{}
-	getDealInfoParam := DealCid{DealCid: dealCid}
-	params = append(params, getDealInfoParam)
-	params = append(params, filepath)
-
-	jsonRpcParams := LotusJsonRpcParams{
-		JsonRpc: LOTUS_JSON_RPC_VERSION,
-		Method:  LOTUS_MARKET_IMPORT_DATA,
-		Params:  params,
-		Id:      LOTUS_JSON_RPC_ID,
-	}
+	return lotusMarket.LotusImportDataWithContext(context.Background(), dealCid, filepath)
+}
+
+func (lotusMarket *LotusMarket) LotusImportDataWithContext(ctx context.Context, dealCid string, filepath string) error {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_DEAL_CID: dealCid})
 
-	response, err := web.HttpPost(lotusMarket.ApiUrl, lotusMarket.AccessToken, jsonRpcParams)
+	getDealInfoParam := DealCid{DealCid: dealCid}
+	err := lotusMarket.rpcClient().Call(ctx, LOTUS_MARKET_IMPORT_DATA, nil, getDealInfoParam, filepath)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		if errors.Is(err, web.ErrPermissionDenied) {
+			logger.Error("please check your access token, it should have write access")
+		}
+		logger.Error(err)
 		return err
 	}
 
-	errorInfo := utils.GetFieldMapFromJson(response, "error")
-
-	if errorInfo == nil {
-		return nil
-	}
-
-	errCode := int(errorInfo["code"].(float64))
-	errMsg := errorInfo["message"].(string)
-	err = fmt.Errorf("error code:%d message:%s", errCode, errMsg)
-	if strings.Contains(string(response), "(need 'write')") {
-		logs.GetLogger().Error("please check your access token, it should have write access")
-		logs.GetLogger().Error(err)
-	}
-	return err
+	return nil
 }
@@ -0,0 +1,187 @@
+package lotus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	// the v0 api only takes a lookback limit in the limited variants, which match replaced messages like
+	// StateWaitMsg and StateSearchMsg of the v1 api do with allowReplaced
+	LOTUS_STATE_WAIT_MSG_LIMITED   = "Filecoin.StateWaitMsgLimited"
+	LOTUS_STATE_SEARCH_MSG_LIMITED = "Filecoin.StateSearchMsgLimited"
+	LOTUS_MPOOL_PENDING            = "Filecoin.MpoolPending"
+	LOTUS_GAS_ESTIMATE_MESSAGE_GAS = "Filecoin.GasEstimateMessageGas"
+
+	// epochs after its inclusion before a message is considered final, the default of lotus
+	MESSAGE_CONFIDENCE_DEFAULT = 5
+	// lookback limit searching the whole chain for a message
+	MESSAGE_LOOKBACK_NO_LIMIT = -1
+
+	EXIT_CODE_OK = 0
+)
+
+// ErrMessageFailed is matched by the error of a receipt with a non zero exit code
+var ErrMessageFailed = errors.New("message execution failed")
+
+// Message is a chain message, the amounts are strings of attoFIL like lotus encodes them
+type Message struct {
+	Version    uint64
+	To         string
+	From       string
+	Nonce      uint64
+	Value      string
+	GasLimit   int64
+	GasFeeCap  string
+	GasPremium string
+	Method     uint64
+	Params     []byte
+}
+
+type Signature struct {
+	Type int
+	Data []byte
+}
+
+type SignedMessage struct {
+	Message   Message
+	Signature Signature
+	CID       Cid
+}
+
+type MessageReceipt struct {
+	ExitCode   int64
+	Return     []byte // the raw return value of the method, cbor encoded
+	GasUsed    int64
+	EventsRoot *Cid
+}
+
+// MsgLookup is a message included on chain with its receipt, Message is the cid of the message executed,
+// another one than the cid looked up when the message was replaced
+type MsgLookup struct {
+	Message   Cid
+	Receipt   MessageReceipt
+	ReturnDec json.RawMessage // the return value decoded by lotus, when it can
+	TipSet    []Cid
+	Height    int64
+}
+
+// MessageSendSpec bounds the fee of a message
+type MessageSendSpec struct {
+	MaxFee string // attoFIL, empty or 0 means the default of the node
+}
+
+// Err returns an error matching ErrMessageFailed when the message failed, nil when it succeeded
+func (receipt *MessageReceipt) Err() error {
+	if receipt.ExitCode == EXIT_CODE_OK {
+		return nil
+	}
+
+	return fmt.Errorf("exit code:%d, %w", receipt.ExitCode, ErrMessageFailed)
+}
+
+// StateWaitMsg waits for the message of msgCid to be included on chain with confidence epochs on top of it,
+// searching back at most lookbackLimit epochs, MESSAGE_LOOKBACK_NO_LIMIT means the whole chain.
+// The wait is only bounded by ctx and by the timeout of the http client, which must be long enough for it.
+// A message replaced with a higher fee is waited for under the cid of its replacement.
+// It calls StateWaitMsgLimited of the /rpc/v0 api, which the v1 api does not have.
+func (lotusClient *LotusClient) StateWaitMsg(msgCid string, confidence uint64, lookbackLimit int64) (*MsgLookup, error) {
+	return lotusClient.StateWaitMsgWithContext(context.Background(), msgCid, confidence, lookbackLimit)
+}
+
+func (lotusClient *LotusClient) StateWaitMsgWithContext(ctx context.Context, msgCid string, confidence uint64, lookbackLimit int64) (*MsgLookup, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MSG_CID: msgCid})
+
+	msgLookup := &MsgLookup{}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_STATE_WAIT_MSG_LIMITED, msgLookup, Cid{Cid: strings.Trim(msgCid, " ")}, confidence, lookbackLimit)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return msgLookup, nil
+}
+
+// StateSearchMsg looks for the message of msgCid on chain without waiting, searching back at most lookbackLimit epochs
+// from the head. A message not found, e.g. still in the message pool, is returned as nil without error.
+// It calls StateSearchMsgLimited of the /rpc/v0 api, which the v1 api does not have.
+func (lotusClient *LotusClient) StateSearchMsg(msgCid string, lookbackLimit int64) (*MsgLookup, error) {
+	return lotusClient.StateSearchMsgWithContext(context.Background(), msgCid, lookbackLimit)
+}
+
+func (lotusClient *LotusClient) StateSearchMsgWithContext(ctx context.Context, msgCid string, lookbackLimit int64) (*MsgLookup, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MSG_CID: msgCid})
+
+	msgLookup := &MsgLookup{}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_STATE_SEARCH_MSG_LIMITED, msgLookup, Cid{Cid: strings.Trim(msgCid, " ")}, lookbackLimit)
+	if err != nil {
+		if errors.Is(err, ErrNilResult) {
+			return nil, nil
+		}
+		logger.Error(err)
+		return nil, err
+	}
+
+	return msgLookup, nil
+}
+
+// MpoolPending returns the messages of the message pool of the node not yet included on chain
+func (lotusClient *LotusClient) MpoolPending() ([]*SignedMessage, error) {
+	return lotusClient.MpoolPendingWithContext(context.Background())
+}
+
+func (lotusClient *LotusClient) MpoolPendingWithContext(ctx context.Context) ([]*SignedMessage, error) {
+	signedMessages := []*SignedMessage{}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_MPOOL_PENDING, &signedMessages, []interface{}{})
+	if err != nil && !errors.Is(err, ErrNilResult) {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return signedMessages, nil
+}
+
+// GasEstimateMessageGas returns message with its gas limit, gas fee cap and gas premium estimated by the node,
+// a nil sendSpec means the default max fee of the node
+func (lotusClient *LotusClient) GasEstimateMessageGas(message *Message, sendSpec *MessageSendSpec) (*Message, error) {
+	return lotusClient.GasEstimateMessageGasWithContext(context.Background(), message, sendSpec)
+}
+
+func (lotusClient *LotusClient) GasEstimateMessageGasWithContext(ctx context.Context, message *Message, sendSpec *MessageSendSpec) (*Message, error) {
+	if message == nil {
+		err := fmt.Errorf("parameter message is nil")
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	// lotus parses an empty amount as invalid
+	params := *message
+	if params.Value == "" {
+		params.Value = "0"
+	}
+	if params.GasFeeCap == "" {
+		params.GasFeeCap = "0"
+	}
+	if params.GasPremium == "" {
+		params.GasPremium = "0"
+	}
+
+	var spec interface{}
+	if sendSpec != nil && sendSpec.MaxFee != "" {
+		spec = sendSpec
+	}
+
+	estimated := &Message{}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_GAS_ESTIMATE_MESSAGE_GAS, estimated, params, spec, []interface{}{})
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return estimated, nil
+}
@@ -0,0 +1,113 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filswan/go-swan-lib/client/lotus/lotustest"
+)
+
+func TestStateWaitMsg(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.SetHeight(100)
+	msgCid := server.PushMessage(lotustest.ChainMessage{From: "f01000", To: lotustest.ADDRESS_STORAGE_MARKET})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		server.LandMessage(msgCid)
+		server.AdvanceHeight(MESSAGE_CONFIDENCE_DEFAULT)
+	}()
+
+	msgLookup, err := newTestLotusClient(t, server, "").StateWaitMsg(msgCid, MESSAGE_CONFIDENCE_DEFAULT, MESSAGE_LOOKBACK_NO_LIMIT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgLookup.Message.Cid != msgCid || msgLookup.Height != 100 || msgLookup.Receipt.Err() != nil {
+		t.Fatalf("lookup: %+v", msgLookup)
+	}
+	if calls := server.Calls(lotustest.METHOD_STATE_WAIT_MSG_LIMITED); calls != 1 {
+		t.Fatalf("StateWaitMsgLimited calls: got %d, want 1", calls)
+	}
+}
+
+func TestStateWaitMsgFailedReceipt(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	msgCid := server.PushMessage(lotustest.ChainMessage{From: "f01000", To: lotustest.ADDRESS_STORAGE_MARKET, ExitCode: 16})
+	server.LandMessage(msgCid)
+
+	msgLookup, err := newTestLotusClient(t, server, "").StateWaitMsg(msgCid, 0, MESSAGE_LOOKBACK_NO_LIMIT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(msgLookup.Receipt.Err(), ErrMessageFailed) {
+		t.Fatalf("receipt error: got %v, want ErrMessageFailed", msgLookup.Receipt.Err())
+	}
+}
+
+func TestStateSearchMsg(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.SetHeight(100)
+	msgCid := server.PushMessage(lotustest.ChainMessage{From: "f01000", To: lotustest.ADDRESS_STORAGE_MARKET})
+	lotusClient := newTestLotusClient(t, server, "")
+
+	msgLookup, err := lotusClient.StateSearchMsg(msgCid, MESSAGE_LOOKBACK_NO_LIMIT)
+	if err != nil || msgLookup != nil {
+		t.Fatalf("message in the pool: got %+v, %v, want nil", msgLookup, err)
+	}
+
+	server.LandMessage(msgCid)
+	server.AdvanceHeight(20)
+
+	msgLookup, err = lotusClient.StateSearchMsg(msgCid, MESSAGE_LOOKBACK_NO_LIMIT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgLookup == nil || msgLookup.Message.Cid != msgCid || msgLookup.Height != 100 {
+		t.Fatalf("landed message: %+v", msgLookup)
+	}
+
+	msgLookup, err = lotusClient.StateSearchMsg(msgCid, 10)
+	if err != nil || msgLookup != nil {
+		t.Fatalf("message beyond the lookback limit: got %+v, %v, want nil", msgLookup, err)
+	}
+}
+
+// the fake rejects the v1 signatures on the v0 api like lotus does
+func TestMessageMethodsTakeV0Params(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	msgCid := server.PushMessage(lotustest.ChainMessage{From: "f01000", To: lotustest.ADDRESS_STORAGE_MARKET})
+	server.LandMessage(msgCid)
+	rpcClient := newTestLotusClient(t, server, "").rpcClient()
+
+	calls := map[string][]interface{}{
+		lotustest.METHOD_STATE_WAIT_MSG:           {Cid{Cid: msgCid}, 0, MESSAGE_LOOKBACK_NO_LIMIT, true},
+		lotustest.METHOD_STATE_WAIT_MSG_LIMITED:   {Cid{Cid: msgCid}, 0, MESSAGE_LOOKBACK_NO_LIMIT, true},
+		lotustest.METHOD_STATE_SEARCH_MSG:         {[]interface{}{}, Cid{Cid: msgCid}, MESSAGE_LOOKBACK_NO_LIMIT, true},
+		lotustest.METHOD_STATE_SEARCH_MSG_LIMITED: {[]interface{}{}, Cid{Cid: msgCid}, MESSAGE_LOOKBACK_NO_LIMIT, true},
+	}
+	for method, params := range calls {
+		msgLookup := &MsgLookup{}
+		err := rpcClient.Call(context.Background(), method, msgLookup, params...)
+		var rpcError *RPCError
+		if !errors.As(err, &rpcError) || rpcError.Code != lotustest.RPC_CODE_INVALID_PARAMS {
+			t.Fatalf("%s with v1 params: got %v, want invalid params", method, err)
+		}
+	}
+
+	// the v0 methods without limit still answer
+	msgLookup := &MsgLookup{}
+	err := rpcClient.Call(context.Background(), lotustest.METHOD_STATE_SEARCH_MSG, msgLookup, Cid{Cid: msgCid})
+	if err != nil || msgLookup.Message.Cid != msgCid {
+		t.Fatalf("StateSearchMsg: %+v, %v", msgLookup, err)
+	}
+	err = rpcClient.Call(context.Background(), lotustest.METHOD_STATE_WAIT_MSG, msgLookup, Cid{Cid: msgCid}, 0)
+	if err != nil || msgLookup.Message.Cid != msgCid {
+		t.Fatalf("StateWaitMsg: %+v, %v", msgLookup, err)
+	}
+}
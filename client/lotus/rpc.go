@@ -0,0 +1,219 @@
+package lotus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/filswan/go-swan-lib/client/web"
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	LOTUS_JSON_RPC_BATCH_SIZE_DEFAULT = 100
+)
+
+// ErrNilResult is returned by RpcClient.Call when a result is expected but the node returned null
+var ErrNilResult = errors.New("no result returned")
+
+var lastRpcId int64 = LOTUS_JSON_RPC_ID
+
+func nextRpcId() int {
+	return int(atomic.AddInt64(&lastRpcId, 1))
+}
+
+func (rpcError *RPCError) Error() string {
+	if rpcError.Method == "" {
+		return fmt.Sprintf("error, code:%d, message:%s", rpcError.Code, rpcError.Message)
+	}
+	return fmt.Sprintf("%s error, code:%d, message:%s", rpcError.Method, rpcError.Code, rpcError.Message)
+}
+
+// Is matches web.ErrPermissionDenied when the token lacks a permission, web.ErrUnauthorized when the token is invalid,
+// web.ErrNotFound when the requested object does not exist and ErrNotVerifiedClient.
+// lotus only reports these as messages, so this is the one place where they are recognized.
+func (rpcError *RPCError) Is(target error) bool {
+	message := strings.ToLower(rpcError.Message)
+
+	switch target {
+	case web.ErrPermissionDenied:
+		return strings.Contains(message, "missing permission") || strings.Contains(message, "(need '")
+	case web.ErrUnauthorized:
+		return strings.Contains(message, "jwt verification failed") || strings.Contains(message, "unauthorized")
+	case web.ErrNotFound:
+		return strings.Contains(message, "not found")
+	case ErrNotVerifiedClient:
+		return isNotVerifiedClient(message)
+	}
+
+	return false
+}
+
+// nonIdempotentMethods must not be resent blindly, a lost response does not mean the call had no effect
+var nonIdempotentMethods = map[string]bool{
+	LOTUS_CLIENT_START_DEAL:  true,
+	LOTUS_CLIENT_IMPORT:      true,
+	LOTUS_MARKET_IMPORT_DATA: true,
+	LOTUS_WALLET_NEW:         true,
+	LOTUS_MARKET_ADD_BALANCE: true,
+	LOTUS_MARKET_WITHDRAW:    true,
+}
+
+func IsIdempotentMethod(method string) bool {
+	return !nonIdempotentMethods[method]
+}
+
+type RpcClient struct {
+	ApiUrl       string
+	AccessToken  string
+	HttpClient   *web.Client      // nil means web.DefaultClient()
+	RetryPolicy  *web.RetryPolicy // nil means the retry policy of HttpClient
+	MaxBatchSize int              // calls sent in one batch request by CallBatch, 0 means LOTUS_JSON_RPC_BATCH_SIZE_DEFAULT
+}
+
+type rpcResponse struct {
+	Id      int             `json:"id"`
+	JsonRpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+}
+
+func NewRpcClient(apiUrl, accessToken string) *RpcClient {
+	rpcClient := &RpcClient{
+		ApiUrl:      apiUrl,
+		AccessToken: accessToken,
+	}
+
+	return rpcClient
+}
+
+// Call invokes the JSON-RPC method with params and decodes the result into result.
+// A nil result means the caller does not care about the returned value.
+// Errors returned by the node are reported as *RPCError.
+func (rpcClient *RpcClient) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if len(rpcClient.ApiUrl) == 0 {
+		err := fmt.Errorf("api url is required")
+		logs.Log().Error(err)
+		return err
+	}
+
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	jsonRpcParams := LotusJsonRpcParams{
+		JsonRpc: LOTUS_JSON_RPC_VERSION,
+		Method:  method,
+		Params:  params,
+		Id:      nextRpcId(),
+	}
+
+	httpClient, retryPolicy := rpcClient.httpClient()
+	idempotent := IsIdempotentMethod(method)
+
+	err := retryPolicy.Retry(ctx, func(attempt int) (bool, error) {
+		if attempt > 1 {
+			logs.Log().Info("retrying ", method, ", attempt:", attempt)
+		}
+
+		response, err := httpClient.Request(ctx, http.MethodPost, rpcClient.ApiUrl, rpcClient.AccessToken, jsonRpcParams)
+		if rpcError := statusRpcError(err); rpcError != nil {
+			rpcError.Method = method
+			logs.Log().Error(rpcError)
+			return idempotent && retryPolicy.IsRetryableRpcCode(rpcError.Code), rpcError
+		}
+		if err != nil {
+			logs.Log().Error(err)
+			return idempotent && retryPolicy.IsRetryableError(err), err
+		}
+
+		err = decodeRpcResponse(response, jsonRpcParams, result)
+		var rpcError *RPCError
+		if errors.As(err, &rpcError) {
+			return idempotent && retryPolicy.IsRetryableRpcCode(rpcError.Code), err
+		}
+
+		return false, err
+	})
+
+	return err
+}
+
+// httpClient returns the client without retries and the policy to retry with,
+// retries are done by the caller so that JSON-RPC errors can be retried as well
+func (rpcClient *RpcClient) httpClient() (*web.Client, *web.RetryPolicy) {
+	httpClient := rpcClient.HttpClient
+	if httpClient == nil {
+		httpClient = web.DefaultClient()
+	}
+
+	retryPolicy := rpcClient.RetryPolicy
+	if retryPolicy == nil {
+		retryPolicy = httpClient.RetryPolicy()
+	}
+
+	return httpClient.WithRetryPolicy(nil), retryPolicy
+}
+
+// statusRpcError returns the JSON-RPC error sent in the body of an http error, like the 500 of lotus
+// for an unknown method or wrong params, nil when err is not such an error
+func statusRpcError(err error) *RPCError {
+	var statusErr *web.HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		return nil
+	}
+
+	rpcResp := &rpcResponse{}
+	if json.Unmarshal(statusErr.Body, rpcResp) != nil {
+		return nil
+	}
+
+	return rpcResp.Error
+}
+
+func decodeRpcResponse(response []byte, jsonRpcParams LotusJsonRpcParams, result interface{}) error {
+	rpcResp := &rpcResponse{}
+	err := json.Unmarshal(response, rpcResp)
+	if err != nil {
+		err := fmt.Errorf("%s, failed to parse response:%s", jsonRpcParams.Method, err.Error())
+		logs.Log().Error(err)
+		return err
+	}
+
+	return decodeRpcResult(rpcResp, jsonRpcParams, result)
+}
+
+func decodeRpcResult(rpcResp *rpcResponse, jsonRpcParams LotusJsonRpcParams, result interface{}) error {
+	if rpcResp.Error != nil {
+		rpcResp.Error.Method = jsonRpcParams.Method
+		logs.Log().Error(rpcResp.Error)
+		return rpcResp.Error
+	}
+
+	if rpcResp.Id != jsonRpcParams.Id {
+		err := fmt.Errorf("%s, response id:%d does not match request id:%d", jsonRpcParams.Method, rpcResp.Id, jsonRpcParams.Id)
+		logs.Log().Error(err)
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if len(rpcResp.Result) == 0 || string(rpcResp.Result) == "null" {
+		return fmt.Errorf("%s, %w", jsonRpcParams.Method, ErrNilResult)
+	}
+
+	err := json.Unmarshal(rpcResp.Result, result)
+	if err != nil {
+		err := fmt.Errorf("%s, failed to parse result:%s", jsonRpcParams.Method, err.Error())
+		logs.Log().Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/filswan/go-swan-lib/client/lotus/lotustest"
+	"github.com/filswan/go-swan-lib/client/web"
+)
+
+const testRpcCodeBusy = 42
+
+func newTestRetryPolicy() *web.RetryPolicy {
+	retryPolicy := web.DefaultRetryPolicy()
+	retryPolicy.InitialBackoff = time.Millisecond
+	retryPolicy.MaxBackoff = time.Millisecond
+	retryPolicy.RetryableRpcCodes = []int{testRpcCodeBusy}
+	return retryPolicy
+}
+
+func newTestLotusClient(t *testing.T, server *lotustest.Server, accessToken string) *LotusClient {
+	lotusClient, err := LotusGetClient(server.ApiUrl(), accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lotusClient.RetryPolicy = newTestRetryPolicy()
+
+	return lotusClient
+}
+
+func TestRpcClientRetriesIdempotentCalls(t *testing.T) {
+	faults := map[string]lotustest.Fault{
+		"http status": {HttpStatus: http.StatusServiceUnavailable, Times: 2},
+		"dropped":     {Drop: true, Times: 2},
+		"rpc code":    {RpcError: &lotustest.RpcError{Code: testRpcCodeBusy, Message: "busy"}, Times: 2},
+	}
+
+	for name, fault := range faults {
+		t.Run(name, func(t *testing.T) {
+			server := lotustest.NewServer()
+			defer server.Close()
+			server.SetHeight(1234)
+			server.InjectFault(lotustest.METHOD_CHAIN_HEAD, fault)
+
+			height, err := newTestLotusClient(t, server, "").LotusGetCurrentEpoch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *height != 1234 {
+				t.Fatalf("height: got %d, want 1234", *height)
+			}
+			if calls := server.Calls(lotustest.METHOD_CHAIN_HEAD); calls != 3 {
+				t.Fatalf("ChainHead calls: got %d, want 3", calls)
+			}
+		})
+	}
+}
+
+func TestRpcClientGivesUpAfterMaxAttempts(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.InjectFault(lotustest.METHOD_CHAIN_HEAD, lotustest.Fault{HttpStatus: http.StatusServiceUnavailable})
+
+	lotusClient := newTestLotusClient(t, server, "")
+	_, err := lotusClient.LotusGetCurrentEpoch()
+	var statusErr *web.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want http status 503", err)
+	}
+	if calls := server.Calls(lotustest.METHOD_CHAIN_HEAD); calls != lotusClient.RetryPolicy.MaxAttempts {
+		t.Fatalf("ChainHead calls: got %d, want %d", calls, lotusClient.RetryPolicy.MaxAttempts)
+	}
+}
+
+func TestRpcClientDoesNotRetryNonIdempotentCalls(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.InjectFault(lotustest.METHOD_WALLET_NEW, lotustest.Fault{Drop: true, Times: 1})
+
+	_, err := newTestLotusClient(t, server, "").WalletNew(lotustest.KEY_TYPE_SECP256K1)
+	if err == nil {
+		t.Fatal("dropped WalletNew reported success")
+	}
+	if calls := server.Calls(lotustest.METHOD_WALLET_NEW); calls != 1 {
+		t.Fatalf("WalletNew calls: got %d, want 1", calls)
+	}
+}
+
+func TestRpcClientStopsRetryingWhenContextIsDone(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.InjectFault(lotustest.METHOD_CHAIN_HEAD, lotustest.Fault{Delay: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := newTestLotusClient(t, server, "").LotusGetCurrentEpochWithContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if calls := server.Calls(lotustest.METHOD_CHAIN_HEAD); calls != 1 {
+		t.Fatalf("ChainHead calls: got %d, want 1", calls)
+	}
+}
+
+func TestRpcClientAuthErrors(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	server.AddToken("reader", lotustest.PERM_READ)
+	server.AddToken("writer", lotustest.PERM_READ, lotustest.PERM_WRITE)
+
+	_, err := newTestLotusClient(t, server, "unknown").LotusGetCurrentEpoch()
+	if !errors.Is(err, web.ErrUnauthorized) {
+		t.Fatalf("unknown token: got %v, want web.ErrUnauthorized", err)
+	}
+
+	_, err = newTestLotusClient(t, server, "reader").WalletNew(lotustest.KEY_TYPE_SECP256K1)
+	var rpcError *RPCError
+	if !errors.Is(err, web.ErrPermissionDenied) || !errors.As(err, &rpcError) {
+		t.Fatalf("read token: got %v, want an *RPCError matching web.ErrPermissionDenied", err)
+	}
+
+	_, err = newTestLotusClient(t, server, "writer").WalletNew(lotustest.KEY_TYPE_SECP256K1)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lotus answers the calls it cannot dispatch with http 500 and the JSON-RPC error in the body
+func TestRpcClientUnknownMethod(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	method := "Filecoin.Unknown"
+
+	err := newTestLotusClient(t, server, "").rpcClient().Call(context.Background(), method, nil)
+	var rpcError *RPCError
+	if !errors.As(err, &rpcError) || rpcError.Code != lotustest.RPC_CODE_METHOD_NOT_FOUND || rpcError.Method != method {
+		t.Fatalf("got %v, want an *RPCError for method not found", err)
+	}
+	if calls := server.Calls(method); calls != 1 {
+		t.Fatalf("%s calls: got %d, want 1", method, calls)
+	}
+}
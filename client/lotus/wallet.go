@@ -1,18 +1,47 @@
 package lotus
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 
 	"github.com/filswan/go-swan-lib/client"
+	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	LOTUS_WALLET_LIST             = "Filecoin.WalletList"
+	LOTUS_WALLET_BALANCE          = "Filecoin.WalletBalance"
+	LOTUS_WALLET_DEFAULT_ADDRESS  = "Filecoin.WalletDefaultAddress"
+	LOTUS_WALLET_NEW              = "Filecoin.WalletNew"
+	LOTUS_WALLET_HAS              = "Filecoin.WalletHas"
+	LOTUS_WALLET_VALIDATE_ADDRESS = "Filecoin.WalletValidateAddress"
+
+	// decimal places of FIL in attoFIL
+	FIL_DECIMALS = 18
+
+	// how lotus answers an undefined address, e.g. the default wallet of a node without one
+	LOTUS_ADDRESS_UNDEFINED = "<empty>"
 )
 
+// ErrNotVerifiedClient is matched by errors of lotus about a wallet without datacap
+var ErrNotVerifiedClient = errors.New("not a verified client")
+
+func isNotVerifiedClient(message string) bool {
+	return strings.Contains(strings.ToLower(message), "is not a verified client")
+}
+
+// IsWalletVerified runs lotus-shed, LotusClient.LotusStateVerifiedClientStatus asks the datacap to the node instead
 func IsWalletVerified(wallet string) (bool, error) {
 	wallet = strings.Trim(wallet, " ")
 	if wallet == "" {
 		err := fmt.Errorf("invalid wallet")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return false, err
 	}
 
@@ -20,18 +49,147 @@ func IsWalletVerified(wallet string) (bool, error) {
 
 	result, err := client.ExecOsCmd(cmd, true)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 
-		if strings.Contains(err.Error(), "is not a verified client") {
+		if isNotVerifiedClient(err.Error()) {
 			return false, nil
 		}
 
 		return false, err
 	}
 
-	if strings.Contains(result, "is not a verified client") {
+	if isNotVerifiedClient(result) {
 		return false, nil
 	}
 
 	return true, nil
 }
+
+// AttoFil2Fil converts an amount of attoFIL to FIL without losing precision
+func AttoFil2Fil(attoFil *big.Int) decimal.Decimal {
+	if attoFil == nil {
+		return decimal.Zero
+	}
+
+	return decimal.NewFromBigInt(attoFil, -FIL_DECIMALS)
+}
+
+// WalletList returns the addresses of the wallets of the node
+func (lotusClient *LotusClient) WalletList() ([]string, error) {
+	return lotusClient.WalletListWithContext(context.Background())
+}
+
+func (lotusClient *LotusClient) WalletListWithContext(ctx context.Context) ([]string, error) {
+	wallets := []string{}
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_WALLET_LIST, &wallets)
+	if err != nil && !errors.Is(err, ErrNilResult) {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+// WalletBalance returns the balance of wallet in attoFIL, AttoFil2Fil converts it to FIL
+func (lotusClient *LotusClient) WalletBalance(wallet string) (*big.Int, error) {
+	return lotusClient.WalletBalanceWithContext(context.Background(), wallet)
+}
+
+func (lotusClient *LotusClient) WalletBalanceWithContext(ctx context.Context, wallet string) (*big.Int, error) {
+	wallet = strings.Trim(wallet, " ")
+	if wallet == "" {
+		err := fmt.Errorf("invalid wallet")
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	var result string
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_WALLET_BALANCE, &result, wallet)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	balance, ok := new(big.Int).SetString(result, 10)
+	if !ok {
+		err := fmt.Errorf("invalid balance:%s of wallet:%s", result, wallet)
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+// WalletDefaultAddress returns the default wallet of the node, empty when it has none
+func (lotusClient *LotusClient) WalletDefaultAddress() (string, error) {
+	return lotusClient.WalletDefaultAddressWithContext(context.Background())
+}
+
+func (lotusClient *LotusClient) WalletDefaultAddressWithContext(ctx context.Context) (string, error) {
+	var wallet string
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_WALLET_DEFAULT_ADDRESS, &wallet)
+	if err != nil && !errors.Is(err, ErrNilResult) {
+		logs.Log().Error(err)
+		return "", err
+	}
+
+	if wallet == LOTUS_ADDRESS_UNDEFINED {
+		return "", nil
+	}
+
+	return wallet, nil
+}
+
+// WalletNew creates a wallet of walletType, constants.WALLET_TYPE_256 or constants.WALLET_TYPE_BLS, and returns its address
+func (lotusClient *LotusClient) WalletNew(walletType string) (string, error) {
+	return lotusClient.WalletNewWithContext(context.Background(), walletType)
+}
+
+func (lotusClient *LotusClient) WalletNewWithContext(ctx context.Context, walletType string) (string, error) {
+	if walletType != constants.WALLET_TYPE_256 && walletType != constants.WALLET_TYPE_BLS {
+		err := fmt.Errorf("invalid wallet type:%s, expecting %s or %s", walletType, constants.WALLET_TYPE_256, constants.WALLET_TYPE_BLS)
+		logs.Log().Error(err)
+		return "", err
+	}
+
+	var wallet string
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_WALLET_NEW, &wallet, walletType)
+	if err != nil {
+		logs.Log().Error(err)
+		return "", err
+	}
+
+	return wallet, nil
+}
+
+// WalletHas reports whether the node has the key of wallet
+func (lotusClient *LotusClient) WalletHas(wallet string) (bool, error) {
+	return lotusClient.WalletHasWithContext(context.Background(), wallet)
+}
+
+func (lotusClient *LotusClient) WalletHasWithContext(ctx context.Context, wallet string) (bool, error) {
+	var has bool
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_WALLET_HAS, &has, strings.Trim(wallet, " "))
+	if err != nil {
+		logs.Log().Error(err)
+		return false, err
+	}
+
+	return has, nil
+}
+
+// WalletValidateAddress returns wallet in the form of the network of the node, an invalid address fails with an error
+func (lotusClient *LotusClient) WalletValidateAddress(wallet string) (string, error) {
+	return lotusClient.WalletValidateAddressWithContext(context.Background(), wallet)
+}
+
+func (lotusClient *LotusClient) WalletValidateAddressWithContext(ctx context.Context, wallet string) (string, error) {
+	var address string
+	err := lotusClient.rpcClient().Call(ctx, LOTUS_WALLET_VALIDATE_ADDRESS, &address, strings.Trim(wallet, " "))
+	if err != nil {
+		logs.Log().Error(err)
+		return "", err
+	}
+
+	return address, nil
+}
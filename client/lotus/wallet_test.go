@@ -0,0 +1,82 @@
+package lotus
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/client/lotus/lotustest"
+	"github.com/filswan/go-swan-lib/constants"
+)
+
+func TestAttoFil2Fil(t *testing.T) {
+	amounts := map[string]string{
+		"0":                         "0",
+		"1":                         "0.000000000000000001",
+		"999999999999999999":        "0.999999999999999999",
+		"1000000000000000000":       "1",
+		"123456789012345678901":     "123.456789012345678901",
+		"-1500000000000000001":      "-1.500000000000000001",
+		"2000000000000000000000000": "2000000",
+	}
+
+	for attoFil, fil := range amounts {
+		amount, ok := new(big.Int).SetString(attoFil, 10)
+		if !ok {
+			t.Fatalf("invalid amount:%s", attoFil)
+		}
+		if got := AttoFil2Fil(amount).String(); got != fil {
+			t.Fatalf("%s attoFIL: got %s FIL, want %s", attoFil, got, fil)
+		}
+	}
+
+	if got := AttoFil2Fil(nil).String(); got != "0" {
+		t.Fatalf("nil: got %s, want 0", got)
+	}
+}
+
+func TestWalletNew(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	lotusClient := newTestLotusClient(t, server, "")
+
+	for walletType, prefix := range map[string]string{constants.WALLET_TYPE_256: "f1", constants.WALLET_TYPE_BLS: "f3"} {
+		wallet, err := lotusClient.WalletNew(walletType)
+		if err != nil || len(wallet) < 2 || wallet[:2] != prefix {
+			t.Fatalf("%s: got %s, %v, want a %s address", walletType, wallet, err, prefix)
+		}
+	}
+
+	for _, walletType := range []string{"", "secp256r1", "BLS", "delegated"} {
+		wallet, err := lotusClient.WalletNew(walletType)
+		if err == nil || wallet != "" {
+			t.Fatalf("%q: got %s, %v, want an error", walletType, wallet, err)
+		}
+	}
+
+	// invalid types are rejected before calling lotus
+	if calls := server.Calls(lotustest.METHOD_WALLET_NEW); calls != 2 {
+		t.Fatalf("WalletNew calls: got %d, want 2", calls)
+	}
+	if wallets := server.Wallets(); len(wallets) != 2 {
+		t.Fatalf("wallets: %v, want 2", wallets)
+	}
+}
+
+func TestWalletDefaultAddress(t *testing.T) {
+	server := lotustest.NewServer()
+	defer server.Close()
+	lotusClient := newTestLotusClient(t, server, "")
+
+	// lotus answers <empty> for a node without default wallet
+	wallet, err := lotusClient.WalletDefaultAddress()
+	if err != nil || wallet != "" {
+		t.Fatalf("got %q, %v, want no wallet", wallet, err)
+	}
+
+	server.AddWallet("f1defaultwallet", big.NewInt(1))
+	server.SetDefaultWallet("f1defaultwallet")
+	wallet, err = lotusClient.WalletDefaultAddress()
+	if err != nil || wallet != "f1defaultwallet" {
+		t.Fatalf("got %q, %v, want f1defaultwallet", wallet, err)
+	}
+}
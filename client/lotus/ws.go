@@ -0,0 +1,731 @@
+package lotus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-swan-lib/logs"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	LOTUS_CHAIN_NOTIFY             = "Filecoin.ChainNotify"
+	LOTUS_CLIENT_GET_DEAL_UPDATES  = "Filecoin.ClientGetDealUpdates"
+	LOTUS_RPC_CHANNEL_VALUE        = "xrpc.ch.val"
+	LOTUS_RPC_CHANNEL_CLOSE        = "xrpc.ch.close"
+	LOTUS_RPC_CANCEL               = "xrpc.cancel"
+	WS_RECONNECT_DELAY_MIN_DEFAULT = time.Second
+	WS_RECONNECT_DELAY_MAX_DEFAULT = 30 * time.Second
+	WS_PING_INTERVAL               = 30 * time.Second
+	WS_READ_TIMEOUT                = 3 * WS_PING_INTERVAL
+	WS_WRITE_TIMEOUT               = 10 * time.Second
+	WS_SUBSCRIPTION_BUFFER         = 64
+)
+
+// ErrWsClosed is returned for calls in flight when the connection is lost or the client is closed
+var ErrWsClosed = errors.New("websocket connection closed")
+
+// RpcCaller sends JSON-RPC requests, it is implemented by RpcClient over HTTP and WsClient over WebSocket
+type RpcCaller interface {
+	Call(ctx context.Context, method string, result interface{}, params ...interface{}) error
+}
+
+type HeadChange struct {
+	Type string
+	Val  TipSet
+}
+
+type ClientDealUpdate struct {
+	ProposalCid   Cid
+	State         int
+	Message       string
+	Provider      string
+	PieceCID      Cid
+	Size          uint64
+	PricePerEpoch string
+	Duration      uint64
+	DealID        int64
+	Verified      bool
+}
+
+// WsClient speaks lotus JSON-RPC over a WebSocket connection to /rpc/v0 or /rpc/v1.
+// The connection is reestablished automatically and subscriptions are renewed on the new connection,
+// calls in flight when the connection is lost fail with ErrWsClosed.
+type WsClient struct {
+	ApiUrl            string
+	AccessToken       string
+	ReconnectDelayMin time.Duration
+	ReconnectDelayMax time.Duration
+
+	writeMutex    sync.Mutex
+	mutex         sync.Mutex
+	conn          *websocket.Conn
+	pending       map[int]*wsPendingCall
+	subscriptions map[*Subscription]bool
+	channels      map[int]*Subscription
+	closed        bool
+	done          chan struct{}
+}
+
+type wsPendingCall struct {
+	method       string
+	response     chan []byte
+	subscription *Subscription
+}
+
+type wsMessage struct {
+	Id     *int              `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+}
+
+// Subscription delivers the values of a channel returned by a lotus method.
+// Values are queued until the subscriber reads them, a slow subscriber never holds up the connection.
+type Subscription struct {
+	method     string
+	params     []interface{}
+	client     *WsClient
+	values     chan json.RawMessage
+	done       chan struct{}
+	closeOnce  sync.Once
+	channelId  int
+	err        error
+	queueMutex sync.Mutex
+	queue      []json.RawMessage
+	queued     chan struct{}
+}
+
+// NewWsClient connects to the lotus node, apiUrl may use the http, https, ws or wss scheme
+func NewWsClient(ctx context.Context, apiUrl, accessToken string) (*WsClient, error) {
+	wsClient := &WsClient{
+		ApiUrl:            apiUrl,
+		AccessToken:       accessToken,
+		ReconnectDelayMin: WS_RECONNECT_DELAY_MIN_DEFAULT,
+		ReconnectDelayMax: WS_RECONNECT_DELAY_MAX_DEFAULT,
+		pending:           map[int]*wsPendingCall{},
+		subscriptions:     map[*Subscription]bool{},
+		channels:          map[int]*Subscription{},
+		done:              make(chan struct{}),
+	}
+
+	conn, err := wsClient.dial(ctx)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	wsClient.conn = conn
+	go wsClient.readLoop(conn)
+	go wsClient.pingLoop()
+
+	return wsClient, nil
+}
+
+func wsUrl(apiUrl string) string {
+	switch {
+	case strings.HasPrefix(apiUrl, "http://"):
+		return "ws://" + strings.TrimPrefix(apiUrl, "http://")
+	case strings.HasPrefix(apiUrl, "https://"):
+		return "wss://" + strings.TrimPrefix(apiUrl, "https://")
+	default:
+		return apiUrl
+	}
+}
+
+func (wsClient *WsClient) dial(ctx context.Context) (*websocket.Conn, error) {
+	if len(wsClient.ApiUrl) == 0 {
+		err := fmt.Errorf("api url is required")
+		return nil, err
+	}
+
+	header := http.Header{}
+	if len(strings.Trim(wsClient.AccessToken, " ")) > 0 {
+		header.Set("Authorization", "Bearer "+wsClient.AccessToken)
+	}
+
+	conn, response, err := websocket.DefaultDialer.DialContext(ctx, wsUrl(wsClient.ApiUrl), header)
+	if err != nil {
+		if response != nil {
+			err = fmt.Errorf("failed to connect to %s, http status:%s, %w", wsClient.ApiUrl, response.Status, err)
+		}
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(WS_READ_TIMEOUT))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(WS_READ_TIMEOUT))
+	})
+
+	return conn, nil
+}
+
+func (wsClient *WsClient) write(conn *websocket.Conn, message interface{}) error {
+	wsClient.writeMutex.Lock()
+	defer wsClient.writeMutex.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(WS_WRITE_TIMEOUT))
+	return conn.WriteJSON(message)
+}
+
+func (wsClient *WsClient) currentConn() (*websocket.Conn, error) {
+	wsClient.mutex.Lock()
+	defer wsClient.mutex.Unlock()
+
+	if wsClient.closed || wsClient.conn == nil {
+		return nil, ErrWsClosed
+	}
+
+	return wsClient.conn, nil
+}
+
+// send registers pendingCall and writes the request, the response is delivered on pendingCall.response
+func (wsClient *WsClient) send(jsonRpcParams LotusJsonRpcParams, pendingCall *wsPendingCall) error {
+	wsClient.mutex.Lock()
+	if wsClient.closed || wsClient.conn == nil {
+		wsClient.mutex.Unlock()
+		return ErrWsClosed
+	}
+	conn := wsClient.conn
+	wsClient.pending[jsonRpcParams.Id] = pendingCall
+	wsClient.mutex.Unlock()
+
+	err := wsClient.write(conn, jsonRpcParams)
+	if err != nil {
+		wsClient.removePending(jsonRpcParams.Id)
+		return err
+	}
+
+	return nil
+}
+
+func (wsClient *WsClient) removePending(id int) {
+	wsClient.mutex.Lock()
+	delete(wsClient.pending, id)
+	wsClient.mutex.Unlock()
+}
+
+func (wsClient *WsClient) cancel(id int) {
+	conn, err := wsClient.currentConn()
+	if err != nil {
+		return
+	}
+
+	cancelParams := LotusJsonRpcParams{
+		JsonRpc: LOTUS_JSON_RPC_VERSION,
+		Method:  LOTUS_RPC_CANCEL,
+		Params:  []interface{}{id},
+		Id:      nextRpcId(),
+	}
+
+	err = wsClient.write(conn, cancelParams)
+	if err != nil {
+		logs.Log().Error(err)
+	}
+}
+
+// Call invokes the JSON-RPC method with params and decodes the result into result, see RpcClient.Call
+func (wsClient *WsClient) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	return wsClient.call(ctx, method, result, nil, params)
+}
+
+func (wsClient *WsClient) call(ctx context.Context, method string, result interface{}, subscription *Subscription, params []interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	jsonRpcParams := LotusJsonRpcParams{
+		JsonRpc: LOTUS_JSON_RPC_VERSION,
+		Method:  method,
+		Params:  params,
+		Id:      nextRpcId(),
+	}
+
+	pendingCall := &wsPendingCall{
+		method:       method,
+		response:     make(chan []byte, 1),
+		subscription: subscription,
+	}
+
+	err := wsClient.send(jsonRpcParams, pendingCall)
+	if err != nil {
+		err := fmt.Errorf("%s, %w", method, err)
+		logs.Log().Error(err)
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		wsClient.removePending(jsonRpcParams.Id)
+		wsClient.cancel(jsonRpcParams.Id)
+		return ctx.Err()
+	case response := <-pendingCall.response:
+		if response == nil {
+			err := fmt.Errorf("%s, %w", method, ErrWsClosed)
+			logs.Log().Error(err)
+			return err
+		}
+		return decodeRpcResponse(response, jsonRpcParams, result)
+	}
+}
+
+// Subscribe calls a lotus method returning a channel, the values are delivered until the subscription is closed
+func (wsClient *WsClient) Subscribe(ctx context.Context, method string, params ...interface{}) (*Subscription, error) {
+	subscription := &Subscription{
+		method: method,
+		params: params,
+		client: wsClient,
+		values: make(chan json.RawMessage, WS_SUBSCRIPTION_BUFFER),
+		done:   make(chan struct{}),
+		queued: make(chan struct{}, 1),
+	}
+
+	wsClient.mutex.Lock()
+	wsClient.subscriptions[subscription] = true
+	wsClient.mutex.Unlock()
+	go subscription.forward()
+
+	var channelId int
+	err := wsClient.call(ctx, method, &channelId, subscription, params)
+	if err != nil {
+		logs.Log().Error(err)
+		subscription.finish(err)
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (wsClient *WsClient) resubscribe(conn *websocket.Conn) {
+	wsClient.mutex.Lock()
+	subscriptions := []*Subscription{}
+	for subscription := range wsClient.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	wsClient.mutex.Unlock()
+
+	for _, subscription := range subscriptions {
+		jsonRpcParams := LotusJsonRpcParams{
+			JsonRpc: LOTUS_JSON_RPC_VERSION,
+			Method:  subscription.method,
+			Params:  subscription.params,
+			Id:      nextRpcId(),
+		}
+		if jsonRpcParams.Params == nil {
+			jsonRpcParams.Params = []interface{}{}
+		}
+
+		pendingCall := &wsPendingCall{
+			method:       subscription.method,
+			response:     make(chan []byte, 1),
+			subscription: subscription,
+		}
+
+		err := wsClient.send(jsonRpcParams, pendingCall)
+		if err != nil {
+			logs.Log().Error(subscription.method, " resubscribe failed, ", err)
+			return
+		}
+
+		go func(subscription *Subscription, jsonRpcParams LotusJsonRpcParams) {
+			response := <-pendingCall.response
+			if response == nil {
+				return
+			}
+
+			var channelId int
+			err := decodeRpcResponse(response, jsonRpcParams, &channelId)
+			if err != nil {
+				subscription.finish(err)
+			}
+		}(subscription, jsonRpcParams)
+	}
+}
+
+func (wsClient *WsClient) readLoop(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			wsClient.connectionLost(conn, err)
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(WS_READ_TIMEOUT))
+
+		message := &wsMessage{}
+		err = json.Unmarshal(data, message)
+		if err != nil {
+			logs.Log().Error("invalid message from ", wsClient.ApiUrl, ", ", err)
+			continue
+		}
+
+		if message.Method != "" {
+			wsClient.handleRequest(conn, message)
+			continue
+		}
+
+		if message.Id == nil {
+			continue
+		}
+
+		wsClient.mutex.Lock()
+		pendingCall := wsClient.pending[*message.Id]
+		delete(wsClient.pending, *message.Id)
+		if pendingCall != nil && pendingCall.subscription != nil {
+			// registered before reading further so that no value sent right after the response is lost
+			var channelId int
+			if json.Unmarshal(message.Result, &channelId) == nil && len(message.Result) > 0 && string(message.Result) != "null" {
+				pendingCall.subscription.channelId = channelId
+				wsClient.channels[channelId] = pendingCall.subscription
+			}
+		}
+		wsClient.mutex.Unlock()
+
+		if pendingCall != nil {
+			pendingCall.response <- data
+		}
+	}
+}
+
+func (wsClient *WsClient) handleRequest(conn *websocket.Conn, message *wsMessage) {
+	if message.Id != nil {
+		ack := map[string]interface{}{
+			"jsonrpc": LOTUS_JSON_RPC_VERSION,
+			"id":      *message.Id,
+			"result":  nil,
+		}
+		err := wsClient.write(conn, ack)
+		if err != nil {
+			logs.Log().Error(err)
+		}
+	}
+
+	if len(message.Params) == 0 {
+		return
+	}
+
+	var channelId int
+	err := json.Unmarshal(message.Params[0], &channelId)
+	if err != nil {
+		logs.Log().Error("invalid channel id from ", wsClient.ApiUrl, ", ", err)
+		return
+	}
+
+	wsClient.mutex.Lock()
+	subscription := wsClient.channels[channelId]
+	wsClient.mutex.Unlock()
+	if subscription == nil {
+		return
+	}
+
+	switch message.Method {
+	case LOTUS_RPC_CHANNEL_VALUE:
+		if len(message.Params) < 2 {
+			return
+		}
+		subscription.push(message.Params[1])
+	case LOTUS_RPC_CHANNEL_CLOSE:
+		subscription.finish(nil)
+	}
+}
+
+func (wsClient *WsClient) connectionLost(conn *websocket.Conn, err error) {
+	wsClient.mutex.Lock()
+	if wsClient.conn != conn {
+		wsClient.mutex.Unlock()
+		return
+	}
+	wsClient.conn = nil
+	pending := wsClient.pending
+	wsClient.pending = map[int]*wsPendingCall{}
+	wsClient.channels = map[int]*Subscription{}
+	closed := wsClient.closed
+	wsClient.mutex.Unlock()
+
+	conn.Close()
+	for _, pendingCall := range pending {
+		pendingCall.response <- nil
+	}
+
+	if closed {
+		return
+	}
+
+	logs.Log().Error("connection to ", wsClient.ApiUrl, " lost, ", err)
+	go wsClient.reconnect()
+}
+
+func (wsClient *WsClient) reconnect() {
+	delay := wsClient.ReconnectDelayMin
+	if delay <= 0 {
+		delay = WS_RECONNECT_DELAY_MIN_DEFAULT
+	}
+
+	for {
+		select {
+		case <-wsClient.done:
+			return
+		case <-time.After(delay):
+		}
+
+		conn, err := wsClient.dial(context.Background())
+		if err != nil {
+			logs.Log().Error("reconnecting to ", wsClient.ApiUrl, " failed, ", err)
+			delay = delay * 2
+			if wsClient.ReconnectDelayMax > 0 && delay > wsClient.ReconnectDelayMax {
+				delay = wsClient.ReconnectDelayMax
+			}
+			continue
+		}
+
+		wsClient.mutex.Lock()
+		if wsClient.closed {
+			wsClient.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		wsClient.conn = conn
+		wsClient.mutex.Unlock()
+
+		logs.Log().Info("reconnected to ", wsClient.ApiUrl)
+		go wsClient.readLoop(conn)
+		wsClient.resubscribe(conn)
+		return
+	}
+}
+
+func (wsClient *WsClient) pingLoop() {
+	ticker := time.NewTicker(WS_PING_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wsClient.done:
+			return
+		case <-ticker.C:
+			conn, err := wsClient.currentConn()
+			if err != nil {
+				continue
+			}
+
+			wsClient.writeMutex.Lock()
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WS_WRITE_TIMEOUT))
+			wsClient.writeMutex.Unlock()
+			if err != nil {
+				logs.Log().Error(err)
+			}
+		}
+	}
+}
+
+// Close ends all subscriptions and the connection
+func (wsClient *WsClient) Close() error {
+	wsClient.mutex.Lock()
+	if wsClient.closed {
+		wsClient.mutex.Unlock()
+		return nil
+	}
+	wsClient.closed = true
+	conn := wsClient.conn
+	subscriptions := wsClient.subscriptions
+	wsClient.subscriptions = map[*Subscription]bool{}
+	wsClient.mutex.Unlock()
+
+	close(wsClient.done)
+	for subscription := range subscriptions {
+		subscription.finish(ErrWsClosed)
+	}
+
+	if conn == nil {
+		return nil
+	}
+
+	wsClient.writeMutex.Lock()
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(WS_WRITE_TIMEOUT))
+	wsClient.writeMutex.Unlock()
+
+	return conn.Close()
+}
+
+// Values returns the raw JSON values sent by the node, Done tells when no more values will come
+func (subscription *Subscription) Values() <-chan json.RawMessage {
+	return subscription.values
+}
+
+// Done is closed when the subscription ends
+func (subscription *Subscription) Done() <-chan struct{} {
+	return subscription.done
+}
+
+// Err returns why the subscription ended, nil when it was closed by the node or by Close
+func (subscription *Subscription) Err() error {
+	select {
+	case <-subscription.done:
+		return subscription.err
+	default:
+		return nil
+	}
+}
+
+// Close stops the subscription and asks the node to close the channel
+func (subscription *Subscription) Close() {
+	wsClient := subscription.client
+	wsClient.mutex.Lock()
+	channelId := subscription.channelId
+	registered := wsClient.channels[channelId] == subscription
+	wsClient.mutex.Unlock()
+
+	subscription.finish(nil)
+	if !registered {
+		return
+	}
+
+	conn, err := wsClient.currentConn()
+	if err != nil {
+		return
+	}
+
+	closeParams := LotusJsonRpcParams{
+		JsonRpc: LOTUS_JSON_RPC_VERSION,
+		Method:  LOTUS_RPC_CHANNEL_CLOSE,
+		Params:  []interface{}{channelId},
+	}
+
+	err = wsClient.write(conn, closeParams)
+	if err != nil {
+		logs.Log().Error(err)
+	}
+}
+
+// push queues a value for the subscriber, it never blocks the read loop of the connection
+func (subscription *Subscription) push(value json.RawMessage) {
+	subscription.queueMutex.Lock()
+	subscription.queue = append(subscription.queue, value)
+	subscription.queueMutex.Unlock()
+
+	select {
+	case subscription.queued <- struct{}{}:
+	default:
+	}
+}
+
+// forward delivers the queued values in order until the subscription or the client ends
+func (subscription *Subscription) forward() {
+	for {
+		select {
+		case <-subscription.queued:
+		case <-subscription.done:
+			return
+		case <-subscription.client.done:
+			return
+		}
+
+		for {
+			subscription.queueMutex.Lock()
+			if len(subscription.queue) == 0 {
+				subscription.queueMutex.Unlock()
+				break
+			}
+			value := subscription.queue[0]
+			subscription.queue[0] = nil
+			subscription.queue = subscription.queue[1:]
+			subscription.queueMutex.Unlock()
+
+			select {
+			case subscription.values <- value:
+			case <-subscription.done:
+				return
+			case <-subscription.client.done:
+				return
+			}
+		}
+	}
+}
+
+func (subscription *Subscription) finish(err error) {
+	subscription.closeOnce.Do(func() {
+		wsClient := subscription.client
+		wsClient.mutex.Lock()
+		delete(wsClient.subscriptions, subscription)
+		if wsClient.channels[subscription.channelId] == subscription {
+			delete(wsClient.channels, subscription.channelId)
+		}
+		wsClient.mutex.Unlock()
+
+		subscription.queueMutex.Lock()
+		subscription.queue = nil
+		subscription.queueMutex.Unlock()
+
+		subscription.err = err
+		close(subscription.done)
+	})
+}
+
+func subscribeTyped[T any](ctx context.Context, wsClient *WsClient, method string, params ...interface{}) (<-chan T, error) {
+	subscription, err := wsClient.Subscribe(ctx, method, params...)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer subscription.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-subscription.Done():
+				return
+			case value := <-subscription.Values():
+				var typed T
+				err := json.Unmarshal(value, &typed)
+				if err != nil {
+					logs.Log().Error(method, " invalid value, ", err)
+					continue
+				}
+
+				select {
+				case out <- typed:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// ChainNotify delivers the head changes of the chain until ctx is done, the first value is the current head
+func (wsClient *WsClient) ChainNotify(ctx context.Context) (<-chan []HeadChange, error) {
+	return subscribeTyped[[]HeadChange](ctx, wsClient, LOTUS_CHAIN_NOTIFY)
+}
+
+// ClientGetDealUpdates delivers the state changes of the deals of the client until ctx is done
+func (wsClient *WsClient) ClientGetDealUpdates(ctx context.Context) (<-chan ClientDealUpdate, error) {
+	return subscribeTyped[ClientDealUpdate](ctx, wsClient, LOTUS_CLIENT_GET_DEAL_UPDATES)
+}
+
+// CallBatch sends all the calls without waiting for the previous responses and sets the Err of each call
+func (wsClient *WsClient) CallBatch(ctx context.Context, calls []*BatchCall) error {
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		wg.Add(1)
+		go func(call *BatchCall) {
+			defer wg.Done()
+			call.Err = wsClient.call(ctx, call.Method, call.Result, nil, call.Params)
+		}(call)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
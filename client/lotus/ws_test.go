@@ -0,0 +1,96 @@
+package lotus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsTestValues is more than a subscription buffers, so a read loop blocking on the subscriber would stall
+const wsTestValues = 4 * WS_SUBSCRIPTION_BUFFER
+
+// newWsTestServer answers ChainNotify with channel 1 followed by wsTestValues values and Version with "test"
+func newWsTestServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			request := struct {
+				Id     int    `json:"id"`
+				Method string `json:"method"`
+			}{}
+			err := conn.ReadJSON(&request)
+			if err != nil {
+				return
+			}
+
+			switch request.Method {
+			case LOTUS_CHAIN_NOTIFY:
+				conn.WriteJSON(map[string]interface{}{"jsonrpc": LOTUS_JSON_RPC_VERSION, "id": request.Id, "result": 1})
+				for i := 0; i < wsTestValues; i++ {
+					conn.WriteJSON(map[string]interface{}{"jsonrpc": LOTUS_JSON_RPC_VERSION, "method": LOTUS_RPC_CHANNEL_VALUE, "params": []interface{}{1, i}})
+				}
+			case LOTUS_VERSION:
+				conn.WriteJSON(map[string]interface{}{"jsonrpc": LOTUS_JSON_RPC_VERSION, "id": request.Id, "result": map[string]string{"Version": "test"}})
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestWsSlowSubscriberDoesNotBlockCalls(t *testing.T) {
+	server := newWsTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	wsClient, err := NewWsClient(ctx, server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wsClient.Close()
+
+	subscription, err := wsClient.Subscribe(ctx, LOTUS_CHAIN_NOTIFY)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing reads the subscription while the call waits for its response
+	callCtx, callCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer callCancel()
+	version := struct{ Version string }{}
+	err = wsClient.Call(callCtx, LOTUS_VERSION, &version)
+	if err != nil {
+		t.Fatalf("call blocked by the subscription: %v", err)
+	}
+	if version.Version != "test" {
+		t.Fatalf("version: got %q", version.Version)
+	}
+
+	for i := 0; i < wsTestValues; i++ {
+		select {
+		case value := <-subscription.Values():
+			var got int
+			err := json.Unmarshal(value, &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != i {
+				t.Fatalf("value %d: got %d", i, got)
+			}
+		case <-ctx.Done():
+			t.Fatalf("value %d not delivered", i)
+		}
+	}
+}
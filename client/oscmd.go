@@ -45,7 +45,7 @@ func ExecOsCmdBase(cmdStr string, out2Screen bool, checkStdErr bool) (string, er
 		errMsg := strings.Join(errs, ",")
 
 		outErr := errors.New(errMsg)
-		logs.GetLogger().Error(outErr)
+		logs.Log().Error(outErr)
 		return "", outErr
 	}
 
@@ -1,10 +1,15 @@
 package swan
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/filswan/go-swan-lib/client/web"
+	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/filswan/go-swan-lib/utils"
 )
@@ -14,12 +19,25 @@ type LoginByEmailParams struct {
 	Password string `json:"password"`
 }
 
+// jwtTokenRetryPolicy is used by GetJwtTokenUp3Times when the client has no retry policy
+var jwtTokenRetryPolicy = &web.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     web.RETRY_MULTIPLIER_DEFAULT,
+	Jitter:         web.RETRY_JITTER_DEFAULT,
+}
+
 type LoginByApikeyParams struct {
 	Apikey      string `json:"apikey"`
 	AccessToken string `json:"access_token"`
 }
 
 func (swanClient *SwanClient) GetJwtTokenByApiKey() error {
+	return swanClient.GetJwtTokenByApiKeyWithContext(context.Background())
+}
+
+func (swanClient *SwanClient) GetJwtTokenByApiKeyWithContext(ctx context.Context) error {
 	data := LoginByApikeyParams{
 		Apikey:      swanClient.ApiKey,
 		AccessToken: swanClient.AccessToken,
@@ -27,45 +45,44 @@ func (swanClient *SwanClient) GetJwtTokenByApiKey() error {
 
 	if len(swanClient.ApiUrl) == 0 {
 		err := fmt.Errorf("api url is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
 	if len(data.Apikey) == 0 {
 		err := fmt.Errorf("apikey is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
 	if len(data.AccessToken) == 0 {
 		err := fmt.Errorf("acess token is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "user/login_by_apikey")
 
-	response, err := web.HttpPostNoToken(apiUrl, data)
+	response, err := swanClient.httpClient().Request(ctx, http.MethodPost, apiUrl, "", data)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
-	if strings.Contains(string(response), "fail") {
+	status := utils.GetFieldStrFromJson(response, "status")
+	if strings.EqualFold(status, constants.SWAN_API_STATUS_FAIL) {
 		message := utils.GetFieldStrFromJson(response, "message")
-		status := utils.GetFieldStrFromJson(response, "status")
-		err := fmt.Errorf("status:%s, message:%s", status, message)
-		logs.GetLogger().Error(err)
-
-		if message == "apikey not found" {
-			logs.GetLogger().Error("please check your api key")
-		}
-
-		if message == "access token wrong" {
-			logs.GetLogger().Error("Please check your access token")
+		err := newSwanAPIError(apiUrl, status, message)
+		logs.Log().Error(err)
+
+		switch message {
+		case SWAN_API_MESSAGE_APIKEY_NOT_FOUND:
+			logs.Log().Error("please check your api key")
+		case SWAN_API_MESSAGE_ACCESS_TOKEN_WRONG:
+			logs.Log().Error("Please check your access token")
 		}
 
-		logs.GetLogger().Info("for more information about how to config, please check https://docs.filswan.com/run-swan-provider/config-swan-provider")
+		logs.Log().Info("for more information about how to config, please check https://docs.filswan.com/run-swan-provider/config-swan-provider")
 
 		return err
 	}
@@ -73,7 +90,7 @@ func (swanClient *SwanClient) GetJwtTokenByApiKey() error {
 	jwtData := utils.GetFieldMapFromJson(response, "data")
 	if jwtData == nil {
 		err := fmt.Errorf("error: fail to connect to swan api")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -83,36 +100,51 @@ func (swanClient *SwanClient) GetJwtTokenByApiKey() error {
 }
 
 func (swanClient *SwanClient) GetJwtTokenUp3Times() error {
+	return swanClient.GetJwtTokenUp3TimesWithContext(context.Background())
+}
+
+func (swanClient *SwanClient) GetJwtTokenUp3TimesWithContext(ctx context.Context) error {
 	if len(swanClient.ApiUrl) == 0 {
 		err := fmt.Errorf("api url is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
 	if len(swanClient.ApiKey) == 0 {
 		err := fmt.Errorf("api key is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
 	if len(swanClient.AccessToken) == 0 {
 		err := fmt.Errorf("access token is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
-	var err error
-	for i := 0; i < 3; i++ {
-		err = swanClient.GetJwtTokenByApiKey()
-		if err == nil {
-			break
-		}
-		logs.GetLogger().Error(err)
+	retryPolicy := swanClient.RetryPolicy
+	if retryPolicy == nil {
+		retryPolicy = jwtTokenRetryPolicy
 	}
 
+	attempts := 0
+	err := retryPolicy.Retry(ctx, func(attempt int) (bool, error) {
+		attempts = attempt
+		err := swanClient.GetJwtTokenByApiKeyWithContext(ctx)
+		if err != nil {
+			logs.Log().Error(err)
+			// a wrong api key or access token does not get better by trying again
+			return ctx.Err() == nil && !errors.Is(err, web.ErrUnauthorized), err
+		}
+		return false, nil
+	})
+
 	if err != nil {
-		err = fmt.Errorf("failed to connect to swan platform after trying 3 times")
-		logs.GetLogger().Error(err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err = fmt.Errorf("failed to connect to swan platform after trying %d times, %w", attempts, err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -1,11 +1,12 @@
 package swan
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 
-	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/filswan/go-swan-lib/model"
@@ -25,37 +26,43 @@ type GetCarFileByUuidUrlResultData struct {
 }
 
 func (swanClient *SwanClient) GetCarFileByUuidUrl(taskUuid, carFileUrl string) (*GetCarFileByUuidUrlResultData, error) {
+	return swanClient.GetCarFileByUuidUrlWithContext(context.Background(), taskUuid, carFileUrl)
+}
+
+func (swanClient *SwanClient) GetCarFileByUuidUrlWithContext(ctx context.Context, taskUuid, carFileUrl string) (*GetCarFileByUuidUrlResultData, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_TASK_UUID: taskUuid})
+
 	if len(taskUuid) == 0 {
 		err := fmt.Errorf("please provide task uuid")
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	if len(carFileUrl) == 0 {
 		err := fmt.Errorf("please provide car file url")
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	apiUrl := fmt.Sprintf("%s/car_files/car_file?task_uuid=%s&car_file_url=%s", swanClient.ApiUrl, taskUuid, carFileUrl)
 
-	response, err := web.HttpGet(apiUrl, swanClient.SwanToken, "")
+	response, err := swanClient.httpClient().Request(ctx, http.MethodGet, apiUrl, swanClient.SwanToken, "")
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	getCarFileByUuidUrlResult := &GetCarFileByUuidUrlResult{}
 	err = json.Unmarshal(response, getCarFileByUuidUrlResult)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(getCarFileByUuidUrlResult.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("error:%s", getCarFileByUuidUrlResult.Status)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, getCarFileByUuidUrlResult.Status, "")
+		logger.Error(err)
 		return nil, err
 	}
 
@@ -76,31 +83,35 @@ type GetAutoBidCarFilesByStatusResultData struct {
 }
 
 func (swanClient *SwanClient) GetAutoBidCarFilesByStatus(carFileStatus string) (*GetAutoBidCarFilesByStatusResultData, error) {
+	return swanClient.GetAutoBidCarFilesByStatusWithContext(context.Background(), carFileStatus)
+}
+
+func (swanClient *SwanClient) GetAutoBidCarFilesByStatusWithContext(ctx context.Context, carFileStatus string) (*GetAutoBidCarFilesByStatusResultData, error) {
 	carFileStatus = strings.Trim(carFileStatus, " ")
 	if len(carFileStatus) == 0 {
 		err := fmt.Errorf("please provide car file status")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	apiUrl := fmt.Sprintf("%s/car_files/auto_bid/get_by_status?car_file_status=%s", swanClient.ApiUrl, carFileStatus)
 
-	response, err := web.HttpGet(apiUrl, swanClient.SwanToken, "")
+	response, err := swanClient.httpClient().Request(ctx, http.MethodGet, apiUrl, swanClient.SwanToken, "")
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	getAutoBidCarFilesByStatusResult := &GetAutoBidCarFilesByStatusResult{}
 	err = json.Unmarshal(response, getAutoBidCarFilesByStatusResult)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(getAutoBidCarFilesByStatusResult.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("error:%s", getAutoBidCarFilesByStatusResult.Status)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, getAutoBidCarFilesByStatusResult.Status, "")
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -1,8 +1,10 @@
 package swan
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
 )
@@ -12,6 +14,8 @@ type SwanClient struct {
 	SwanToken   string
 	ApiKey      string
 	AccessToken string
+	HttpClient  *web.Client      // nil means web.DefaultClient()
+	RetryPolicy *web.RetryPolicy // nil means the retry policy of HttpClient
 }
 type SwanServerResponse struct {
 	Status  string `json:"status"`
@@ -19,9 +23,13 @@ type SwanServerResponse struct {
 }
 
 func GetClient(apiUrl, apiKey, accessToken, swanToken string) (*SwanClient, error) {
+	return GetClientWithContext(context.Background(), apiUrl, apiKey, accessToken, swanToken)
+}
+
+func GetClientWithContext(ctx context.Context, apiUrl, apiKey, accessToken, swanToken string) (*SwanClient, error) {
 	if len(apiUrl) == 0 {
 		err := fmt.Errorf("api url is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -33,9 +41,22 @@ func GetClient(apiUrl, apiKey, accessToken, swanToken string) (*SwanClient, erro
 	}
 
 	if swanToken == constants.EMPTY_STRING {
-		err := swanClient.GetJwtTokenUp3Times()
+		err := swanClient.GetJwtTokenUp3TimesWithContext(ctx)
 		return swanClient, err
 	}
 
 	return swanClient, nil
 }
+
+func (swanClient *SwanClient) httpClient() *web.Client {
+	httpClient := swanClient.HttpClient
+	if httpClient == nil {
+		httpClient = web.DefaultClient()
+	}
+
+	if swanClient.RetryPolicy != nil {
+		httpClient = httpClient.WithRetryPolicy(swanClient.RetryPolicy)
+	}
+
+	return httpClient
+}
@@ -0,0 +1,54 @@
+package swan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/filswan/go-swan-lib/client/web"
+)
+
+const (
+	SWAN_API_MESSAGE_APIKEY_NOT_FOUND   = "apikey not found"
+	SWAN_API_MESSAGE_ACCESS_TOKEN_WRONG = "access token wrong"
+)
+
+// SwanAPIError is returned when the swan platform answers with a status other than success
+type SwanAPIError struct {
+	Url     string
+	Status  string
+	Message string
+}
+
+func newSwanAPIError(apiUrl, status, message string) *SwanAPIError {
+	swanAPIError := &SwanAPIError{
+		Url:     apiUrl,
+		Status:  status,
+		Message: message,
+	}
+
+	return swanAPIError
+}
+
+func (swanAPIError *SwanAPIError) Error() string {
+	if swanAPIError.Message == "" {
+		return fmt.Sprintf("status:%s", swanAPIError.Status)
+	}
+	return fmt.Sprintf("status:%s, message:%s", swanAPIError.Status, swanAPIError.Message)
+}
+
+// Is matches web.ErrUnauthorized for a wrong api key or access token, web.ErrNotFound and web.ErrPermissionDenied
+func (swanAPIError *SwanAPIError) Is(target error) bool {
+	message := strings.ToLower(swanAPIError.Message)
+
+	switch target {
+	case web.ErrUnauthorized:
+		return message == SWAN_API_MESSAGE_APIKEY_NOT_FOUND || message == SWAN_API_MESSAGE_ACCESS_TOKEN_WRONG ||
+			strings.Contains(message, "unauthorized") || strings.Contains(message, "token expired")
+	case web.ErrNotFound:
+		return message != SWAN_API_MESSAGE_APIKEY_NOT_FOUND && strings.Contains(message, "not found")
+	case web.ErrPermissionDenied:
+		return strings.Contains(message, "permission denied") || strings.Contains(message, "forbidden")
+	}
+
+	return false
+}
@@ -1,11 +1,12 @@
 package swan
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 
-	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/filswan/go-swan-lib/model"
@@ -21,24 +22,30 @@ type MinerResponse struct {
 }
 
 func (swanClient *SwanClient) GetMiner(minerFid string) (*MinerResponse, error) {
+	return swanClient.GetMinerWithContext(context.Background(), minerFid)
+}
+
+func (swanClient *SwanClient) GetMinerWithContext(ctx context.Context, minerFid string) (*MinerResponse, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MINER_FID: minerFid})
+
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "miners", minerFid)
 
-	response, err := web.HttpGetNoToken(apiUrl, "")
+	response, err := swanClient.httpClient().Request(ctx, http.MethodGet, apiUrl, "", "")
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	minerResponse := &MinerResponse{}
 	err = json.Unmarshal(response, minerResponse)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(minerResponse.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("status:%s, message:%s", minerResponse.Status, minerResponse.Message)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, minerResponse.Status, minerResponse.Message)
+		logger.Error(err)
 		return nil, err
 
 	}
@@ -56,20 +63,26 @@ type UpdateMinerConfigParams struct {
 }
 
 func (swanClient *SwanClient) UpdateMinerBidConf(minerFid string, confMiner model.Miner) error {
-	err := swanClient.GetJwtTokenUp3Times()
+	return swanClient.UpdateMinerBidConfWithContext(context.Background(), minerFid, confMiner)
+}
+
+func (swanClient *SwanClient) UpdateMinerBidConfWithContext(ctx context.Context, minerFid string, confMiner model.Miner) error {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MINER_FID: minerFid})
+
+	err := swanClient.GetJwtTokenUp3TimesWithContext(ctx)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
-	minerResponse, err := swanClient.GetMiner(minerFid)
+	minerResponse, err := swanClient.GetMinerWithContext(ctx, minerFid)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	if minerResponse == nil || minerResponse.Status != constants.SWAN_API_STATUS_SUCCESS {
-		logs.GetLogger().Error("Error: Get miner information failed")
+		logger.Error("Error: Get miner information failed")
 		return err
 	}
 
@@ -79,11 +92,11 @@ func (swanClient *SwanClient) UpdateMinerBidConf(minerFid string, confMiner mode
 		miner.ExpectedSealingTime == confMiner.ExpectedSealingTime &&
 		miner.StartEpoch == confMiner.StartEpoch &&
 		miner.AutoBidDealPerDay == confMiner.AutoBidDealPerDay {
-		logs.GetLogger().Info("No changes in bid configuration")
+		logger.Info("No changes in bid configuration")
 		return err
 	}
 
-	logs.GetLogger().Info("Begin updating bid configuration")
+	logger.Info("Begin updating bid configuration")
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "miners/update_miner_config")
 
 	params := UpdateMinerConfigParams{
@@ -95,26 +108,26 @@ func (swanClient *SwanClient) UpdateMinerBidConf(minerFid string, confMiner mode
 		MarketVersion:       confMiner.MarketVersion,
 	}
 
-	response, err := web.HttpPost(apiUrl, swanClient.SwanToken, params)
+	response, err := swanClient.httpClient().Request(ctx, http.MethodPost, apiUrl, swanClient.SwanToken, params)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	swanServerResponse := &SwanServerResponse{}
 	err = json.Unmarshal(response, swanServerResponse)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	if !strings.EqualFold(minerResponse.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("%s,%s", minerResponse.Status, minerResponse.Message)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, minerResponse.Status, minerResponse.Message)
+		logger.Error(err)
 		return err
 	}
 
-	logs.GetLogger().Info("Bid configuration updated.")
+	logger.Info("Bid configuration updated.")
 	return nil
 }
 
@@ -123,9 +136,15 @@ type SetHeartbeatOnlineParams struct {
 }
 
 func (swanClient *SwanClient) SendHeartbeatRequest(minerFid string) error {
-	err := swanClient.GetJwtTokenUp3Times()
+	return swanClient.SendHeartbeatRequestWithContext(context.Background(), minerFid)
+}
+
+func (swanClient *SwanClient) SendHeartbeatRequestWithContext(ctx context.Context, minerFid string) error {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_MINER_FID: minerFid})
+
+	err := swanClient.GetJwtTokenUp3TimesWithContext(ctx)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
@@ -134,26 +153,26 @@ func (swanClient *SwanClient) SendHeartbeatRequest(minerFid string) error {
 		MinerFid: minerFid,
 	}
 
-	response, err := web.HttpPost(apiUrl, swanClient.SwanToken, params)
+	response, err := swanClient.httpClient().Request(ctx, http.MethodPost, apiUrl, swanClient.SwanToken, params)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	swanServerResponse := &SwanServerResponse{}
 	err = json.Unmarshal([]byte(response), swanServerResponse)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	if !strings.EqualFold(swanServerResponse.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("%s,%s", swanServerResponse.Status, swanServerResponse.Message)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, swanServerResponse.Status, swanServerResponse.Message)
+		logger.Error(err)
 		return err
 	}
 
 	msg := fmt.Sprintf("status:%s, message:%s", swanServerResponse.Status, swanServerResponse.Message)
-	logs.GetLogger().Info(msg)
+	logger.Info(msg)
 	return nil
 }
@@ -1,11 +1,12 @@
 package swan
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 
-	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/filswan/go-swan-lib/model"
@@ -32,35 +33,39 @@ type GetOfflineDealsByStatusResponse struct {
 }
 
 func (swanClient *SwanClient) GetOfflineDealsByStatus(params GetOfflineDealsByStatusParams) ([]*model.OfflineDeal, error) {
+	return swanClient.GetOfflineDealsByStatusWithContext(context.Background(), params)
+}
+
+func (swanClient *SwanClient) GetOfflineDealsByStatusWithContext(ctx context.Context, params GetOfflineDealsByStatusParams) ([]*model.OfflineDeal, error) {
 	if utils.IsStrEmpty(&params.DealStatus) {
 		err := fmt.Errorf("deal status is required")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
-	err := swanClient.GetJwtTokenUp3Times()
+	err := swanClient.GetJwtTokenUp3TimesWithContext(ctx)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "offline_deals/get_by_status")
-	response, err := web.HttpGet(apiUrl, swanClient.SwanToken, params)
+	response, err := swanClient.httpClient().Request(ctx, http.MethodGet, apiUrl, swanClient.SwanToken, params)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	getOfflineDealsByStatusResponse := GetOfflineDealsByStatusResponse{}
 	err = json.Unmarshal([]byte(response), &getOfflineDealsByStatusResponse)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(getOfflineDealsByStatusResponse.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("get offline deal with status:%s failed", params.DealStatus)
-		logs.GetLogger().Error(err)
+		err := fmt.Errorf("get offline deal with status:%s failed, %w", params.DealStatus, newSwanAPIError(apiUrl, getOfflineDealsByStatusResponse.Status, ""))
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -82,42 +87,48 @@ type UpdateOfflineDealParams struct {
 
 // for public and auto-bid task
 func (swanClient *SwanClient) UpdateOfflineDeal(params UpdateOfflineDealParams) error {
-	err := swanClient.GetJwtTokenUp3Times()
+	return swanClient.UpdateOfflineDealWithContext(context.Background(), params)
+}
+
+func (swanClient *SwanClient) UpdateOfflineDealWithContext(ctx context.Context, params UpdateOfflineDealParams) error {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_DEAL_ID: params.DealId})
+
+	err := swanClient.GetJwtTokenUp3TimesWithContext(ctx)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	if len(params.Status) == 0 {
 		err := fmt.Errorf("status is invalid")
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	if params.DealId <= 0 {
 		err := fmt.Errorf("deal id is invalid")
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "offline_deals/update_offline_deal")
 
-	response, err := web.HttpPut(apiUrl, swanClient.SwanToken, params)
+	response, err := swanClient.httpClient().Request(ctx, http.MethodPut, apiUrl, swanClient.SwanToken, params)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	swanServerResponse := &SwanServerResponse{}
 	err = json.Unmarshal([]byte(response), swanServerResponse)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	if !strings.EqualFold(swanServerResponse.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("deal(id=%d),failed to update offline deal status to %s,%s", params.DealId, params.Status, swanServerResponse.Message)
-		logs.GetLogger().Error(err)
+		err := fmt.Errorf("deal(id=%d),failed to update offline deal status to %s,%w", params.DealId, params.Status, newSwanAPIError(apiUrl, swanServerResponse.Status, swanServerResponse.Message))
+		logger.Error(err)
 		return err
 	}
 
@@ -126,23 +137,27 @@ func (swanClient *SwanClient) UpdateOfflineDeal(params UpdateOfflineDealParams)
 
 // for public and non auto-bid task
 func (swanClient *SwanClient) CreateOfflineDeals(fileDescs []*model.FileDesc) (*SwanServerResponse, error) {
+	return swanClient.CreateOfflineDealsWithContext(context.Background(), fileDescs)
+}
+
+func (swanClient *SwanClient) CreateOfflineDealsWithContext(ctx context.Context, fileDescs []*model.FileDesc) (*SwanServerResponse, error) {
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "offline_deals/create_offline_deals")
-	response, err := web.HttpPost(apiUrl, swanClient.SwanToken, fileDescs)
+	response, err := swanClient.httpClient().Request(ctx, http.MethodPost, apiUrl, swanClient.SwanToken, fileDescs)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	swanServerResponse := &SwanServerResponse{}
 	err = json.Unmarshal(response, swanServerResponse)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(swanServerResponse.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("error:%s,%s", swanServerResponse.Status, swanServerResponse.Message)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, swanServerResponse.Status, swanServerResponse.Message)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -150,23 +165,29 @@ func (swanClient *SwanClient) CreateOfflineDeals(fileDescs []*model.FileDesc) (*
 }
 
 func (swanClient *SwanClient) GetDealListByTaskUuid(taskUuId string, pageNum int) (*DealListByTaskUuIdResp, error) {
+	return swanClient.GetDealListByTaskUuidWithContext(context.Background(), taskUuId, pageNum)
+}
+
+func (swanClient *SwanClient) GetDealListByTaskUuidWithContext(ctx context.Context, taskUuId string, pageNum int) (*DealListByTaskUuIdResp, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_TASK_UUID: taskUuId})
+
 	apiUrl := fmt.Sprintf("%s/tasks/%s?limit=100&offset=%d", swanClient.ApiUrl, taskUuId, 100*pageNum)
-	response, err := web.HttpGet(apiUrl, swanClient.SwanToken, "")
+	response, err := swanClient.httpClient().Request(ctx, http.MethodGet, apiUrl, swanClient.SwanToken, "")
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	dealListByTaskUuIdResp := DealListByTaskUuIdResp{}
 	err = json.Unmarshal([]byte(response), &dealListByTaskUuIdResp)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(dealListByTaskUuIdResp.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("get dealList by taskUuid status:%s failed", dealListByTaskUuIdResp.Status)
-		logs.GetLogger().Error(err)
+		err := fmt.Errorf("get dealList by taskUuid failed, %w", newSwanAPIError(apiUrl, dealListByTaskUuIdResp.Status, ""))
+		logger.Error(err)
 		return nil, err
 	}
 	return &dealListByTaskUuIdResp, nil
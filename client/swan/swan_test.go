@@ -0,0 +1,142 @@
+package swan
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/filswan/go-swan-lib/client/swan/swantest"
+	"github.com/filswan/go-swan-lib/client/web"
+	"github.com/filswan/go-swan-lib/model"
+)
+
+const (
+	testApiKey      = "apikey"
+	testAccessToken = "access-token"
+)
+
+func newTestRetryPolicy() *web.RetryPolicy {
+	retryPolicy := web.DefaultRetryPolicy()
+	retryPolicy.InitialBackoff = time.Millisecond
+	retryPolicy.MaxBackoff = time.Millisecond
+	return retryPolicy
+}
+
+func newTestSwanClient(t *testing.T, server *swantest.Server) *SwanClient {
+	server.AddApiKey(testApiKey, testAccessToken)
+
+	swanClient, err := GetClient(server.URL, testApiKey, testAccessToken, server.IssueToken(testApiKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	swanClient.RetryPolicy = newTestRetryPolicy()
+
+	return swanClient
+}
+
+func TestGetClientLogsIn(t *testing.T) {
+	server := swantest.NewServer()
+	defer server.Close()
+	server.AddApiKey(testApiKey, testAccessToken)
+
+	swanClient, err := GetClient(server.URL, testApiKey, testAccessToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swanClient.SwanToken == "" {
+		t.Fatal("no jwt token after login")
+	}
+}
+
+func TestGetJwtTokenRetriesTransportErrors(t *testing.T) {
+	server := swantest.NewServer()
+	defer server.Close()
+	server.AddApiKey(testApiKey, testAccessToken)
+	server.InjectFault(swantest.PATH_LOGIN_BY_APIKEY, swantest.Fault{Drop: true, Times: 2})
+
+	swanClient := &SwanClient{ApiUrl: server.URL, ApiKey: testApiKey, AccessToken: testAccessToken, RetryPolicy: newTestRetryPolicy()}
+	err := swanClient.GetJwtTokenUp3Times()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := server.Calls(swantest.PATH_LOGIN_BY_APIKEY); calls != 3 {
+		t.Fatalf("login calls: got %d, want 3", calls)
+	}
+}
+
+func TestGetJwtTokenDoesNotRetryWrongCredentials(t *testing.T) {
+	server := swantest.NewServer()
+	defer server.Close()
+	server.AddApiKey(testApiKey, testAccessToken)
+
+	swanClient := &SwanClient{ApiUrl: server.URL, ApiKey: testApiKey, AccessToken: "wrong", RetryPolicy: newTestRetryPolicy()}
+	err := swanClient.GetJwtTokenUp3Times()
+	var swanAPIError *SwanAPIError
+	if !errors.Is(err, web.ErrUnauthorized) || !errors.As(err, &swanAPIError) {
+		t.Fatalf("got %v, want a *SwanAPIError matching web.ErrUnauthorized", err)
+	}
+	if calls := server.Calls(swantest.PATH_LOGIN_BY_APIKEY); calls != 1 {
+		t.Fatalf("login calls: got %d, want 1", calls)
+	}
+}
+
+func TestRevokedTokenIsUnauthorized(t *testing.T) {
+	server := swantest.NewServer()
+	defer server.Close()
+	swanClient := newTestSwanClient(t, server)
+	server.RevokeToken(swanClient.SwanToken)
+
+	_, err := swanClient.GetTasks(nil, nil)
+	if !errors.Is(err, web.ErrUnauthorized) {
+		t.Fatalf("got %v, want web.ErrUnauthorized", err)
+	}
+}
+
+func TestCreateAndGetTask(t *testing.T) {
+	server := swantest.NewServer()
+	defer server.Close()
+	swanClient := newTestSwanClient(t, server)
+
+	_, err := swanClient.CreateTask(model.Task{TaskName: "task"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := swanClient.GetAllTasks("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].TaskName != "task" {
+		t.Fatalf("tasks: %+v", tasks)
+	}
+
+	getTaskByUuidResult, err := swanClient.GetTaskByUuid(tasks[0].Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getTaskByUuidResult.Data.Task.Uuid != tasks[0].Uuid {
+		t.Fatalf("task: got %s, want %s", getTaskByUuidResult.Data.Task.Uuid, tasks[0].Uuid)
+	}
+
+	_, err = swanClient.CreateTask(model.Task{}, nil)
+	var swanAPIError *SwanAPIError
+	if !errors.As(err, &swanAPIError) {
+		t.Fatalf("task without name: got %v, want *SwanAPIError", err)
+	}
+}
+
+func TestSwanClientRetriesIdempotentRequests(t *testing.T) {
+	server := swantest.NewServer()
+	defer server.Close()
+	swanClient := newTestSwanClient(t, server)
+	server.InjectFault(swantest.PATH_TASKS, swantest.Fault{HttpStatus: http.StatusServiceUnavailable, Times: 2})
+
+	_, err := swanClient.GetTasks(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := server.Calls(swantest.PATH_TASKS); calls != 3 {
+		t.Fatalf("tasks calls: got %d, want 3", calls)
+	}
+}
@@ -0,0 +1,829 @@
+package swantest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/filswan/go-swan-lib/constants"
+	"github.com/filswan/go-swan-lib/model"
+)
+
+// Heartbeat is recorded for every call to miners/set_heartbeat_online
+type Heartbeat struct {
+	MinerFid string
+	Time     time.Time
+}
+
+// Statistics is recorded for every call to statistics/chain and statistics/node
+type Statistics struct {
+	Path      string
+	UserKey   string
+	ChainName string
+}
+
+type state struct {
+	nextId        int
+	tasks         []*model.Task
+	carFiles      []*model.CarFile
+	carFileStatus map[int]string
+	offlineDeals  []*model.OfflineDeal
+	miners        map[string]*model.Miner
+	datacap       map[string]bool
+	heartbeats    []Heartbeat
+	statistics    []Statistics
+}
+
+func newState() *state {
+	return &state{
+		nextId:        1,
+		carFileStatus: map[int]string{},
+		miners:        map[string]*model.Miner{},
+		datacap:       map[string]bool{},
+	}
+}
+
+func (state *state) newId() int {
+	id := state.nextId
+	state.nextId++
+	return id
+}
+
+func (state *state) taskByUuid(taskUuid string) *model.Task {
+	for _, task := range state.tasks {
+		if task.Uuid == taskUuid {
+			return task
+		}
+	}
+
+	return nil
+}
+
+func (state *state) taskById(taskId int) *model.Task {
+	for _, task := range state.tasks {
+		if task.Id == taskId {
+			return task
+		}
+	}
+
+	return nil
+}
+
+func (state *state) offlineDealById(dealId int) *model.OfflineDeal {
+	for _, offlineDeal := range state.offlineDeals {
+		if offlineDeal.Id == dealId {
+			return offlineDeal
+		}
+	}
+
+	return nil
+}
+
+func (state *state) offlineDealsOfCarFile(carFileId int) []*model.OfflineDeal {
+	offlineDeals := []*model.OfflineDeal{}
+	for _, offlineDeal := range state.offlineDeals {
+		if offlineDeal.CarFileId == carFileId {
+			offlineDeals = append(offlineDeals, copyOfflineDeal(offlineDeal))
+		}
+	}
+
+	return offlineDeals
+}
+
+// addTask stores a copy of task, giving it an id, a uuid when it has none and a creation time
+func (state *state) addTask(task model.Task) *model.Task {
+	task.Id = state.newId()
+	if task.Uuid == "" {
+		task.Uuid = newUuid()
+	}
+	if task.CreatedOn == "" {
+		task.CreatedOn = strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	task.UpdatedOn = task.CreatedOn
+
+	state.tasks = append(state.tasks, &task)
+	return &task
+}
+
+// addFileDescs stores the car files and the offline deals of fileDescs, task may be nil for car files of existing tasks
+func (state *state) addFileDescs(task *model.Task, fileDescs []*model.FileDesc) error {
+	for _, fileDesc := range fileDescs {
+		fileTask := task
+		if fileTask == nil {
+			fileTask = state.taskByUuid(fileDesc.Uuid)
+			if fileTask == nil {
+				return fmt.Errorf("task:%s not found", fileDesc.Uuid)
+			}
+		}
+
+		carFile := &model.CarFile{
+			Id:         state.newId(),
+			TaskId:     fileTask.Id,
+			OriginName: fileDesc.SourceFileName,
+			FileUrl:    fileDesc.CarFileUrl,
+			FileSize:   fileDesc.CarFileSize,
+			PayloadCid: fileDesc.PayloadCid,
+			PieceCid:   fileDesc.PieceCid,
+			CreatedAt:  time.Now().Unix(),
+			UpdatedAt:  time.Now().Unix(),
+		}
+		if fileDesc.CarFileMd5 != "" {
+			carFileMd5 := fileDesc.CarFileMd5
+			carFile.FileMd5 = &carFileMd5
+		}
+		if fileDesc.StartEpoch != nil {
+			carFile.StartEpoch = *fileDesc.StartEpoch
+		}
+		state.carFiles = append(state.carFiles, carFile)
+		state.carFileStatus[carFile.Id] = constants.CAR_FILE_STATUS_CREATED
+
+		for _, deal := range fileDesc.Deals {
+			offlineDeal := newOfflineDeal(fileTask, carFile)
+			offlineDeal.Id = state.newId()
+			offlineDeal.DealCid = deal.DealCid
+			offlineDeal.MinerFid = deal.MinerFid
+			offlineDeal.StartEpoch = deal.StartEpoch
+			offlineDeal.ChainDealId = int64(deal.DealId)
+			offlineDeal.ClientAddr = deal.ClientAddr
+			offlineDeal.AllocationID = deal.AllocationID
+			offlineDeal.Type = deal.Type
+			offlineDeal.Status = deal.StorageStatus
+			if offlineDeal.Status == "" {
+				offlineDeal.Status = constants.OFFLINE_DEAL_STATUS_CREATED
+			}
+			state.offlineDeals = append(state.offlineDeals, offlineDeal)
+		}
+	}
+
+	return nil
+}
+
+func newOfflineDeal(task *model.Task, carFile *model.CarFile) *model.OfflineDeal {
+	taskName := task.TaskName
+	taskUuid := task.Uuid
+	taskType := task.Type
+	sourceId := task.SourceId
+	duration := task.Duration
+
+	offlineDeal := &model.OfflineDeal{
+		TaskId:        task.Id,
+		TaskName:      &taskName,
+		TaskUuid:      &taskUuid,
+		TaskType:      &taskType,
+		SourceId:      &sourceId,
+		Duration:      &duration,
+		FastRetrieval: task.FastRetrieval,
+		MaxPrice:      task.MaxPrice,
+		CarFileId:     carFile.Id,
+		CarFileUrl:    carFile.FileUrl,
+		CarFileSize:   carFile.FileSize,
+		PayloadCid:    carFile.PayloadCid,
+		PieceCid:      carFile.PieceCid,
+		CreatedAt:     strconv.FormatInt(time.Now().Unix(), 10),
+		UpdatedAt:     strconv.FormatInt(time.Now().Unix(), 10),
+	}
+
+	return offlineDeal
+}
+
+func copyOfflineDeal(offlineDeal *model.OfflineDeal) *model.OfflineDeal {
+	offlineDealCopy := *offlineDeal
+	return &offlineDealCopy
+}
+
+// AddTask stores task with its car files and offline deals like tasks/create_task, and returns the stored task
+func (server *Server) AddTask(task model.Task, fileDescs []*model.FileDesc) (*model.Task, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	storedTask := server.state.addTask(task)
+	err := server.state.addFileDescs(storedTask, fileDescs)
+	if err != nil {
+		return nil, err
+	}
+
+	taskCopy := *storedTask
+	return &taskCopy, nil
+}
+
+func (server *Server) Task(taskUuid string) *model.Task {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	task := server.state.taskByUuid(taskUuid)
+	if task == nil {
+		return nil
+	}
+
+	taskCopy := *task
+	return &taskCopy
+}
+
+func (server *Server) SetTaskStatus(taskUuid, status string) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	task := server.state.taskByUuid(taskUuid)
+	if task == nil {
+		return fmt.Errorf("task:%s not found", taskUuid)
+	}
+
+	task.Status = status
+	return nil
+}
+
+// CarFiles returns the car files of the task
+func (server *Server) CarFiles(taskUuid string) []model.CarFile {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	carFiles := []model.CarFile{}
+	task := server.state.taskByUuid(taskUuid)
+	if task == nil {
+		return carFiles
+	}
+
+	for _, carFile := range server.state.carFiles {
+		if carFile.TaskId == task.Id {
+			carFiles = append(carFiles, *carFile)
+		}
+	}
+
+	return carFiles
+}
+
+// SetCarFileStatus sets the status car_files/auto_bid/get_by_status looks car files up by, new car files are Created
+func (server *Server) SetCarFileStatus(carFileId int, status string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.carFileStatus[carFileId] = status
+}
+
+// AddOfflineDeal stores offlineDeal, giving it an id when it has none, and returns the id
+func (server *Server) AddOfflineDeal(offlineDeal model.OfflineDeal) int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if offlineDeal.Id == 0 {
+		offlineDeal.Id = server.state.newId()
+	}
+	server.state.offlineDeals = append(server.state.offlineDeals, &offlineDeal)
+
+	return offlineDeal.Id
+}
+
+func (server *Server) OfflineDeal(dealId int) *model.OfflineDeal {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	offlineDeal := server.state.offlineDealById(dealId)
+	if offlineDeal == nil {
+		return nil
+	}
+
+	return copyOfflineDeal(offlineDeal)
+}
+
+// OfflineDeals returns the offline deals of the task, all of them for an empty task uuid
+func (server *Server) OfflineDeals(taskUuid string) []*model.OfflineDeal {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	offlineDeals := []*model.OfflineDeal{}
+	for _, offlineDeal := range server.state.offlineDeals {
+		if taskUuid == "" || (offlineDeal.TaskUuid != nil && *offlineDeal.TaskUuid == taskUuid) {
+			offlineDeals = append(offlineDeals, copyOfflineDeal(offlineDeal))
+		}
+	}
+
+	return offlineDeals
+}
+
+func (server *Server) SetMiner(miner model.Miner) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if miner.Id == 0 {
+		miner.Id = server.state.newId()
+	}
+	server.state.miners[miner.MinerFid] = &miner
+}
+
+func (server *Server) Miner(minerFid string) *model.Miner {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	miner := server.state.miners[minerFid]
+	if miner == nil {
+		return nil
+	}
+
+	minerCopy := *miner
+	return &minerCopy
+}
+
+// SetDatacap sets what tools/check_datacap answers for the wallet, unknown wallets are not verified
+func (server *Server) SetDatacap(wallet string, isVerified bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.datacap[wallet] = isVerified
+}
+
+// Heartbeats returns the heartbeats received from the miner, from all the miners for an empty miner fid
+func (server *Server) Heartbeats(minerFid string) []Heartbeat {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	heartbeats := []Heartbeat{}
+	for _, heartbeat := range server.state.heartbeats {
+		if minerFid == "" || heartbeat.MinerFid == minerFid {
+			heartbeats = append(heartbeats, heartbeat)
+		}
+	}
+
+	return heartbeats
+}
+
+func (server *Server) Statistics() []Statistics {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]Statistics{}, server.state.statistics...)
+}
+
+func (server *Server) registerRoutes() {
+	server.handle(http.MethodPost, PATH_LOGIN_BY_APIKEY, false, server.loginByApikey)
+	server.handle(http.MethodGet, PATH_TASKS, true, server.getTasks)
+	server.handle(http.MethodPost, PATH_CREATE_TASK, true, server.createTask)
+	server.handle(http.MethodGet, PATH_TASK, true, server.getTask)
+	server.handle(http.MethodGet, PATH_CAR_FILE, true, server.getCarFile)
+	server.handle(http.MethodGet, PATH_AUTO_BID_CAR_FILES, true, server.getAutoBidCarFiles)
+	server.handle(http.MethodGet, PATH_OFFLINE_DEALS_BY_STATUS, true, server.getOfflineDealsByStatus)
+	server.handle(http.MethodPut, PATH_UPDATE_OFFLINE_DEAL, true, server.updateOfflineDeal)
+	server.handle(http.MethodPost, PATH_CREATE_OFFLINE_DEALS, true, server.createOfflineDeals)
+	server.handle(http.MethodPost, PATH_UPDATE_MINER_CONFIG, true, server.updateMinerConfig)
+	server.handle(http.MethodPost, PATH_SET_HEARTBEAT_ONLINE, true, server.setHeartbeatOnline)
+	server.handle(http.MethodGet, PATH_MINER, false, server.getMiner)
+	server.handle(http.MethodGet, PATH_CHECK_DATACAP, false, server.checkDatacap)
+	server.handle(http.MethodPost, PATH_STATISTICS_CHAIN, false, server.statistics(PATH_STATISTICS_CHAIN))
+	server.handle(http.MethodPost, PATH_STATISTICS_NODE, false, server.statistics(PATH_STATISTICS_NODE))
+}
+
+func (server *Server) loginByApikey(r *http.Request, param string) Response {
+	params := struct {
+		Apikey      string `json:"apikey"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err := decodeBody(r, &params)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	server.mutex.Lock()
+	accessToken, ok := server.apiKeys[params.Apikey]
+	server.mutex.Unlock()
+
+	if !ok {
+		return fail(MESSAGE_APIKEY_NOT_FOUND)
+	}
+
+	if accessToken != params.AccessToken {
+		return fail(MESSAGE_ACCESS_TOKEN_WRONG)
+	}
+
+	data := map[string]interface{}{
+		"jwt_token": server.IssueToken(params.Apikey),
+	}
+	return success(data)
+}
+
+// getTasks answers the tasks with the status query parameter if any, limit -1 or none means all of them
+func (server *Server) getTasks(r *http.Request, param string) Response {
+	query := r.URL.Query()
+	status := query.Get("status")
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		limit = -1
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	tasks := []model.Task{}
+	for _, task := range server.state.tasks {
+		if status != "" && task.Status != status {
+			continue
+		}
+		if limit >= 0 && len(tasks) >= limit {
+			break
+		}
+		tasks = append(tasks, *task)
+	}
+
+	data := map[string]interface{}{
+		"task":             tasks,
+		"total_items":      len(tasks),
+		"total_task_count": len(server.state.tasks),
+	}
+	return success(data)
+}
+
+func (server *Server) createTask(r *http.Request, param string) Response {
+	params := struct {
+		Task      model.Task        `json:"task"`
+		FileDescs []*model.FileDesc `json:"file_descs"`
+	}{}
+	err := decodeBody(r, &params)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	if params.Task.TaskName == "" {
+		return fail("task name is required")
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	task := server.state.addTask(params.Task)
+	err = server.state.addFileDescs(task, params.FileDescs)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	response := success(nil)
+	response.Message = fmt.Sprintf("task:%s created", task.Uuid)
+	return response
+}
+
+// getTask answers the task with its car files, bids and a page of its offline deals.
+// deal_complete_rate is left out, swan.GetTaskByUuid decodes it as a string and swan.GetDealListByTaskUuid as a number.
+func (server *Server) getTask(r *http.Request, param string) Response {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	task := server.state.taskByUuid(param)
+	if task == nil {
+		return fail(fmt.Sprintf("task:%s not found", param))
+	}
+
+	carFiles := []model.CarFile{}
+	for _, carFile := range server.state.carFiles {
+		if carFile.TaskId == task.Id {
+			carFiles = append(carFiles, *carFile)
+		}
+	}
+
+	offlineDeals := []*model.OfflineDeal{}
+	for _, offlineDeal := range server.state.offlineDeals {
+		if offlineDeal.TaskId == task.Id {
+			offlineDeals = append(offlineDeals, copyOfflineDeal(offlineDeal))
+		}
+	}
+	totalDealCount := len(offlineDeals)
+	offlineDeals = page(offlineDeals, offset, limit)
+
+	miner := model.Miner{}
+	if task.MinerFid != "" && server.state.miners[task.MinerFid] != nil {
+		miner = *server.state.miners[task.MinerFid]
+	}
+
+	data := map[string]interface{}{
+		"task":             task,
+		"car_file":         carFiles,
+		"miner":            miner,
+		"deal":             offlineDeals,
+		"bids":             []model.Bid{},
+		"total_items":      len(offlineDeals),
+		"total_deal_count": totalDealCount,
+		"total_task_count": len(server.state.tasks),
+		"bid_count":        0,
+	}
+	return success(data)
+}
+
+func (server *Server) getCarFile(r *http.Request, param string) Response {
+	query := r.URL.Query()
+	taskUuid := query.Get("task_uuid")
+	carFileUrl := query.Get("car_file_url")
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	task := server.state.taskByUuid(taskUuid)
+	if task == nil {
+		return fail(fmt.Sprintf("task:%s not found", taskUuid))
+	}
+
+	for _, carFile := range server.state.carFiles {
+		if carFile.TaskId == task.Id && carFile.FileUrl == carFileUrl {
+			offlineDeals := server.state.offlineDealsOfCarFile(carFile.Id)
+			data := map[string]interface{}{
+				"car_file":      carFile,
+				"offline_deals": offlineDeals,
+				"total_items":   len(offlineDeals),
+			}
+			return success(data)
+		}
+	}
+
+	return fail(fmt.Sprintf("car file:%s not found", carFileUrl))
+}
+
+// getAutoBidCarFiles answers the first car file of an auto-bid task with the status, and its offline deals
+func (server *Server) getAutoBidCarFiles(r *http.Request, param string) Response {
+	status := r.URL.Query().Get("car_file_status")
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for _, carFile := range server.state.carFiles {
+		if server.state.carFileStatus[carFile.Id] != status {
+			continue
+		}
+
+		task := server.state.taskById(carFile.TaskId)
+		if task == nil || task.BidMode == nil || *task.BidMode != constants.TASK_BID_MODE_AUTO {
+			continue
+		}
+
+		offlineDeals := server.state.offlineDealsOfCarFile(carFile.Id)
+		data := map[string]interface{}{
+			"car_file":      carFile,
+			"offline_deals": offlineDeals,
+			"total_items":   len(offlineDeals),
+		}
+		return success(data)
+	}
+
+	return success(map[string]interface{}{})
+}
+
+// getOfflineDealsByStatus answers the offline deals matching the filters sent in the body of the GET request
+func (server *Server) getOfflineDealsByStatus(r *http.Request, param string) Response {
+	params := struct {
+		DealStatus string  `json:"status"`
+		TaskUuid   *string `json:"task_uuid"`
+		SourceId   *int    `json:"source_id"`
+		MinerFid   *string `json:"miner_fid"`
+		PageNum    *int    `json:"page_num"`
+		PageSize   *int    `json:"page_size"`
+	}{}
+	err := decodeBody(r, &params)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	offlineDeals := []*model.OfflineDeal{}
+	for _, offlineDeal := range server.state.offlineDeals {
+		if offlineDeal.Status != params.DealStatus {
+			continue
+		}
+		if params.TaskUuid != nil && (offlineDeal.TaskUuid == nil || *offlineDeal.TaskUuid != *params.TaskUuid) {
+			continue
+		}
+		if params.SourceId != nil && (offlineDeal.SourceId == nil || *offlineDeal.SourceId != *params.SourceId) {
+			continue
+		}
+		if params.MinerFid != nil && offlineDeal.MinerFid != *params.MinerFid {
+			continue
+		}
+		offlineDeals = append(offlineDeals, copyOfflineDeal(offlineDeal))
+	}
+
+	sort.Slice(offlineDeals, func(i, j int) bool {
+		return offlineDeals[i].Id < offlineDeals[j].Id
+	})
+
+	if params.PageSize != nil && *params.PageSize > 0 {
+		pageNum := 0
+		if params.PageNum != nil {
+			pageNum = *params.PageNum
+		}
+		offlineDeals = page(offlineDeals, pageNum**params.PageSize, *params.PageSize)
+	}
+
+	data := map[string]interface{}{
+		"offline_deals": offlineDeals,
+	}
+	return success(data)
+}
+
+func (server *Server) updateOfflineDeal(r *http.Request, param string) Response {
+	params := struct {
+		DealId       int     `json:"id"`
+		DealCid      *string `json:"deal_cid"`
+		FilePath     *string `json:"file_path"`
+		Status       string  `json:"status"`
+		StartEpoch   *int    `json:"start_epoch"`
+		Note         *string `json:"note"`
+		ChainDealId  int64   `json:"chain_deal_id"`
+		ClientAddr   string  `json:"client_addr"`
+		AllocationID uint64  `json:"allocation_id"`
+	}{}
+	err := decodeBody(r, &params)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	if params.Status == "" {
+		return fail("status is required")
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	offlineDeal := server.state.offlineDealById(params.DealId)
+	if offlineDeal == nil {
+		return fail(fmt.Sprintf("offline deal:%d not found", params.DealId))
+	}
+
+	offlineDeal.Status = params.Status
+	if params.DealCid != nil {
+		offlineDeal.DealCid = *params.DealCid
+	}
+	if params.FilePath != nil {
+		offlineDeal.FilePath = *params.FilePath
+	}
+	if params.StartEpoch != nil {
+		offlineDeal.StartEpoch = *params.StartEpoch
+	}
+	if params.Note != nil {
+		offlineDeal.Note = *params.Note
+	}
+	if params.ChainDealId != 0 {
+		offlineDeal.ChainDealId = params.ChainDealId
+	}
+	if params.ClientAddr != "" {
+		offlineDeal.ClientAddr = params.ClientAddr
+	}
+	if params.AllocationID != 0 {
+		offlineDeal.AllocationID = params.AllocationID
+	}
+	offlineDeal.UpdatedAt = strconv.FormatInt(time.Now().Unix(), 10)
+
+	return success(nil)
+}
+
+// createOfflineDeals stores the deals of file descriptions whose Uuid is the uuid of an existing task
+func (server *Server) createOfflineDeals(r *http.Request, param string) Response {
+	fileDescs := []*model.FileDesc{}
+	err := decodeBody(r, &fileDescs)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	err = server.state.addFileDescs(nil, fileDescs)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	return success(nil)
+}
+
+func (server *Server) getMiner(r *http.Request, param string) Response {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	miner := server.state.miners[param]
+	if miner == nil {
+		return fail(fmt.Sprintf("miner:%s not found", param))
+	}
+
+	data := map[string]interface{}{
+		"miner": minerJson(miner),
+	}
+	return success(data)
+}
+
+func (server *Server) updateMinerConfig(r *http.Request, param string) Response {
+	params := struct {
+		MinerFid            string `json:"miner_fid"`
+		BidMode             int    `json:"bid_mode"`
+		ExpectedSealingTime int    `json:"expected_sealing_time"`
+		StartEpoch          int    `json:"start_epoch"`
+		AutoBidDealPerDay   int    `json:"auto_bid_deal_per_day"`
+		MarketVersion       string `json:"market_version"`
+	}{}
+	err := decodeBody(r, &params)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	miner := server.state.miners[params.MinerFid]
+	if miner == nil {
+		return fail(fmt.Sprintf("miner:%s not found", params.MinerFid))
+	}
+
+	miner.BidMode = params.BidMode
+	miner.ExpectedSealingTime = params.ExpectedSealingTime
+	miner.StartEpoch = params.StartEpoch
+	miner.AutoBidDealPerDay = params.AutoBidDealPerDay
+	miner.MarketVersion = params.MarketVersion
+
+	data := map[string]interface{}{
+		"miner": minerJson(miner),
+	}
+	return success(data)
+}
+
+// minerJson encodes auto_bid_deal_per_day, which model.Miner only tags for toml
+func minerJson(miner *model.Miner) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                    miner.Id,
+		"miner_fid":             miner.MinerFid,
+		"bid_mode":              miner.BidMode,
+		"expected_sealing_time": miner.ExpectedSealingTime,
+		"start_epoch":           miner.StartEpoch,
+		"auto_bid_deal_per_day": miner.AutoBidDealPerDay,
+		"market_version":        miner.MarketVersion,
+	}
+}
+
+func (server *Server) setHeartbeatOnline(r *http.Request, param string) Response {
+	params := struct {
+		MinerFid string `json:"miner_fid"`
+	}{}
+	err := decodeBody(r, &params)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	miner := server.state.miners[params.MinerFid]
+	if miner == nil {
+		return fail(fmt.Sprintf("miner:%s not found", params.MinerFid))
+	}
+
+	server.state.heartbeats = append(server.state.heartbeats, Heartbeat{MinerFid: params.MinerFid, Time: time.Now()})
+
+	response := success(nil)
+	response.Message = "miner is online"
+	return response
+}
+
+func (server *Server) checkDatacap(r *http.Request, param string) Response {
+	wallet := r.URL.Query().Get("address")
+	if wallet == "" {
+		return fail("address is required")
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	data := map[string]interface{}{
+		"is_verified": server.state.datacap[wallet],
+	}
+	return success(data)
+}
+
+func (server *Server) statistics(path string) func(r *http.Request, param string) Response {
+	return func(r *http.Request, param string) Response {
+		params := struct {
+			ChainName string `json:"chain_name"`
+			UserKey   string `json:"user_key"`
+		}{}
+		err := decodeBody(r, &params)
+		if err != nil {
+			return fail(err.Error())
+		}
+
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+
+		server.state.statistics = append(server.state.statistics, Statistics{Path: path, UserKey: params.UserKey, ChainName: params.ChainName})
+		return success(nil)
+	}
+}
+
+func page(offlineDeals []*model.OfflineDeal, offset, limit int) []*model.OfflineDeal {
+	if offset >= len(offlineDeals) {
+		return []*model.OfflineDeal{}
+	}
+
+	end := offset + limit
+	if end > len(offlineDeals) {
+		end = len(offlineDeals)
+	}
+
+	return offlineDeals[offset:end]
+}
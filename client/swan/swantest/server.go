@@ -0,0 +1,306 @@
+// Package swantest provides an in-process fake swan platform API, with in-memory tasks, car files,
+// offline deals and miners, jwt authentication and fault injection, for hermetic tests of code using the swan package.
+package swantest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-swan-lib/internal/faketest"
+)
+
+const (
+	STATUS_SUCCESS = "success"
+	STATUS_FAIL    = "fail"
+
+	MESSAGE_APIKEY_NOT_FOUND   = "apikey not found"
+	MESSAGE_ACCESS_TOKEN_WRONG = "access token wrong"
+
+	JWT_TTL_DEFAULT = 24 * time.Hour
+)
+
+// paths of the endpoints, the ones ending with a parameter match any value of it
+const (
+	PATH_LOGIN_BY_APIKEY         = "user/login_by_apikey"
+	PATH_TASKS                   = "tasks"
+	PATH_TASK                    = "tasks/{uuid}"
+	PATH_CREATE_TASK             = "tasks/create_task"
+	PATH_CAR_FILE                = "car_files/car_file"
+	PATH_AUTO_BID_CAR_FILES      = "car_files/auto_bid/get_by_status"
+	PATH_OFFLINE_DEALS_BY_STATUS = "offline_deals/get_by_status"
+	PATH_UPDATE_OFFLINE_DEAL     = "offline_deals/update_offline_deal"
+	PATH_CREATE_OFFLINE_DEALS    = "offline_deals/create_offline_deals"
+	PATH_MINER                   = "miners/{fid}"
+	PATH_UPDATE_MINER_CONFIG     = "miners/update_miner_config"
+	PATH_SET_HEARTBEAT_ONLINE    = "miners/set_heartbeat_online"
+	PATH_CHECK_DATACAP           = "tools/check_datacap"
+	PATH_STATISTICS_CHAIN        = "statistics/chain"
+	PATH_STATISTICS_NODE         = "statistics/node"
+)
+
+// Fault is injected in the answers to an endpoint, besides Delay, HttpStatus and Drop it applies Message
+type Fault = faketest.Fault
+
+// Response is the envelope of every answer of the swan platform
+type Response struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type route struct {
+	method   string
+	path     string
+	needAuth bool
+	handler  func(r *http.Request, param string) Response
+}
+
+// Server is a fake swan platform, it is safe for concurrent use
+type Server struct {
+	URL string // api url to configure SwanClient with
+
+	// JwtTTL is the validity of the issued jwt tokens, 0 means JWT_TTL_DEFAULT
+	JwtTTL time.Duration
+
+	faketest.Faults
+
+	httpServer *httptest.Server
+	mutex      sync.Mutex
+	secret     []byte
+	apiKeys    map[string]string
+	revoked    map[string]bool
+	routes     []route
+	state      *state
+}
+
+// NewServer starts a fake swan platform without any api key, task, car file, offline deal or miner
+func NewServer() *Server {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	server := &Server{
+		secret:  secret,
+		apiKeys: map[string]string{},
+		revoked: map[string]bool{},
+		state:   newState(),
+	}
+	server.registerRoutes()
+
+	server.httpServer = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	server.URL = server.httpServer.URL
+
+	return server
+}
+
+func (server *Server) Close() {
+	server.httpServer.Close()
+}
+
+// AddApiKey registers the api key and access token user/login_by_apikey exchanges for a jwt token
+func (server *Server) AddApiKey(apiKey, accessToken string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.apiKeys[apiKey] = accessToken
+}
+
+// IssueToken returns a valid jwt token for apiKey without calling user/login_by_apikey
+func (server *Server) IssueToken(apiKey string) string {
+	return server.signJwt(apiKey, time.Now().Add(server.jwtTTL()))
+}
+
+// RevokeToken makes the endpoints requiring authentication reject token, like an expired one
+func (server *Server) RevokeToken(token string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.revoked[token] = true
+}
+
+// InjectFault makes the calls to the endpoint at path fail, an empty path affects all the endpoints
+func (server *Server) InjectFault(path string, fault Fault) {
+	server.SetFault(path, fault)
+}
+
+func (server *Server) handle(method, path string, needAuth bool, handler func(r *http.Request, param string) Response) {
+	server.routes = append(server.routes, route{method: method, path: path, needAuth: needAuth, handler: handler})
+}
+
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Trim(r.URL.Path, "/")
+
+	route, param := server.match(urlPath)
+	if route == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.Method != route.method {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	fault := server.TakeFault(route.path)
+	if fault.Interrupt(w) {
+		return
+	}
+	if fault != nil && fault.Message != "" {
+		writeJson(w, fail(fault.Message))
+		return
+	}
+
+	if route.needAuth && !server.authenticate(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJson(w, route.handler(r, param))
+}
+
+// match finds the route of urlPath, the exact paths are tried before the ones with a parameter
+func (server *Server) match(urlPath string) (*route, string) {
+	for i := range server.routes {
+		if server.routes[i].path == urlPath {
+			return &server.routes[i], ""
+		}
+	}
+
+	for i := range server.routes {
+		prefix, _, hasParam := strings.Cut(server.routes[i].path, "{")
+		if !hasParam || !strings.HasPrefix(urlPath, prefix) {
+			continue
+		}
+
+		param := strings.TrimPrefix(urlPath, prefix)
+		if param != "" && !strings.Contains(param, "/") {
+			return &server.routes[i], param
+		}
+	}
+
+	return nil, ""
+}
+
+func (server *Server) authenticate(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(authorization, "Bearer ")
+
+	server.mutex.Lock()
+	revoked := server.revoked[token]
+	server.mutex.Unlock()
+
+	return !revoked && server.verifyJwt(token)
+}
+
+func (server *Server) jwtTTL() time.Duration {
+	if server.JwtTTL > 0 {
+		return server.JwtTTL
+	}
+
+	return JWT_TTL_DEFAULT
+}
+
+type jwtClaims struct {
+	Exp int64 `json:"exp"`
+}
+
+// signJwt returns an HS256 jwt token, with a random id so that two tokens issued at once differ
+func (server *Server) signJwt(subject string, expireAt time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+	claims := map[string]interface{}{
+		"sub": subject,
+		"iat": time.Now().Unix(),
+		"exp": expireAt.Unix(),
+		"jti": base64.RawURLEncoding.EncodeToString(nonce),
+	}
+	claimsJson, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(claimsJson)
+
+	return header + "." + payload + "." + server.jwtSignature(header+"."+payload)
+}
+
+func (server *Server) verifyJwt(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	signature := server.jwtSignature(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(signature), []byte(parts[2])) {
+		return false
+	}
+
+	claimsJson, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	claims := jwtClaims{}
+	err = json.Unmarshal(claimsJson, &claims)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Unix() < claims.Exp
+}
+
+func (server *Server) jwtSignature(signingInput string) string {
+	mac := hmac.New(sha256.New, server.secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newUuid returns a random version 4 uuid
+func newUuid() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func success(data interface{}) Response {
+	return Response{Status: STATUS_SUCCESS, Data: data}
+}
+
+func fail(message string) Response {
+	return Response{Status: STATUS_FAIL, Message: message}
+}
+
+// decodeBody decodes the json body of r, an empty body leaves value unchanged
+func decodeBody(r *http.Request, value interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(body, value)
+	if err != nil {
+		return fmt.Errorf("invalid request body:%s", err.Error())
+	}
+
+	return nil
+}
+
+func writeJson(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -1,12 +1,13 @@
 package swan
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 
-	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/filswan/go-swan-lib/model"
@@ -14,28 +15,32 @@ import (
 )
 
 func (swanClient *SwanClient) CreateTask(task model.Task, fileDescs []*model.FileDesc) (*SwanServerResponse, error) {
+	return swanClient.CreateTaskWithContext(context.Background(), task, fileDescs)
+}
+
+func (swanClient *SwanClient) CreateTaskWithContext(ctx context.Context, task model.Task, fileDescs []*model.FileDesc) (*SwanServerResponse, error) {
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "tasks/create_task")
 	params := map[string]interface{}{
 		"task":       task,
 		"file_descs": fileDescs,
 	}
 
-	response, err := web.HttpPost(apiUrl, swanClient.SwanToken, params)
+	response, err := swanClient.httpClient().Request(ctx, http.MethodPost, apiUrl, swanClient.SwanToken, params)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	swanServerResponse := &SwanServerResponse{}
 	err = json.Unmarshal(response, swanServerResponse)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(swanServerResponse.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("error:%s,%s", swanServerResponse.Status, swanServerResponse.Message)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, swanServerResponse.Status, swanServerResponse.Message)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -54,6 +59,10 @@ type GetTaskResultData struct {
 }
 
 func (swanClient *SwanClient) GetTasks(limit *int, status *string) (*GetTaskResult, error) {
+	return swanClient.GetTasksWithContext(context.Background(), limit, status)
+}
+
+func (swanClient *SwanClient) GetTasksWithContext(ctx context.Context, limit *int, status *string) (*GetTaskResult, error) {
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "tasks")
 	filters := ""
 	if limit != nil {
@@ -71,22 +80,22 @@ func (swanClient *SwanClient) GetTasks(limit *int, status *string) (*GetTaskResu
 
 	apiUrl = apiUrl + filters
 
-	response, err := web.HttpGet(apiUrl, swanClient.SwanToken, "")
+	response, err := swanClient.httpClient().Request(ctx, http.MethodGet, apiUrl, swanClient.SwanToken, "")
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	getTaskResult := &GetTaskResult{}
 	err = json.Unmarshal(response, getTaskResult)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(getTaskResult.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("error:%s", getTaskResult.Status)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, getTaskResult.Status, "")
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -94,10 +103,14 @@ func (swanClient *SwanClient) GetTasks(limit *int, status *string) (*GetTaskResu
 }
 
 func (swanClient *SwanClient) GetAllTasks(status string) ([]model.Task, error) {
+	return swanClient.GetAllTasksWithContext(context.Background(), status)
+}
+
+func (swanClient *SwanClient) GetAllTasksWithContext(ctx context.Context, status string) ([]model.Task, error) {
 	limit := -1
-	getTaskResult, err := swanClient.GetTasks(&limit, &status)
+	getTaskResult, err := swanClient.GetTasksWithContext(ctx, &limit, &status)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	return getTaskResult.Data.Task, err
@@ -121,29 +134,35 @@ type GetTaskByUuidResultData struct {
 }
 
 func (swanClient *SwanClient) GetTaskByUuid(taskUuid string) (*GetTaskByUuidResult, error) {
+	return swanClient.GetTaskByUuidWithContext(context.Background(), taskUuid)
+}
+
+func (swanClient *SwanClient) GetTaskByUuidWithContext(ctx context.Context, taskUuid string) (*GetTaskByUuidResult, error) {
+	logger := logs.WithFields(logs.Fields{logs.FIELD_TASK_UUID: taskUuid})
+
 	if len(taskUuid) == 0 {
 		err := fmt.Errorf("please provide task uuid")
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "tasks", taskUuid)
 
-	response, err := web.HttpGet(apiUrl, swanClient.SwanToken, "")
+	response, err := swanClient.httpClient().Request(ctx, http.MethodGet, apiUrl, swanClient.SwanToken, "")
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	getTaskByUuidResult := &GetTaskByUuidResult{}
 	err = json.Unmarshal(response, getTaskByUuidResult)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logger.Error(err)
 		return nil, err
 	}
 
 	if !strings.EqualFold(getTaskByUuidResult.Status, constants.SWAN_API_STATUS_SUCCESS) {
-		err := fmt.Errorf("error:%s", getTaskByUuidResult.Status)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, getTaskByUuidResult.Status, "")
+		logger.Error(err)
 		return nil, err
 	}
 
@@ -2,6 +2,7 @@ package swan
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,20 +11,23 @@ import (
 	"strings"
 	"time"
 
-	"github.com/filswan/go-swan-lib/client/web"
 	"github.com/filswan/go-swan-lib/constants"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/filswan/go-swan-lib/utils"
 )
 
 func (swanClient *SwanClient) CheckDatacap(wallet string) (bool, error) {
+	return swanClient.CheckDatacapWithContext(context.Background(), wallet)
+}
+
+func (swanClient *SwanClient) CheckDatacapWithContext(ctx context.Context, wallet string) (bool, error) {
 	apiUrl := swanClient.ApiUrl + "/tools/check_datacap?address=" + wallet
 	params := url.Values{}
 
-	response, err := web.HttpGetNoToken(apiUrl, strings.NewReader(params.Encode()))
+	response, err := swanClient.httpClient().Request(ctx, http.MethodGet, apiUrl, "", strings.NewReader(params.Encode()))
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return false, err
 	}
 
@@ -31,8 +35,8 @@ func (swanClient *SwanClient) CheckDatacap(wallet string) (bool, error) {
 
 	if !strings.EqualFold(status, constants.SWAN_API_STATUS_SUCCESS) {
 		message := utils.GetFieldStrFromJson(response, "message")
-		err := fmt.Errorf("error:%s,%s", status, message)
-		logs.GetLogger().Error(err)
+		err := newSwanAPIError(apiUrl, status, message)
+		logs.Log().Error(err)
 		return false, err
 	}
 
@@ -43,13 +47,17 @@ func (swanClient *SwanClient) CheckDatacap(wallet string) (bool, error) {
 }
 
 func (swanClient *SwanClient) StatisticsChainInfo(chainId string) error {
+	return swanClient.StatisticsChainInfoWithContext(context.Background(), chainId)
+}
+
+func (swanClient *SwanClient) StatisticsChainInfoWithContext(ctx context.Context, chainId string) error {
 	chainName, ok := constants.ChainMap[chainId]
 	if !ok {
 		return errors.New(fmt.Sprintf("not support chainId: %s", chainId))
 	}
-	err := swanClient.GetJwtTokenUp3Times()
+	err := swanClient.GetJwtTokenUp3TimesWithContext(ctx)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -62,20 +70,31 @@ func (swanClient *SwanClient) StatisticsChainInfo(chainId string) error {
 	reqParam, _ := json.Marshal(req)
 	buffer := bytes.NewBuffer(reqParam)
 
-	client := http.Client{
-		Timeout: 30 * time.Second,
-	}
+	ctx, cancel := context.WithTimeout(ctx, constants.HTTP_API_TIMEOUT_SECOND*time.Second)
+	defer cancel()
+
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "statistics/chain")
-	if _, err := client.Post(apiUrl, "application/json", buffer); err != nil {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiUrl, buffer)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := swanClient.httpClient().Do(request)
+	if err != nil {
 		return err
 	}
+	response.Body.Close()
 	return nil
 }
 
 func (swanClient *SwanClient) StatisticsNodeStatus() error {
-	err := swanClient.GetJwtTokenUp3Times()
+	return swanClient.StatisticsNodeStatusWithContext(context.Background())
+}
+
+func (swanClient *SwanClient) StatisticsNodeStatusWithContext(ctx context.Context) error {
+	err := swanClient.GetJwtTokenUp3TimesWithContext(ctx)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -86,12 +105,19 @@ func (swanClient *SwanClient) StatisticsNodeStatus() error {
 	reqParam, _ := json.Marshal(req)
 	buffer := bytes.NewBuffer(reqParam)
 
-	client := http.Client{
-		Timeout: 30 * time.Second,
-	}
+	ctx, cancel := context.WithTimeout(ctx, constants.HTTP_API_TIMEOUT_SECOND*time.Second)
+	defer cancel()
+
 	apiUrl := utils.UrlJoin(swanClient.ApiUrl, "statistics/node")
-	if _, err = client.Post(apiUrl, "application/json", buffer); err != nil {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiUrl, buffer)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := swanClient.httpClient().Do(request)
+	if err != nil {
 		return err
 	}
+	response.Body.Close()
 	return nil
 }
@@ -0,0 +1,278 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+type ClientConfig struct {
+	Timeout             time.Duration     // default timeout of each request, 0 means no timeout
+	CaCertFile          string            // PEM bundle trusted in addition to the system roots
+	ClientCertFile      string            // PEM client certificate for mutual TLS
+	ClientKeyFile       string            // PEM private key of ClientCertFile
+	InsecureSkipVerify  bool              // skip server certificate verification, never use it in production
+	ProxyUrl            string            // proxy for all requests, empty means using HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	Headers             map[string]string // headers sent with every request unless set by the request itself
+	MaxIdleConnsPerHost int               // 0 means the net/http default
+	RetryPolicy         *RetryPolicy      // nil means no retry
+}
+
+// Client is safe for concurrent use and should be reused so that connections are pooled
+type Client struct {
+	httpClient  *http.Client
+	timeout     time.Duration
+	headers     map[string]string
+	retryPolicy *RetryPolicy
+}
+
+var (
+	defaultClient      *Client
+	defaultClientMutex sync.RWMutex
+)
+
+func NewClient(config ClientConfig) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	}
+
+	tlsConfig, err := newTlsConfig(config)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if config.ProxyUrl != "" {
+		proxyUrl, err := url.Parse(config.ProxyUrl)
+		if err != nil {
+			err := fmt.Errorf("invalid proxy url:%s, %s", config.ProxyUrl, err.Error())
+			logs.Log().Error(err)
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	headers := map[string]string{}
+	for key, val := range config.Headers {
+		headers[key] = val
+	}
+
+	client := &Client{
+		httpClient:  &http.Client{Transport: transport},
+		timeout:     config.Timeout,
+		headers:     headers,
+		retryPolicy: config.RetryPolicy,
+	}
+
+	return client, nil
+}
+
+func newTlsConfig(config ClientConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CaCertFile != "" {
+		caCerts, err := os.ReadFile(config.CaCertFile)
+		if err != nil {
+			return nil, err
+		}
+
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		if !rootCAs.AppendCertsFromPEM(caCerts) {
+			err := fmt.Errorf("no certificate found in:%s", config.CaCertFile)
+			return nil, err
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// DefaultClient returns the client used by the package level functions
+func DefaultClient() *Client {
+	defaultClientMutex.RLock()
+	client := defaultClient
+	defaultClientMutex.RUnlock()
+	if client != nil {
+		return client
+	}
+
+	defaultClientMutex.Lock()
+	defer defaultClientMutex.Unlock()
+	if defaultClient == nil {
+		// an empty config never fails
+		defaultClient, _ = NewClient(ClientConfig{})
+	}
+
+	return defaultClient
+}
+
+// SetDefaultClient replaces the client used by the package level functions, nil restores the built-in one
+func SetDefaultClient(client *Client) {
+	defaultClientMutex.Lock()
+	defer defaultClientMutex.Unlock()
+	defaultClient = client
+}
+
+// Do sends the request with the default headers of the client, the caller must close the response body
+func (client *Client) Do(request *http.Request) (*http.Response, error) {
+	for key, val := range client.headers {
+		if request.Header.Get(key) == "" {
+			request.Header.Set(key, val)
+		}
+	}
+
+	return client.httpClient.Do(request)
+}
+
+// WithRetryPolicy returns a client sharing the connections of client but retrying with retryPolicy
+func (client *Client) WithRetryPolicy(retryPolicy *RetryPolicy) *Client {
+	clientCopy := *client
+	clientCopy.retryPolicy = retryPolicy
+	return &clientCopy
+}
+
+func (client *Client) RetryPolicy() *RetryPolicy {
+	return client.retryPolicy
+}
+
+// Request sends params as the request body and returns the response body when the status is 200.
+// params of type io.Reader is sent as a form, others are encoded as json.
+// Idempotent requests are retried according to the retry policy of the client, see IsIdempotent.
+func (client *Client) Request(ctx context.Context, httpMethod, uri, tokenString string, params interface{}) ([]byte, error) {
+	var newBody func() (io.Reader, error)
+	var contentType string
+	replayable := true
+
+	switch params := params.(type) {
+	case io.Reader:
+		seeker, isSeeker := params.(io.Seeker)
+		replayable = isSeeker
+		newBody = func() (io.Reader, error) {
+			if isSeeker {
+				_, err := seeker.Seek(0, io.SeekStart)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return params, nil
+		}
+		contentType = HTTP_CONTENT_TYPE_FORM
+	default:
+		jsonReq, err := json.Marshal(params)
+		if err != nil {
+			logs.Log().Error(err)
+			return nil, err
+		}
+		newBody = func() (io.Reader, error) {
+			return bytes.NewReader(jsonReq), nil
+		}
+		contentType = HTTP_CONTENT_TYPE_JSON
+	}
+
+	idempotent := replayable && IsIdempotent(ctx, httpMethod)
+
+	var responseBody []byte
+	err := client.retryPolicy.Retry(ctx, func(attempt int) (bool, error) {
+		if attempt > 1 {
+			logs.Log().Info("retrying ", httpMethod, " ", uri, ", attempt:", attempt)
+		}
+
+		body, err := newBody()
+		if err != nil {
+			logs.Log().Error(err)
+			return false, err
+		}
+
+		responseBody, err = client.request(ctx, httpMethod, uri, tokenString, contentType, body)
+		if err != nil {
+			return idempotent && client.retryPolicy.IsRetryableError(err), err
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responseBody, nil
+}
+
+func (client *Client) request(parentCtx context.Context, httpMethod, uri, tokenString, contentType string, body io.Reader) ([]byte, error) {
+	ctx := parentCtx
+	if client.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.timeout)
+		defer cancel()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, httpMethod, uri, body)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+
+	if len(strings.Trim(tokenString, " ")) > 0 {
+		request.Header.Set("Authorization", "Bearer "+tokenString)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		logs.Log().Error(err)
+		if parentCtx.Err() != nil {
+			return nil, err
+		}
+		return nil, &transportError{Err: err}
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		err := newHTTPStatusError(response, uri)
+		logs.Log().Error(err)
+		switch response.StatusCode {
+		case http.StatusNotFound:
+			logs.Log().Error("please check your url:", uri)
+		case http.StatusUnauthorized:
+			logs.Log().Error("authentication failed, please check your token")
+		}
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return responseBody, nil
+}
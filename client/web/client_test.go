@@ -0,0 +1,47 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+// captureLogs makes the library log to the returned buffer until the end of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	logger, err := logs.NewLogger(logs.Config{Output: buf, NoLogFiles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := logs.Log()
+	logs.SetLogger(logger)
+	t.Cleanup(func() { logs.SetLogger(previous) })
+
+	return buf
+}
+
+func TestRequestDoesNotLogToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	logsBuf := captureLogs(t)
+
+	_, err := DefaultClient().Request(context.Background(), http.MethodGet, server.URL, "secret-token", nil)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("got %v, want ErrUnauthorized", err)
+	}
+	if strings.Contains(logsBuf.String(), "secret-token") {
+		t.Fatalf("token logged:\n%s", logsBuf.String())
+	}
+	if !strings.Contains(logsBuf.String(), "authentication failed") {
+		t.Fatalf("authentication failure not logged:\n%s", logsBuf.String())
+	}
+}
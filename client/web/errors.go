@@ -0,0 +1,71 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTP_ERROR_BODY_MAX bounds the response body kept in an HTTPStatusError
+const HTTP_ERROR_BODY_MAX = 64 * 1024
+
+// Sentinel errors shared by the clients of this library, test them with errors.Is
+var (
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrNotFound         = errors.New("not found")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// HTTPStatusError is returned when the server answers with a status other than 200
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+	Url        string
+	Body       []byte // the start of the response body, e.g. a JSON-RPC error, at most HTTP_ERROR_BODY_MAX bytes
+}
+
+// newHTTPStatusError reads the start of the body of response, which the caller still has to close
+func newHTTPStatusError(response *http.Response, uri string) *HTTPStatusError {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, HTTP_ERROR_BODY_MAX))
+
+	statusErr := &HTTPStatusError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Url:        uri,
+		Body:       body,
+	}
+
+	return statusErr
+}
+
+func (statusErr *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status: %s, code:%d, url:%s", statusErr.Status, statusErr.StatusCode, statusErr.Url)
+}
+
+// Is maps 401, 403 and 404 to ErrUnauthorized, ErrPermissionDenied and ErrNotFound
+func (statusErr *HTTPStatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return statusErr.StatusCode == http.StatusUnauthorized
+	case ErrPermissionDenied:
+		return statusErr.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return statusErr.StatusCode == http.StatusNotFound
+	}
+
+	return false
+}
+
+// transportError is a failure to get any response from the server
+type transportError struct {
+	Err error
+}
+
+func (transportErr *transportError) Error() string {
+	return transportErr.Err.Error()
+}
+
+func (transportErr *transportError) Unwrap() error {
+	return transportErr.Err
+}
@@ -1,300 +1,178 @@
 package web
 
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/json"
-	"fmt"
-	"io"
-	"io/ioutil"
-	"mime/multipart"
+	"context"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/filswan/go-swan-lib/logs"
-	"github.com/filswan/go-swan-lib/utils"
 )
 
 const HTTP_CONTENT_TYPE_FORM = "application/x-www-form-urlencoded"
 const HTTP_CONTENT_TYPE_JSON = "application/json; charset=UTF-8"
 
 func HttpPostNoToken(uri string, params interface{}) ([]byte, error) {
-	response, err := HttpRequest(http.MethodPost, uri, "", params, nil)
+	return HttpPostNoTokenWithContext(context.Background(), uri, params)
+}
+
+func HttpPostNoTokenWithContext(ctx context.Context, uri string, params interface{}) ([]byte, error) {
+	response, err := HttpRequestWithContext(ctx, http.MethodPost, uri, "", params, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	return response, nil
 }
 
 func HttpPost(uri, tokenString string, params interface{}) ([]byte, error) {
-	response, err := HttpRequest(http.MethodPost, uri, tokenString, params, nil)
+	return HttpPostWithContext(context.Background(), uri, tokenString, params)
+}
+
+func HttpPostWithContext(ctx context.Context, uri, tokenString string, params interface{}) ([]byte, error) {
+	response, err := HttpRequestWithContext(ctx, http.MethodPost, uri, tokenString, params, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	return response, nil
 }
 
 func HttpGetNoToken(uri string, params interface{}) ([]byte, error) {
-	response, err := HttpRequest(http.MethodGet, uri, "", params, nil)
+	return HttpGetNoTokenWithContext(context.Background(), uri, params)
+}
+
+func HttpGetNoTokenWithContext(ctx context.Context, uri string, params interface{}) ([]byte, error) {
+	response, err := HttpRequestWithContext(ctx, http.MethodGet, uri, "", params, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	return response, nil
 }
 
 func HttpGetNoTokenTimeout(uri string, params interface{}, timeoutSecond *int) ([]byte, error) {
-	response, err := HttpRequest(http.MethodGet, uri, "", params, timeoutSecond)
+	return HttpGetNoTokenTimeoutWithContext(context.Background(), uri, params, timeoutSecond)
+}
+
+func HttpGetNoTokenTimeoutWithContext(ctx context.Context, uri string, params interface{}, timeoutSecond *int) ([]byte, error) {
+	response, err := HttpRequestWithContext(ctx, http.MethodGet, uri, "", params, timeoutSecond)
 	if err != nil {
-		logs.GetLogger().Error()
+		logs.Log().Error()
 		return nil, err
 	}
 	return response, nil
 }
 
 func HttpGet(uri, tokenString string, params interface{}) ([]byte, error) {
-	response, err := HttpRequest(http.MethodGet, uri, tokenString, params, nil)
+	return HttpGetWithContext(context.Background(), uri, tokenString, params)
+}
+
+func HttpGetWithContext(ctx context.Context, uri, tokenString string, params interface{}) ([]byte, error) {
+	response, err := HttpRequestWithContext(ctx, http.MethodGet, uri, tokenString, params, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	return response, nil
 }
 
 func HttpPut(uri, tokenString string, params interface{}) ([]byte, error) {
-	response, err := HttpRequest(http.MethodPut, uri, tokenString, params, nil)
+	return HttpPutWithContext(context.Background(), uri, tokenString, params)
+}
+
+func HttpPutWithContext(ctx context.Context, uri, tokenString string, params interface{}) ([]byte, error) {
+	response, err := HttpRequestWithContext(ctx, http.MethodPut, uri, tokenString, params, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	return response, nil
 }
 
 func HttpDelete(uri, tokenString string, params interface{}) ([]byte, error) {
-	response, err := HttpRequest(http.MethodDelete, uri, tokenString, params, nil)
+	return HttpDeleteWithContext(context.Background(), uri, tokenString, params)
+}
+
+func HttpDeleteWithContext(ctx context.Context, uri, tokenString string, params interface{}) ([]byte, error) {
+	response, err := HttpRequestWithContext(ctx, http.MethodDelete, uri, tokenString, params, nil)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 	return response, nil
 }
 
 func HttpRequest(httpMethod, uri, tokenString string, params interface{}, timeoutSecond *int) ([]byte, error) {
-	var request *http.Request
-	var err error
-
-	switch params := params.(type) {
-	case io.Reader:
-		request, err = http.NewRequest(httpMethod, uri, params)
-		if err != nil {
-			logs.GetLogger().Error(err)
-			return nil, err
-		}
-		request.Header.Set("Content-Type", HTTP_CONTENT_TYPE_FORM)
-	default:
-		jsonReq, errJson := json.Marshal(params)
-		if errJson != nil {
-			logs.GetLogger().Error(errJson)
-			return nil, errJson
-		}
-
-		request, err = http.NewRequest(httpMethod, uri, bytes.NewBuffer(jsonReq))
-		if err != nil {
-			logs.GetLogger().Error(err)
-			return nil, err
-		}
-		request.Header.Set("Content-Type", HTTP_CONTENT_TYPE_JSON)
-	}
-
-	if len(strings.Trim(tokenString, " ")) > 0 {
-		request.Header.Set("Authorization", "Bearer "+tokenString)
-	}
-
-	customTransport := http.DefaultTransport.(*http.Transport).Clone()
-	customTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return HttpRequestWithContext(context.Background(), httpMethod, uri, tokenString, params, timeoutSecond)
+}
 
-	client := &http.Client{Transport: customTransport}
+func HttpRequestWithContext(ctx context.Context, httpMethod, uri, tokenString string, params interface{}, timeoutSecond *int) ([]byte, error) {
 	if timeoutSecond != nil {
-		client.Timeout = time.Duration(*timeoutSecond) * time.Second
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(*timeoutSecond)*time.Second)
+		defer cancel()
 	}
 
-	response, err := client.Do(request)
-
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		err := fmt.Errorf("http status: %s, code:%d, url:%s", response.Status, response.StatusCode, uri)
-		logs.GetLogger().Error(err)
-		switch response.StatusCode {
-		case http.StatusNotFound:
-			logs.GetLogger().Error("please check your url:", uri)
-		case http.StatusUnauthorized:
-			logs.GetLogger().Error("Please check your token:", tokenString)
-		}
-		return nil, err
-	}
-
-	responseBody, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	return responseBody, nil
+	return DefaultClient().Request(ctx, httpMethod, uri, tokenString, params)
 }
 
 func HttpPutFile(url string, tokenString string, paramTexts map[string]string, paramFilename, paramFilepath string) (string, error) {
-	response, err := HttpRequestFile(http.MethodPut, url, tokenString, paramTexts, paramFilename, paramFilepath)
+	return HttpPutFileWithContext(context.Background(), url, tokenString, paramTexts, paramFilename, paramFilepath)
+}
+
+func HttpPutFileWithContext(ctx context.Context, url string, tokenString string, paramTexts map[string]string, paramFilename, paramFilepath string) (string, error) {
+	response, err := HttpRequestFileWithContext(ctx, http.MethodPut, url, tokenString, paramTexts, paramFilename, paramFilepath)
 	return response, err
 }
 
 func HttpPostFile(url string, tokenString string, paramTexts map[string]string, paramFilename, paramFilepath string) (string, error) {
-	response, err := HttpRequestFile(http.MethodPost, url, tokenString, paramTexts, paramFilename, paramFilepath)
+	return HttpPostFileWithContext(context.Background(), url, tokenString, paramTexts, paramFilename, paramFilepath)
+}
+
+func HttpPostFileWithContext(ctx context.Context, url string, tokenString string, paramTexts map[string]string, paramFilename, paramFilepath string) (string, error) {
+	response, err := HttpRequestFileWithContext(ctx, http.MethodPost, url, tokenString, paramTexts, paramFilename, paramFilepath)
 	return response, err
 }
 
 func HttpRequestFile(httpMethod, url string, tokenString string, paramTexts map[string]string, paramFilename, paramFilepath string) (string, error) {
-	filename, fileContent, err := utils.ReadFile(paramFilepath)
-	if err != nil {
-		logs.GetLogger().Info(err)
-		return "", err
-	}
-
-	bodyBuf := new(bytes.Buffer)
-	bodyWriter := multipart.NewWriter(bodyBuf)
-
-	fileWriter, err := bodyWriter.CreateFormFile(paramFilename, filename)
-	if err != nil {
-		bodyWriter.Close()
-		logs.GetLogger().Info(err)
-		return "", err
-	}
-
-	fileWriter.Write(fileContent)
-
-	for key, val := range paramTexts {
-		err = bodyWriter.WriteField(key, val)
-		if err != nil {
-			bodyWriter.Close()
-			logs.GetLogger().Info(err)
-			return "", err
-		}
-	}
-
-	bodyWriter.Close()
-
-	request, err := http.NewRequest(httpMethod, url, bodyBuf)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return "", nil
-	}
-
-	request.Header.Set("Content-Type", bodyWriter.FormDataContentType())
-	if len(strings.Trim(tokenString, " ")) > 0 {
-		request.Header.Set("Authorization", "Bearer "+tokenString)
-	}
+	return HttpRequestFileWithContext(context.Background(), httpMethod, url, tokenString, paramTexts, paramFilename, paramFilepath)
+}
 
-	client := &http.Client{}
-	response, err := client.Do(request)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return "", nil
-	}
+func HttpRequestFileWithContext(ctx context.Context, httpMethod, url string, tokenString string, paramTexts map[string]string, paramFilename, paramFilepath string) (string, error) {
+	return HttpRequestFileWithProgress(ctx, httpMethod, url, tokenString, paramTexts, paramFilename, paramFilepath, nil)
+}
 
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		err := fmt.Errorf("http status:%s, code:%d, url:%s", response.Status, response.StatusCode, url)
-		logs.GetLogger().Error(err)
-		switch response.StatusCode {
-		case http.StatusNotFound:
-			logs.GetLogger().Error("please check your url:", url)
-		case http.StatusUnauthorized:
-			logs.GetLogger().Error("Please check your token:", tokenString)
-		}
-		return "", err
+// HttpRequestFileWithProgress streams the file at paramFilepath from disk as the field paramFilename of a
+// multipart body with the fields paramTexts, and returns the response body when the status is 200
+func HttpRequestFileWithProgress(ctx context.Context, httpMethod, url string, tokenString string, paramTexts map[string]string, paramFilename, paramFilepath string, progress UploadProgress) (string, error) {
+	upload := MultipartUpload{
+		Fields: paramTexts,
+		Files: []UploadFile{{
+			FieldName: paramFilename,
+			FilePath:  paramFilepath,
+		}},
+		TokenString: tokenString,
+		Progress:    progress,
 	}
 
-	responseBody, err := ioutil.ReadAll(response.Body)
+	response, err := HttpUploadMultipartWithContext(ctx, httpMethod, url, upload)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return "", err
 	}
 
-	responseStr := string(responseBody)
-
-	return responseStr, nil
+	return string(response), nil
 }
 
 func HttpUploadFileByStream(uri, filefullpath string) ([]byte, error) {
-	fileReader, err := os.Open(filefullpath)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	filename := filepath.Base(filefullpath)
-
-	boundary := "MyMultiPartBoundary12345"
-	token := "DEPLOY_GATE_TOKEN"
-	message := "Uploaded by Nebula"
-	releaseNote := "Built by Nebula"
-	fieldFormat := "--%s\r\nContent-Disposition: form-data; name=\"%s\"\r\n\r\n%s\r\n"
-	tokenPart := fmt.Sprintf(fieldFormat, boundary, "token", token)
-	messagePart := fmt.Sprintf(fieldFormat, boundary, "message", message)
-	releaseNotePart := fmt.Sprintf(fieldFormat, boundary, "release_note", releaseNote)
-	fileName := filename
-	fileHeader := "Content-type: application/octet-stream"
-	fileFormat := "--%s\r\nContent-Disposition: form-data; name=\"file\"; filename=\"%s\"\r\n%s\r\n\r\n"
-	filePart := fmt.Sprintf(fileFormat, boundary, fileName, fileHeader)
-	bodyTop := fmt.Sprintf("%s%s%s%s", tokenPart, messagePart, releaseNotePart, filePart)
-	bodyBottom := fmt.Sprintf("\r\n--%s--\r\n", boundary)
-	body := io.MultiReader(strings.NewReader(bodyTop), fileReader, strings.NewReader(bodyBottom))
-
-	contentType := fmt.Sprintf("multipart/form-data; boundary=%s", boundary)
-
-	response, err := http.Post(uri, contentType, body)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, nil
-	}
-
-	defer response.Body.Close()
+	return HttpUploadFileByStreamWithContext(context.Background(), uri, filefullpath)
+}
 
-	if response.StatusCode != http.StatusOK {
-		err := fmt.Errorf("http status:%s, code:%d, url:%s", response.Status, response.StatusCode, uri)
-		logs.GetLogger().Error(err)
-		switch response.StatusCode {
-		case http.StatusNotFound:
-			logs.GetLogger().Error("please check your url:", uri)
-		}
-		return nil, err
+// HttpUploadFileByStreamWithContext streams a file as the field file of a multipart POST and returns the raw response
+func HttpUploadFileByStreamWithContext(ctx context.Context, uri, filefullpath string) ([]byte, error) {
+	upload := MultipartUpload{
+		Files: []UploadFile{{FilePath: filefullpath}},
 	}
 
-	responseBody, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-
-	responseStr := string(responseBody)
-	//logs.GetLogger().Info(responseStr)
-	filesInfo := strings.Split(responseStr, "\n")
-	if len(filesInfo) < 4 {
-		err := fmt.Errorf("not enough files info returned, ipfs response:%s", responseStr)
-		logs.GetLogger().Error(err)
-		return nil, err
-	}
-	responseStr = filesInfo[3]
-	return []byte(responseStr), nil
+	return HttpUploadMultipartWithContext(ctx, http.MethodPost, uri, upload)
 }
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/filswan/go-swan-lib/internal/faketest"
+)
+
+// newTestFile writes a file big enough to still be sent when the server stops reading it
+func newTestFile(t *testing.T) string {
+	filePath := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(filePath, make([]byte, 4<<20), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filePath
+}
+
+func TestHttpRequestFileDroppedConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.CopyN(io.Discard, r.Body, 1024)
+		faketest.DropConnection(w)
+	}))
+	defer server.Close()
+
+	response, err := HttpRequestFile(http.MethodPost, server.URL, "", map[string]string{"a": "1"}, "file", newTestFile(t))
+	if err == nil || response != "" {
+		t.Fatalf("got %q, %v, want an error", response, err)
+	}
+}
+
+func TestHttpRequestFileWithProgressCancelled(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.CopyN(io.Discard, r.Body, 1024)
+		close(started)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	progressCalled := atomic.Bool{}
+	progress := func(bytesSent, bytesTotal int64) { progressCalled.Store(true) }
+	response, err := HttpRequestFileWithProgress(ctx, http.MethodPost, server.URL, "", nil, "file", newTestFile(t), progress)
+	if !errors.Is(err, context.Canceled) || response != "" {
+		t.Fatalf("got %q, %v, want context.Canceled", response, err)
+	}
+	if !progressCalled.Load() {
+		t.Fatal("progress not called before the cancellation")
+	}
+}
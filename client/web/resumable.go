@@ -0,0 +1,376 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	TUS_RESUMABLE_VERSION = "1.0.0"
+
+	HTTP_CONTENT_TYPE_OFFSET_OCTET_STREAM = "application/offset+octet-stream"
+	// answered by tus servers when the checksum of a chunk does not match
+	HTTP_STATUS_CHECKSUM_MISMATCH = 460
+
+	RESUMABLE_CHUNK_SIZE_DEFAULT = 16 * 1024 * 1024
+	// the state of the upload of a file is kept next to it unless StateFilePath is set
+	RESUMABLE_STATE_FILE_SUFFIX = ".upload.json"
+)
+
+// ResumableUpload uploads a file in chunks with the tus 1.0 protocol and its creation and checksum extensions,
+// each chunk with its sha256. The location of the upload is persisted so that a restarted process resumes it
+// from the offset the server has.
+type ResumableUpload struct {
+	CreationUrl   string // url creating the uploads on the server
+	FilePath      string
+	ChunkSize     int64             // RESUMABLE_CHUNK_SIZE_DEFAULT when 0
+	StateFilePath string            // FilePath+RESUMABLE_STATE_FILE_SUFFIX when empty
+	TokenString   string            // sent as a bearer token when not empty
+	Metadata      map[string]string // sent with the creation, the base of FilePath is added as filename
+	Progress      UploadProgress    // nil means no progress reporting
+	Timeout       time.Duration     // of each request, 0 means none, a stalled chunk is then retried
+}
+
+// resumableState is what is needed to resume an upload, the offset is asked to the server
+type resumableState struct {
+	CreationUrl string    `json:"creation_url"`
+	Location    string    `json:"location"`
+	FileSize    int64     `json:"file_size"`
+	FileModTime time.Time `json:"file_mod_time"`
+}
+
+func HttpUploadResumable(upload ResumableUpload) (string, error) {
+	return HttpUploadResumableWithContext(context.Background(), upload)
+}
+
+func HttpUploadResumableWithContext(ctx context.Context, upload ResumableUpload) (string, error) {
+	return DefaultClient().UploadResumable(ctx, upload)
+}
+
+// UploadResumable uploads the file of upload, resuming its previous upload when the file did not change,
+// and returns the url of the upload on the server. The chunks are retried with the retry policy of the client,
+// DefaultRetryPolicy() when it has none, after asking the server the offset it has.
+func (client *Client) UploadResumable(ctx context.Context, upload ResumableUpload) (string, error) {
+	if upload.ChunkSize <= 0 {
+		upload.ChunkSize = RESUMABLE_CHUNK_SIZE_DEFAULT
+	}
+	if upload.StateFilePath == "" {
+		upload.StateFilePath = upload.FilePath + RESUMABLE_STATE_FILE_SUFFIX
+	}
+
+	file, err := os.Open(upload.FilePath)
+	if err != nil {
+		logs.Log().Error(err)
+		return "", err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		logs.Log().Error(err)
+		return "", err
+	}
+
+	state := &resumableState{
+		CreationUrl: upload.CreationUrl,
+		FileSize:    fileInfo.Size(),
+		FileModTime: fileInfo.ModTime(),
+	}
+
+	offset := int64(-1)
+	previousState, err := readResumableState(upload.StateFilePath)
+	if err == nil && previousState.CreationUrl == state.CreationUrl && previousState.FileSize == state.FileSize &&
+		previousState.FileModTime.Equal(state.FileModTime) {
+		offset, err = client.uploadOffset(ctx, upload, previousState.Location)
+		if err == nil {
+			state.Location = previousState.Location
+			logs.Log().Info("resuming the upload of ", upload.FilePath, " at offset:", offset, ", location:", state.Location)
+		} else {
+			logs.Log().Warn("cannot resume the upload of ", upload.FilePath, ", uploading it again, ", err)
+		}
+	}
+
+	if state.Location == "" {
+		state.Location, err = client.createUpload(ctx, upload, state.FileSize)
+		if err != nil {
+			logs.Log().Error(err)
+			return "", err
+		}
+		offset = 0
+
+		err = writeResumableState(upload.StateFilePath, state)
+		if err != nil {
+			logs.Log().Error(err)
+			return "", err
+		}
+	}
+
+	retryPolicy := client.retryPolicy
+	if retryPolicy == nil {
+		retryPolicy = DefaultRetryPolicy()
+	}
+
+	chunk := make([]byte, upload.ChunkSize)
+	for offset < state.FileSize {
+		err := retryPolicy.Retry(ctx, func(attempt int) (bool, error) {
+			if attempt > 1 {
+				logs.Log().Info("retrying the chunk at offset:", offset, " of ", upload.FilePath, ", attempt:", attempt)
+
+				// the server may have kept part of the chunk, or none of it
+				serverOffset, err := client.uploadOffset(ctx, upload, state.Location)
+				if err != nil {
+					return ctx.Err() == nil && retryPolicy.isRetryableUploadError(err), err
+				}
+				offset = serverOffset
+				if offset >= state.FileSize {
+					return false, nil
+				}
+			}
+
+			n, err := file.ReadAt(chunk[:min64(upload.ChunkSize, state.FileSize-offset)], offset)
+			if err != nil {
+				return false, err
+			}
+
+			newOffset, err := client.patchUpload(ctx, upload, state.Location, offset, chunk[:n])
+			if err != nil {
+				return ctx.Err() == nil && retryPolicy.isRetryableUploadError(err), err
+			}
+
+			offset = newOffset
+			return false, nil
+		})
+		if err != nil {
+			logs.Log().Error(err)
+			return "", err
+		}
+
+		if upload.Progress != nil {
+			upload.Progress(offset, state.FileSize)
+		}
+	}
+
+	err = os.Remove(upload.StateFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		logs.Log().Warn(err)
+	}
+
+	logs.Log().Info(state.FileSize, " bytes of ", upload.FilePath, " uploaded to:", state.Location)
+	return state.Location, nil
+}
+
+// isRetryableUploadError also retries the chunks the server rejected for their offset or their checksum
+func (retryPolicy *RetryPolicy) isRetryableUploadError(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusConflict, HTTP_STATUS_CHECKSUM_MISMATCH:
+			return true
+		}
+	}
+
+	return retryPolicy.IsRetryableError(err)
+}
+
+// createUpload creates the upload and returns its url
+func (client *Client) createUpload(ctx context.Context, upload ResumableUpload, fileSize int64) (string, error) {
+	metadata := map[string]string{"filename": filepath.Base(upload.FilePath)}
+	for key, val := range upload.Metadata {
+		metadata[key] = val
+	}
+
+	response, err := client.tusRequest(ctx, upload, http.MethodPost, upload.CreationUrl, nil, map[string]string{
+		"Upload-Length":   strconv.FormatInt(fileSize, 10),
+		"Upload-Metadata": encodeUploadMetadata(metadata),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusCreated {
+		return "", newHTTPStatusError(response, upload.CreationUrl)
+	}
+
+	location, err := response.Location()
+	if err != nil {
+		err := fmt.Errorf("no upload location answered by:%s, %s", upload.CreationUrl, err.Error())
+		return "", err
+	}
+
+	return location.String(), nil
+}
+
+// uploadOffset asks the server the bytes of the upload it has
+func (client *Client) uploadOffset(ctx context.Context, upload ResumableUpload, location string) (int64, error) {
+	response, err := client.tusRequest(ctx, upload, http.MethodHead, location, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNoContent {
+		return 0, newHTTPStatusError(response, location)
+	}
+
+	return parseUploadOffset(response, location)
+}
+
+// patchUpload sends a chunk at offset with its checksum and returns the new offset of the upload
+func (client *Client) patchUpload(ctx context.Context, upload ResumableUpload, location string, offset int64, chunk []byte) (int64, error) {
+	checksum := sha256.Sum256(chunk)
+
+	response, err := client.tusRequest(ctx, upload, http.MethodPatch, location, chunk, map[string]string{
+		"Content-Type":    HTTP_CONTENT_TYPE_OFFSET_OCTET_STREAM,
+		"Upload-Offset":   strconv.FormatInt(offset, 10),
+		"Upload-Checksum": "sha256 " + base64.StdEncoding.EncodeToString(checksum[:]),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		return 0, newHTTPStatusError(response, location)
+	}
+
+	newOffset, err := parseUploadOffset(response, location)
+	if err != nil {
+		return 0, err
+	}
+	if newOffset != offset+int64(len(chunk)) {
+		err := fmt.Errorf("upload offset:%d after sending %d bytes at offset:%d, url:%s", newOffset, len(chunk), offset, location)
+		return 0, err
+	}
+
+	return newOffset, nil
+}
+
+// tusRequest sends a request of the tus protocol, the caller checks the status and closes the response body
+func (client *Client) tusRequest(ctx context.Context, upload ResumableUpload, httpMethod, uri string, body []byte, headers map[string]string) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if upload.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, upload.Timeout)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, httpMethod, uri, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	request.Header.Set("Tus-Resumable", TUS_RESUMABLE_VERSION)
+	if len(strings.Trim(upload.TokenString, " ")) > 0 {
+		request.Header.Set("Authorization", "Bearer "+upload.TokenString)
+	}
+	for key, val := range headers {
+		request.Header.Set(key, val)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		cancel()
+		// a timeout of the request, not the caller's context, is a failure of the link worth retrying
+		return nil, &transportError{Err: err}
+	}
+
+	response.Body = &cancelOnClose{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+// cancelOnClose releases the timeout of a request once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (cancelOnClose *cancelOnClose) Close() error {
+	err := cancelOnClose.ReadCloser.Close()
+	cancelOnClose.cancel()
+	return err
+}
+
+func parseUploadOffset(response *http.Response, uri string) (int64, error) {
+	offset, err := strconv.ParseInt(response.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		err := fmt.Errorf("invalid upload offset:%s, url:%s", response.Header.Get("Upload-Offset"), uri)
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+// encodeUploadMetadata encodes the Upload-Metadata header, keys and base64 values separated by commas
+func encodeUploadMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, key := range keys {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func readResumableState(stateFilePath string) (*resumableState, error) {
+	stateJson, err := os.ReadFile(stateFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &resumableState{}
+	err = json.Unmarshal(stateJson, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := url.Parse(state.Location); err != nil || state.Location == "" {
+		err := fmt.Errorf("invalid upload location in:%s", stateFilePath)
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// writeResumableState writes the state to a temporary file renamed to stateFilePath, it is never half written
+func writeResumableState(stateFilePath string, state *resumableState) error {
+	stateJson, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tempFilePath := stateFilePath + ".tmp"
+	err = os.WriteFile(tempFilePath, stateJson, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempFilePath, stateFilePath)
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,256 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filswan/go-swan-lib/client/web/webtest"
+)
+
+const testChunkSize = 1024
+
+func newTestUploadClient(t *testing.T) *Client {
+	retryPolicy := DefaultRetryPolicy()
+	retryPolicy.InitialBackoff = time.Millisecond
+	retryPolicy.MaxBackoff = time.Millisecond
+
+	client, err := NewClient(ClientConfig{RetryPolicy: retryPolicy})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+// newTestUpload writes a file of 5 chunks and a half and returns its upload to server with its content
+func newTestUpload(t *testing.T, server *webtest.Server) (ResumableUpload, []byte) {
+	content := make([]byte, 5*testChunkSize+testChunkSize/2)
+	for i := range content {
+		content[i] = byte(i * 31)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(filePath, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := ResumableUpload{
+		CreationUrl: server.URL,
+		FilePath:    filePath,
+		ChunkSize:   testChunkSize,
+	}
+
+	return upload, content
+}
+
+func checkUploaded(t *testing.T, server *webtest.Server, location string, content []byte) {
+	upload := server.Upload(location)
+	if upload == nil {
+		t.Fatalf("no upload at:%s", location)
+	}
+	if upload.Offset != upload.Length || !bytes.Equal(upload.Data, content) {
+		t.Fatalf("upload at:%s has %d of %d bytes, content equal:%t", location, upload.Offset, upload.Length, bytes.Equal(upload.Data, content))
+	}
+}
+
+func TestUploadResumable(t *testing.T) {
+	server := webtest.NewServer()
+	defer server.Close()
+	upload, content := newTestUpload(t, server)
+	upload.Metadata = map[string]string{"task": "task"}
+
+	location, err := newTestUploadClient(t).UploadResumable(context.Background(), upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkUploaded(t, server, location, content)
+	if metadata := server.Upload(location).Metadata; metadata["filename"] != "file" || metadata["task"] != "task" {
+		t.Fatalf("metadata: %v", metadata)
+	}
+	if calls := server.Calls(http.MethodPatch); calls != 6 {
+		t.Fatalf("PATCH calls: got %d, want 6", calls)
+	}
+	if _, err := os.Stat(upload.FilePath + RESUMABLE_STATE_FILE_SUFFIX); !os.IsNotExist(err) {
+		t.Fatalf("state file of a complete upload: %v", err)
+	}
+}
+
+func TestUploadResumableRetriesFailedChunks(t *testing.T) {
+	faults := map[string]webtest.Fault{
+		"dropped":           {Drop: true, Times: 2},
+		"partial chunk":     {PartialBytes: testChunkSize / 3, Times: 2},
+		"checksum mismatch": {PartialBytes: -1, Times: 2},
+		"offset conflict":   {HttpStatus: http.StatusConflict, Times: 2},
+		"unavailable":       {HttpStatus: http.StatusServiceUnavailable, Times: 2},
+	}
+
+	for name, fault := range faults {
+		t.Run(name, func(t *testing.T) {
+			server := webtest.NewServer()
+			defer server.Close()
+			upload, content := newTestUpload(t, server)
+			server.InjectFault(http.MethodPatch, fault)
+
+			location, err := newTestUploadClient(t).UploadResumable(context.Background(), upload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkUploaded(t, server, location, content)
+			if uploads := server.Uploads(); uploads != 1 {
+				t.Fatalf("uploads: got %d, want 1", uploads)
+			}
+			// each failed chunk is sent again after asking the server its offset
+			if calls := server.Calls(http.MethodPatch); calls != 8 {
+				t.Fatalf("PATCH calls: got %d, want 8", calls)
+			}
+			if calls := server.Calls(http.MethodHead); calls != 2 {
+				t.Fatalf("HEAD calls: got %d, want 2", calls)
+			}
+		})
+	}
+}
+
+func TestUploadResumableGivesUpOnPersistentFailure(t *testing.T) {
+	server := webtest.NewServer()
+	defer server.Close()
+	upload, _ := newTestUpload(t, server)
+	server.InjectFault(http.MethodPatch, webtest.Fault{HttpStatus: http.StatusServiceUnavailable})
+
+	client := newTestUploadClient(t)
+	_, err := client.UploadResumable(context.Background(), upload)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want http status 503", err)
+	}
+	if calls := server.Calls(http.MethodPatch); calls != client.RetryPolicy().MaxAttempts {
+		t.Fatalf("PATCH calls: got %d, want %d", calls, client.RetryPolicy().MaxAttempts)
+	}
+
+	// the state is kept for the next attempt
+	if _, err := os.Stat(upload.FilePath + RESUMABLE_STATE_FILE_SUFFIX); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadResumableResumesFromStateFile(t *testing.T) {
+	server := webtest.NewServer()
+	defer server.Close()
+	upload, content := newTestUpload(t, server)
+
+	// the process stops after 2 chunks
+	ctx, cancel := context.WithCancel(context.Background())
+	upload.Progress = func(uploaded, total int64) {
+		if uploaded >= 2*testChunkSize {
+			cancel()
+		}
+	}
+
+	client := newTestUploadClient(t)
+	_, err := client.UploadResumable(ctx, upload)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	patchCalls := server.Calls(http.MethodPatch)
+
+	upload.Progress = nil
+	location, err := client.UploadResumable(context.Background(), upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkUploaded(t, server, location, content)
+	if uploads := server.Uploads(); uploads != 1 {
+		t.Fatalf("uploads: got %d, want 1", uploads)
+	}
+	if calls := server.Calls(http.MethodPatch) - patchCalls; calls != 4 {
+		t.Fatalf("PATCH calls after resuming: got %d, want 4", calls)
+	}
+}
+
+func TestUploadResumableRestartsExpiredUpload(t *testing.T) {
+	server := webtest.NewServer()
+	defer server.Close()
+	upload, content := newTestUpload(t, server)
+	server.InjectFault(http.MethodPatch, webtest.Fault{HttpStatus: http.StatusBadRequest, Times: 1})
+
+	client := newTestUploadClient(t)
+	_, err := client.UploadResumable(context.Background(), upload)
+	if err == nil {
+		t.Fatal("rejected chunk reported success")
+	}
+
+	state, err := readResumableState(upload.FilePath + RESUMABLE_STATE_FILE_SUFFIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.RemoveUpload(state.Location)
+
+	location, err := client.UploadResumable(context.Background(), upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location == state.Location {
+		t.Fatal("expired upload resumed")
+	}
+	checkUploaded(t, server, location, content)
+}
+
+func TestUploadResumableAuth(t *testing.T) {
+	server := webtest.NewServer()
+	defer server.Close()
+	server.Token = "token"
+	upload, content := newTestUpload(t, server)
+
+	upload.TokenString = "wrong"
+	_, err := newTestUploadClient(t).UploadResumable(context.Background(), upload)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("wrong token: got %v, want ErrUnauthorized", err)
+	}
+	if calls := server.Calls(http.MethodPost); calls != 1 {
+		t.Fatalf("POST calls: got %d, want 1", calls)
+	}
+
+	upload.TokenString = "token"
+	location, err := newTestUploadClient(t).UploadResumable(context.Background(), upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkUploaded(t, server, location, content)
+}
+
+func TestUploadResumableTooLarge(t *testing.T) {
+	server := webtest.NewServer()
+	defer server.Close()
+	upload, content := newTestUpload(t, server)
+	server.MaxSize = int64(len(content)) - 1
+
+	_, err := newTestUploadClient(t).UploadResumable(context.Background(), upload)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("got %v, want http status 413", err)
+	}
+	if uploads := server.Uploads(); uploads != 0 {
+		t.Fatalf("uploads: got %d, want 0", uploads)
+	}
+}
+
+func TestHttpUploadResumableUsesDefaultClient(t *testing.T) {
+	server := webtest.NewServer()
+	defer server.Close()
+	upload, content := newTestUpload(t, server)
+
+	location, err := HttpUploadResumable(upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkUploaded(t, server, location, content)
+}
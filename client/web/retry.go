@@ -0,0 +1,165 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	RETRY_MAX_ATTEMPTS_DEFAULT    = 3
+	RETRY_INITIAL_BACKOFF_DEFAULT = 500 * time.Millisecond
+	RETRY_MAX_BACKOFF_DEFAULT     = 10 * time.Second
+	RETRY_MULTIPLIER_DEFAULT      = 2
+	RETRY_JITTER_DEFAULT          = 0.2
+)
+
+type RetryPolicy struct {
+	MaxAttempts          int           // total attempts including the first one, 0 or 1 means no retry
+	InitialBackoff       time.Duration // wait before the first retry
+	MaxBackoff           time.Duration // upper bound of the wait between two attempts, 0 means no bound
+	Multiplier           float64       // growth of the wait after each retry, less than 1 means 1
+	Jitter               float64       // fraction of the wait randomized, between 0 and 1
+	RetryableStatusCodes []int         // http status codes worth retrying
+	RetryableRpcCodes    []int         // JSON-RPC error codes worth retrying
+}
+
+type idempotentKey struct{}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	retryPolicy := &RetryPolicy{
+		MaxAttempts:    RETRY_MAX_ATTEMPTS_DEFAULT,
+		InitialBackoff: RETRY_INITIAL_BACKOFF_DEFAULT,
+		MaxBackoff:     RETRY_MAX_BACKOFF_DEFAULT,
+		Multiplier:     RETRY_MULTIPLIER_DEFAULT,
+		Jitter:         RETRY_JITTER_DEFAULT,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+
+	return retryPolicy
+}
+
+// WithIdempotent marks the requests sent with ctx as safe to repeat,
+// requests other than GET, HEAD, OPTIONS, PUT and DELETE are not retried without it
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func IsIdempotent(ctx context.Context, httpMethod string) bool {
+	switch httpMethod {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// Backoff returns the wait before the given retry, retry starts from 1
+func (retryPolicy *RetryPolicy) Backoff(retry int) time.Duration {
+	if retryPolicy == nil || retry < 1 {
+		return 0
+	}
+
+	multiplier := retryPolicy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(retryPolicy.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		backoff = backoff * multiplier
+		if retryPolicy.MaxBackoff > 0 && backoff >= float64(retryPolicy.MaxBackoff) {
+			break
+		}
+	}
+
+	if retryPolicy.MaxBackoff > 0 && backoff > float64(retryPolicy.MaxBackoff) {
+		backoff = float64(retryPolicy.MaxBackoff)
+	}
+
+	if retryPolicy.Jitter > 0 {
+		jitter := retryPolicy.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		backoff = backoff * (1 - jitter + 2*jitter*rand.Float64())
+	}
+
+	return time.Duration(backoff)
+}
+
+func (retryPolicy *RetryPolicy) IsRetryableStatus(statusCode int) bool {
+	if retryPolicy == nil {
+		return false
+	}
+
+	for _, code := range retryPolicy.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (retryPolicy *RetryPolicy) IsRetryableRpcCode(rpcCode int) bool {
+	if retryPolicy == nil {
+		return false
+	}
+
+	for _, code := range retryPolicy.RetryableRpcCodes {
+		if code == rpcCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsRetryableError reports whether err returned by this package is a transport failure or a retryable http status,
+// errors caused by the cancellation of the caller's context are never retryable
+func (retryPolicy *RetryPolicy) IsRetryableError(err error) bool {
+	if retryPolicy == nil || err == nil {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return retryPolicy.IsRetryableStatus(statusErr.StatusCode)
+	}
+
+	var transportErr *transportError
+	return errors.As(err, &transportErr)
+}
+
+// Retry runs operation until it succeeds, it reports a non retryable error, the attempts are used up or ctx is done.
+// A nil policy runs operation once.
+func (retryPolicy *RetryPolicy) Retry(ctx context.Context, operation func(attempt int) (retryable bool, err error)) error {
+	maxAttempts := 1
+	if retryPolicy != nil && retryPolicy.MaxAttempts > 1 {
+		maxAttempts = retryPolicy.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		retryable, err := operation(attempt)
+		if err == nil || !retryable || attempt >= maxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(retryPolicy.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
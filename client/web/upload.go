@@ -0,0 +1,300 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	HTTP_CONTENT_TYPE_OCTET_STREAM = "application/octet-stream"
+
+	// field of the files of a multipart upload without FieldName
+	UPLOAD_FIELD_NAME_DEFAULT = "file"
+)
+
+// UploadFile is a file of a multipart upload, read from Reader or from FilePath when Reader is nil
+type UploadFile struct {
+	FieldName   string // UPLOAD_FIELD_NAME_DEFAULT when empty
+	FileName    string // the base of FilePath when empty
+	FilePath    string
+	Reader      io.Reader
+	ContentType string // HTTP_CONTENT_TYPE_OCTET_STREAM when empty
+}
+
+// UploadProgress is called as the files are sent with the bytes sent so far and their total, -1 when unknown
+type UploadProgress func(bytesSent, bytesTotal int64)
+
+type MultipartUpload struct {
+	Fields      map[string]string // text fields, sent before the files sorted by name
+	Files       []UploadFile
+	TokenString string            // sent as a bearer token when not empty
+	Headers     map[string]string // more headers like a custom authorization
+	Progress    UploadProgress    // nil means no progress reporting
+	Timeout     time.Duration     // of the whole upload, 0 means none, the timeout of the client does not apply
+}
+
+// uploadFile is an UploadFile ready to be sent
+type uploadFile struct {
+	header textproto.MIMEHeader
+	reader io.Reader
+	size   int64 // -1 when unknown
+	closer io.Closer
+}
+
+func HttpUploadMultipart(httpMethod, uri string, upload MultipartUpload) ([]byte, error) {
+	return HttpUploadMultipartWithContext(context.Background(), httpMethod, uri, upload)
+}
+
+func HttpUploadMultipartWithContext(ctx context.Context, httpMethod, uri string, upload MultipartUpload) ([]byte, error) {
+	return DefaultClient().UploadMultipart(ctx, httpMethod, uri, upload)
+}
+
+// UploadMultipart streams the fields and the files of upload as a multipart body, without holding the files
+// in memory, and returns the raw response body when the status is 200. The body has a Content-Length when
+// the sizes of all the files are known, files from FilePath or from a Reader with a Len or a Stat method.
+// Uploads are not retried, a Reader cannot be read twice.
+func (client *Client) UploadMultipart(ctx context.Context, httpMethod, uri string, upload MultipartUpload) ([]byte, error) {
+	if upload.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, upload.Timeout)
+		defer cancel()
+	}
+
+	files, err := openUploadFiles(upload.Files)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+	defer closeUploadFiles(files)
+
+	fieldNames := make([]string, 0, len(upload.Fields))
+	for fieldName := range upload.Fields {
+		fieldNames = append(fieldNames, fieldName)
+	}
+	sort.Strings(fieldNames)
+
+	pipeReader, pipeWriter := io.Pipe()
+	bodyWriter := multipart.NewWriter(pipeWriter)
+
+	contentLength, filesSize := multipartLength(bodyWriter.Boundary(), upload.Fields, fieldNames, files)
+
+	go func() {
+		err := writeMultipart(bodyWriter, upload, fieldNames, files, filesSize)
+		if err == nil {
+			err = bodyWriter.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	request, err := http.NewRequestWithContext(ctx, httpMethod, uri, pipeReader)
+	if err != nil {
+		pipeReader.Close()
+		logs.Log().Error(err)
+		return nil, err
+	}
+	request.ContentLength = contentLength
+	request.Header.Set("Content-Type", bodyWriter.FormDataContentType())
+	if len(strings.Trim(upload.TokenString, " ")) > 0 {
+		request.Header.Set("Authorization", "Bearer "+upload.TokenString)
+	}
+	for key, val := range upload.Headers {
+		request.Header.Set(key, val)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		pipeReader.Close()
+		logs.Log().Error(err)
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		err := newHTTPStatusError(response, uri)
+		logs.Log().Error(err)
+		switch response.StatusCode {
+		case http.StatusNotFound:
+			logs.Log().Error("please check your url:", uri)
+		case http.StatusUnauthorized:
+			logs.Log().Error("authentication failed, please check your token")
+		}
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return responseBody, nil
+}
+
+func openUploadFiles(uploadFiles []UploadFile) ([]*uploadFile, error) {
+	files := []*uploadFile{}
+	for _, upload := range uploadFiles {
+		file := &uploadFile{
+			reader: upload.Reader,
+			size:   -1,
+		}
+		files = append(files, file)
+
+		fileName := upload.FileName
+		if file.reader == nil {
+			osFile, err := os.Open(upload.FilePath)
+			if err != nil {
+				closeUploadFiles(files)
+				return nil, err
+			}
+			file.closer = osFile
+
+			fileInfo, err := osFile.Stat()
+			if err == nil && !fileInfo.Mode().IsRegular() {
+				err = fmt.Errorf("%s is not a regular file", upload.FilePath)
+			}
+			if err != nil {
+				closeUploadFiles(files)
+				return nil, err
+			}
+
+			file.reader = osFile
+			if fileName == "" {
+				fileName = filepath.Base(upload.FilePath)
+			}
+		}
+
+		switch reader := file.reader.(type) {
+		case interface{ Len() int }:
+			file.size = int64(reader.Len())
+		case interface{ Stat() (os.FileInfo, error) }:
+			fileInfo, err := reader.Stat()
+			if err == nil && fileInfo.Mode().IsRegular() {
+				file.size = fileInfo.Size()
+			}
+		}
+
+		fieldName := upload.FieldName
+		if fieldName == "" {
+			fieldName = UPLOAD_FIELD_NAME_DEFAULT
+		}
+		contentType := upload.ContentType
+		if contentType == "" {
+			contentType = HTTP_CONTENT_TYPE_OCTET_STREAM
+		}
+
+		file.header = textproto.MIMEHeader{}
+		file.header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(fieldName), escapeQuotes(fileName)))
+		file.header.Set("Content-Type", contentType)
+	}
+
+	return files, nil
+}
+
+func closeUploadFiles(files []*uploadFile) {
+	for _, file := range files {
+		if file.closer != nil {
+			file.closer.Close()
+		}
+	}
+}
+
+// multipartLength returns the length of the multipart body and the bytes of its files, -1 when a size is unknown
+func multipartLength(boundary string, fields map[string]string, fieldNames []string, files []*uploadFile) (int64, int64) {
+	filesSize := int64(0)
+	for _, file := range files {
+		if file.size < 0 {
+			return -1, -1
+		}
+		filesSize += file.size
+	}
+
+	counter := &countingWriter{}
+	bodyWriter := multipart.NewWriter(counter)
+	err := bodyWriter.SetBoundary(boundary)
+	if err != nil {
+		return -1, filesSize
+	}
+
+	for _, fieldName := range fieldNames {
+		bodyWriter.WriteField(fieldName, fields[fieldName])
+	}
+	for _, file := range files {
+		bodyWriter.CreatePart(file.header)
+	}
+	bodyWriter.Close()
+
+	return counter.written + filesSize, filesSize
+}
+
+func writeMultipart(bodyWriter *multipart.Writer, upload MultipartUpload, fieldNames []string, files []*uploadFile, filesSize int64) error {
+	for _, fieldName := range fieldNames {
+		err := bodyWriter.WriteField(fieldName, upload.Fields[fieldName])
+		if err != nil {
+			return err
+		}
+	}
+
+	bytesSent := int64(0)
+	for _, file := range files {
+		partWriter, err := bodyWriter.CreatePart(file.header)
+		if err != nil {
+			return err
+		}
+
+		if upload.Progress != nil {
+			partWriter = &progressWriter{writer: partWriter, onWrite: func(n int64) {
+				bytesSent += n
+				upload.Progress(bytesSent, filesSize)
+			}}
+		}
+
+		written, err := io.Copy(partWriter, file.reader)
+		if err != nil {
+			return err
+		}
+		if file.size >= 0 && written != file.size {
+			err := fmt.Errorf("%d bytes read instead of %d, the file changed while uploaded", written, file.size)
+			return err
+		}
+	}
+
+	return nil
+}
+
+type countingWriter struct {
+	written int64
+}
+
+func (countingWriter *countingWriter) Write(p []byte) (int, error) {
+	countingWriter.written += int64(len(p))
+	return len(p), nil
+}
+
+type progressWriter struct {
+	writer  io.Writer
+	onWrite func(n int64)
+}
+
+func (progressWriter *progressWriter) Write(p []byte) (int, error) {
+	n, err := progressWriter.writer.Write(p)
+	progressWriter.onWrite(int64(n))
+	return n, err
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes the names of a Content-Disposition like mime/multipart does
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,238 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// receivedUpload is what the test server read of a multipart upload
+type receivedUpload struct {
+	contentLength int64
+	bodyLength    int64
+	boundary      string
+	parts         []receivedPart
+}
+
+type receivedPart struct {
+	fieldName   string
+	fileName    string
+	contentType string
+	content     string
+}
+
+func newUploadServer(t *testing.T, received *receivedUpload) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received.contentLength = r.ContentLength
+		received.bodyLength = int64(len(body))
+
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received.boundary = params["boundary"]
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		multipartReader, err := r.MultipartReader()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received.parts = nil
+		for {
+			part, err := multipartReader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			content, err := io.ReadAll(part)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			received.parts = append(received.parts, receivedPart{
+				fieldName:   part.FormName(),
+				fileName:    part.FileName(),
+				contentType: part.Header.Get("Content-Type"),
+				content:     string(content),
+			})
+		}
+
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestUploadMultipart(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "a.txt")
+	err := os.WriteFile(filePath, []byte("content of a"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := &receivedUpload{}
+	server := newUploadServer(t, received)
+
+	progress := [][2]int64{}
+	upload := MultipartUpload{
+		Fields: map[string]string{"name": "value", "another": `quoted "value"`},
+		Files: []UploadFile{
+			{FilePath: filePath},
+			{FieldName: "car", FileName: `b "1".car`, Reader: strings.NewReader("content of b"), ContentType: "application/vnd.ipld.car"},
+		},
+		Progress: func(bytesSent, bytesTotal int64) {
+			progress = append(progress, [2]int64{bytesSent, bytesTotal})
+		},
+	}
+
+	responseBody, err := DefaultClient().UploadMultipart(context.Background(), http.MethodPost, server.URL, upload)
+	if err != nil || string(responseBody) != "ok" {
+		t.Fatalf("got %q, %v", responseBody, err)
+	}
+
+	want := []receivedPart{
+		{fieldName: "another", content: `quoted "value"`},
+		{fieldName: "name", content: "value"},
+		{fieldName: UPLOAD_FIELD_NAME_DEFAULT, fileName: "a.txt", contentType: HTTP_CONTENT_TYPE_OCTET_STREAM, content: "content of a"},
+		{fieldName: "car", fileName: `b "1".car`, contentType: "application/vnd.ipld.car", content: "content of b"},
+	}
+	if len(received.parts) != len(want) {
+		t.Fatalf("parts:\n got %+v\nwant %+v", received.parts, want)
+	}
+	for i := range want {
+		if received.parts[i] != want[i] {
+			t.Fatalf("part %d: got %+v, want %+v", i, received.parts[i], want[i])
+		}
+	}
+
+	// the sizes of both files are known, the body is sent with its exact length
+	if received.contentLength != received.bodyLength {
+		t.Fatalf("Content-Length %d, body of %d bytes", received.contentLength, received.bodyLength)
+	}
+
+	if len(progress) == 0 || progress[len(progress)-1] != [2]int64{24, 24} {
+		t.Fatalf("progress: %v, want to end with 24 of 24 bytes", progress)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i][0] < progress[i-1][0] {
+			t.Fatalf("progress going back: %v", progress)
+		}
+	}
+
+	boundary := received.boundary
+	_, err = DefaultClient().UploadMultipart(context.Background(), http.MethodPost, server.URL, MultipartUpload{Files: []UploadFile{{FilePath: filePath}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if boundary == "" || received.boundary == boundary {
+		t.Fatalf("boundary %q reused, want a random one per upload", boundary)
+	}
+}
+
+// a reader without Len nor Stat is sent chunked, its total is reported as unknown
+func TestUploadMultipartUnknownSize(t *testing.T) {
+	received := &receivedUpload{}
+	server := newUploadServer(t, received)
+
+	totals := map[int64]bool{}
+	upload := MultipartUpload{
+		Files:    []UploadFile{{FileName: "stream", Reader: io.MultiReader(strings.NewReader("stream"))}},
+		Progress: func(bytesSent, bytesTotal int64) { totals[bytesTotal] = true },
+	}
+
+	_, err := DefaultClient().UploadMultipart(context.Background(), http.MethodPost, server.URL, upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.contentLength != -1 {
+		t.Fatalf("Content-Length %d, want none", received.contentLength)
+	}
+	if len(received.parts) != 1 || received.parts[0].content != "stream" {
+		t.Fatalf("parts: %+v", received.parts)
+	}
+	if len(totals) != 1 || !totals[-1] {
+		t.Fatalf("totals: %v, want -1", totals)
+	}
+}
+
+func TestMultipartLength(t *testing.T) {
+	upload := MultipartUpload{
+		Fields: map[string]string{"a": "1", "é": "\"2\""},
+		Files: []UploadFile{
+			{FileName: "x", Reader: bytes.NewReader(make([]byte, 1000))},
+			{FieldName: "y", FileName: `y\"`, Reader: strings.NewReader("y"), ContentType: "text/plain"},
+		},
+	}
+
+	files, err := openUploadFiles(upload.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldNames := []string{"a", "é"}
+
+	body := &bytes.Buffer{}
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		bodyWriter := multipart.NewWriter(pipeWriter)
+		err := writeMultipart(bodyWriter, upload, fieldNames, files, -1)
+		if err == nil {
+			err = bodyWriter.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+	_, err = io.Copy(body, pipeReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boundary := strings.TrimPrefix(strings.SplitN(body.String(), "\r\n", 2)[0], "--")
+	contentLength, filesSize := multipartLength(boundary, upload.Fields, fieldNames, files)
+	if contentLength != int64(body.Len()) || filesSize != 1001 {
+		t.Fatalf("got %d and %d, want %d and 1001", contentLength, filesSize, body.Len())
+	}
+}
+
+func TestUploadMultipartDoesNotLogToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	logsBuf := captureLogs(t)
+
+	upload := MultipartUpload{
+		Files:       []UploadFile{{FileName: "x", Reader: strings.NewReader("x")}},
+		TokenString: "secret-token",
+	}
+	_, err := DefaultClient().UploadMultipart(context.Background(), http.MethodPost, server.URL, upload)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("got %v, want ErrUnauthorized", err)
+	}
+	if strings.Contains(logsBuf.String(), "secret-token") {
+		t.Fatalf("token logged:\n%s", logsBuf.String())
+	}
+	if !strings.Contains(logsBuf.String(), "authentication failed") {
+		t.Fatalf("authentication failure not logged:\n%s", logsBuf.String())
+	}
+}
@@ -0,0 +1,386 @@
+// Package webtest provides an in-process fake resumable upload server speaking the tus 1.0 protocol, with its creation
+// and checksum extensions, in-memory uploads, bearer authentication and fault injection, for hermetic tests of the
+// resumable uploads of the web package.
+package webtest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/filswan/go-swan-lib/internal/faketest"
+)
+
+const (
+	TUS_RESUMABLE_VERSION  = "1.0.0"
+	TUS_EXTENSIONS         = "creation,checksum"
+	TUS_CHECKSUM_ALGORITHM = "sha256,sha1,md5"
+
+	HTTP_CONTENT_TYPE_OFFSET_OCTET_STREAM = "application/offset+octet-stream"
+	HTTP_STATUS_CHECKSUM_MISMATCH         = 460
+
+	// path creating the uploads, the uploads are below it
+	PATH_FILES = "/files/"
+)
+
+// Fault is injected in the answers to the requests of a method, besides Delay, HttpStatus and Drop
+// it applies PartialBytes to the PATCH requests
+type Fault = faketest.Fault
+
+// Upload is an upload the server received, complete when Offset reaches Length
+type Upload struct {
+	Id       string
+	Length   int64
+	Offset   int64
+	Metadata map[string]string
+	Data     []byte
+}
+
+// Server is a fake resumable upload server, it is safe for concurrent use
+type Server struct {
+	URL string // creation url to configure ResumableUpload with
+
+	// Token is the bearer token required by every request, empty means no authentication
+	Token string
+	// MaxSize is the largest upload accepted, 0 means no limit
+	MaxSize int64
+
+	faketest.Faults
+
+	httpServer *httptest.Server
+	mutex      sync.Mutex
+	uploads    map[string]*Upload
+}
+
+// NewServer starts a fake resumable upload server without any upload
+func NewServer() *Server {
+	server := &Server{
+		uploads: map[string]*Upload{},
+	}
+
+	server.httpServer = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	server.URL = server.httpServer.URL + PATH_FILES
+
+	return server
+}
+
+func (server *Server) Close() {
+	server.httpServer.Close()
+}
+
+// Upload returns a copy of the upload at location, the url answered to its creation, nil when there is none
+func (server *Server) Upload(location string) *Upload {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	upload := server.uploads[uploadId(location)]
+	if upload == nil {
+		return nil
+	}
+
+	uploadCopy := *upload
+	uploadCopy.Data = append([]byte{}, upload.Data...)
+	uploadCopy.Metadata = map[string]string{}
+	for key, val := range upload.Metadata {
+		uploadCopy.Metadata[key] = val
+	}
+
+	return &uploadCopy
+}
+
+// Uploads returns how many uploads were created
+func (server *Server) Uploads() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return len(server.uploads)
+}
+
+// RemoveUpload forgets the upload at location, like an expired one
+func (server *Server) RemoveUpload(location string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	delete(server.uploads, uploadId(location))
+}
+
+// InjectFault makes the requests of method fail, an empty method affects all of them
+func (server *Server) InjectFault(method string, fault Fault) {
+	server.SetFault(method, fault)
+}
+
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", TUS_RESUMABLE_VERSION)
+
+	if !strings.HasPrefix(r.URL.Path, PATH_FILES) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, PATH_FILES)
+
+	fault := server.TakeFault(r.Method)
+	if fault.Interrupt(w) {
+		return
+	}
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", TUS_RESUMABLE_VERSION)
+		w.Header().Set("Tus-Extension", TUS_EXTENSIONS)
+		w.Header().Set("Tus-Checksum-Algorithm", TUS_CHECKSUM_ALGORITHM)
+		if server.MaxSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(server.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if server.Token != "" && r.Header.Get("Authorization") != "Bearer "+server.Token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != TUS_RESUMABLE_VERSION {
+		w.Header().Set("Tus-Version", TUS_RESUMABLE_VERSION)
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		server.create(w, r)
+	case id != "" && r.Method == http.MethodHead:
+		server.head(w, id)
+	case id != "" && r.Method == http.MethodPatch:
+		server.patch(w, r, id, fault)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (server *Server) create(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if server.MaxSize > 0 && length > server.MaxSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := decodeMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	upload := &Upload{
+		Id:       newId(),
+		Length:   length,
+		Metadata: metadata,
+		Data:     []byte{},
+	}
+
+	server.mutex.Lock()
+	server.uploads[upload.Id] = upload
+	server.mutex.Unlock()
+
+	w.Header().Set("Location", PATH_FILES+upload.Id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (server *Server) head(w http.ResponseWriter, id string) {
+	server.mutex.Lock()
+	upload := server.uploads[id]
+	var offset, length int64
+	if upload != nil {
+		offset, length = upload.Offset, upload.Length
+	}
+	server.mutex.Unlock()
+
+	if upload == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// patch appends the chunk at the offset of the upload once its checksum, when sent, matches,
+// the bytes received before a lost connection are kept like tus servers do
+func (server *Server) patch(w http.ResponseWriter, r *http.Request, id string, fault *Fault) {
+	if r.Header.Get("Content-Type") != HTTP_CONTENT_TYPE_OFFSET_OCTET_STREAM {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+
+	checksum, err := newChecksum(r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	upload := server.uploads[id]
+	var currentOffset, length int64
+	if upload != nil {
+		currentOffset, length = upload.Offset, upload.Length
+	}
+	server.mutex.Unlock()
+
+	if upload == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if offset != currentOffset {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	reader := io.LimitReader(r.Body, length-offset+1)
+	if fault != nil && fault.PartialBytes > 0 {
+		reader = io.LimitReader(r.Body, fault.PartialBytes)
+	}
+
+	chunk, err := io.ReadAll(reader)
+	if err != nil {
+		// keep what was received, it cannot be checked without the rest of the chunk
+		if checksum == nil {
+			server.append(id, offset, chunk)
+		}
+		return
+	}
+	if offset+int64(len(chunk)) > length {
+		http.Error(w, "chunk beyond Upload-Length", http.StatusBadRequest)
+		return
+	}
+
+	if fault != nil && fault.PartialBytes > 0 {
+		if checksum == nil {
+			server.append(id, offset, chunk)
+		}
+		faketest.DropConnection(w)
+		return
+	}
+	if fault != nil && fault.PartialBytes < 0 && len(chunk) > 0 {
+		chunk[0] ^= 0xff
+	}
+
+	if checksum != nil && !checksum.matches(chunk) {
+		w.WriteHeader(HTTP_STATUS_CHECKSUM_MISMATCH)
+		return
+	}
+
+	newOffset, ok := server.append(id, offset, chunk)
+	if !ok {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// append adds the bytes of a chunk at offset unless another request moved the upload meanwhile
+func (server *Server) append(id string, offset int64, chunk []byte) (int64, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	upload := server.uploads[id]
+	if upload == nil || upload.Offset != offset {
+		return 0, false
+	}
+
+	upload.Data = append(upload.Data, chunk...)
+	upload.Offset += int64(len(chunk))
+	return upload.Offset, true
+}
+
+type checksum struct {
+	hash     hash.Hash
+	expected []byte
+}
+
+// newChecksum parses an Upload-Checksum header, nil when it is empty
+func newChecksum(header string) (*checksum, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	algorithm, encoded, found := strings.Cut(header, " ")
+	if !found {
+		return nil, fmt.Errorf("invalid Upload-Checksum:%s", header)
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Upload-Checksum:%s", header)
+	}
+
+	checksum := &checksum{expected: expected}
+	switch algorithm {
+	case "sha256":
+		checksum.hash = sha256.New()
+	case "sha1":
+		checksum.hash = sha1.New()
+	case "md5":
+		checksum.hash = md5.New()
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm:%s", algorithm)
+	}
+
+	return checksum, nil
+}
+
+func (checksum *checksum) matches(chunk []byte) bool {
+	checksum.hash.Write(chunk)
+	return string(checksum.hash.Sum(nil)) == string(checksum.expected)
+}
+
+// decodeMetadata decodes an Upload-Metadata header, keys and base64 values separated by commas
+func decodeMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		val, err := base64.StdEncoding.DecodeString(encoded)
+		if key == "" || err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata:%s", header)
+		}
+		metadata[key] = string(val)
+	}
+
+	return metadata, nil
+}
+
+// uploadId returns the id of the upload at location, an absolute url or a path
+func uploadId(location string) string {
+	_, id, _ := strings.Cut(location, PATH_FILES)
+	return id
+}
+
+func newId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,257 @@
+// Package commp calculates piece commitments (CommP) locally, like Filecoin.ClientCalcCommP but without a lotus node
+package commp
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/filswan/go-swan-lib/logs"
+)
+
+const (
+	NODE_SIZE = 32
+
+	// smallest padded piece, 4 nodes
+	PIECE_SIZE_MIN = FR32_PADDED_CHUNK
+
+	// multicodecs of a piece cid
+	CID_VERSION                        = 1
+	CODEC_FIL_COMMITMENT_UNSEALED      = 0xf101
+	MULTIHASH_SHA2_256_TRUNC254_PADDED = 0x1012
+	MULTIBASE_BASE32                   = "b"
+)
+
+var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CommP is the piece commitment of a payload
+type CommP struct {
+	PieceCid    string // CID v1 of the commitment, baga...
+	PieceSize   uint64 // padded piece size, a power of 2
+	PayloadSize uint64 // bytes of data the commitment was calculated from
+}
+
+// UnpaddedPieceSize is the piece size lotus reports, the padded size less the fr32 padding
+func (commP *CommP) UnpaddedPieceSize() uint64 {
+	return commP.PieceSize / FR32_PADDED_CHUNK * FR32_UNPADDED_CHUNK
+}
+
+// Calculator is an io.Writer calculating the commitment of what is written to it, it is not safe for concurrent use
+type Calculator struct {
+	chunk       [FR32_UNPADDED_CHUNK]byte
+	chunkLen    int
+	payloadSize uint64
+	// layers[i] is the pending left node of level i of the merkle tree, nil when there is none
+	layers []*[NODE_SIZE]byte
+}
+
+func NewCalculator() *Calculator {
+	return &Calculator{}
+}
+
+func (calculator *Calculator) Write(p []byte) (int, error) {
+	written := len(p)
+
+	for len(p) > 0 {
+		n := copy(calculator.chunk[calculator.chunkLen:], p)
+		calculator.chunkLen += n
+		p = p[n:]
+
+		if calculator.chunkLen == FR32_UNPADDED_CHUNK {
+			calculator.layers = addChunk(calculator.layers, &calculator.chunk)
+			calculator.chunkLen = 0
+		}
+	}
+
+	calculator.payloadSize += uint64(written)
+	return written, nil
+}
+
+// Sum returns the commitment of what has been written so far, zero padded to the piece size.
+// It does not change the state of the calculator, more data may still be written.
+func (calculator *Calculator) Sum() (*CommP, error) {
+	if calculator.payloadSize == 0 {
+		err := fmt.Errorf("no data to calculate the piece commitment of")
+		return nil, err
+	}
+
+	layers := make([]*[NODE_SIZE]byte, len(calculator.layers))
+	copy(layers, calculator.layers)
+
+	if calculator.chunkLen > 0 {
+		chunk := calculator.chunk
+		for i := calculator.chunkLen; i < FR32_UNPADDED_CHUNK; i++ {
+			chunk[i] = 0
+		}
+		layers = addChunk(layers, &chunk)
+	}
+
+	pieceSize := PaddedPieceSize(calculator.payloadSize)
+	depth := log2(pieceSize / NODE_SIZE)
+
+	root := rootOf(layers, depth)
+	commP := &CommP{
+		PieceCid:    PieceCid(root),
+		PieceSize:   pieceSize,
+		PayloadSize: calculator.payloadSize,
+	}
+
+	return commP, nil
+}
+
+func (calculator *Calculator) Reset() {
+	calculator.chunkLen = 0
+	calculator.payloadSize = 0
+	calculator.layers = nil
+}
+
+// Calc reads reader to its end and returns the commitment of its content
+func Calc(reader io.Reader) (*CommP, error) {
+	calculator := NewCalculator()
+
+	_, err := io.Copy(calculator, reader)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	commP, err := calculator.Sum()
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+
+	return commP, nil
+}
+
+func CalcFile(filePath string) (*CommP, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logs.Log().Error(err)
+		return nil, err
+	}
+	defer file.Close()
+
+	return Calc(file)
+}
+
+// PaddedPieceSize returns the size of the piece holding payloadSize bytes: the smallest power of 2,
+// and at least PIECE_SIZE_MIN, whose unpadded size is enough. Beyond 64 bytes it is the second value
+// returned by utils.CalculatePieceSize(payloadSize, false).
+func PaddedPieceSize(payloadSize uint64) uint64 {
+	pieceSize := uint64(PIECE_SIZE_MIN)
+	for pieceSize/FR32_PADDED_CHUNK*FR32_UNPADDED_CHUNK < payloadSize {
+		pieceSize = pieceSize * 2
+	}
+
+	return pieceSize
+}
+
+// PieceCid encodes a commitment as a CID v1 in base32, like lotus
+func PieceCid(commitment [NODE_SIZE]byte) string {
+	cid := []byte{}
+	cid = binary.AppendUvarint(cid, CID_VERSION)
+	cid = binary.AppendUvarint(cid, CODEC_FIL_COMMITMENT_UNSEALED)
+	cid = binary.AppendUvarint(cid, MULTIHASH_SHA2_256_TRUNC254_PADDED)
+	cid = binary.AppendUvarint(cid, NODE_SIZE)
+	cid = append(cid, commitment[:]...)
+
+	return MULTIBASE_BASE32 + strings.ToLower(base32Encoding.EncodeToString(cid))
+}
+
+// addChunk pads chunk and adds its 4 nodes to the merkle tree
+func addChunk(layers []*[NODE_SIZE]byte, chunk *[FR32_UNPADDED_CHUNK]byte) []*[NODE_SIZE]byte {
+	padded := [FR32_PADDED_CHUNK]byte{}
+	fr32Pad(chunk, &padded)
+
+	for i := 0; i < FR32_PADDED_CHUNK; i += NODE_SIZE {
+		node := [NODE_SIZE]byte{}
+		copy(node[:], padded[i:i+NODE_SIZE])
+		layers = addNode(layers, 0, &node)
+	}
+
+	return layers
+}
+
+// addNode adds node at level, combining it with the pending left nodes it completes
+func addNode(layers []*[NODE_SIZE]byte, level int, node *[NODE_SIZE]byte) []*[NODE_SIZE]byte {
+	for {
+		for len(layers) <= level {
+			layers = append(layers, nil)
+		}
+
+		left := layers[level]
+		if left == nil {
+			layers[level] = node
+			return layers
+		}
+
+		parent := hashNodes(left, node)
+		node = &parent
+		layers[level] = nil
+		level++
+	}
+}
+
+// rootOf completes the pending left nodes with zero subtrees up to the root at depth
+func rootOf(layers []*[NODE_SIZE]byte, depth int) [NODE_SIZE]byte {
+	for level := 0; level < depth; level++ {
+		if level >= len(layers) || layers[level] == nil {
+			continue
+		}
+
+		zero := zeroNode(level)
+		parent := hashNodes(layers[level], &zero)
+		layers[level] = nil
+		layers = addNode(layers, level+1, &parent)
+	}
+
+	if depth < len(layers) && layers[depth] != nil {
+		return *layers[depth]
+	}
+
+	return zeroNode(depth)
+}
+
+// hashNodes is sha256-trunc254-padded, the two most significant bits of the last byte of the hash are cleared
+func hashNodes(left, right *[NODE_SIZE]byte) [NODE_SIZE]byte {
+	hash := sha256.New()
+	hash.Write(left[:])
+	hash.Write(right[:])
+
+	node := [NODE_SIZE]byte{}
+	hash.Sum(node[:0])
+	node[NODE_SIZE-1] &= 0x3f
+
+	return node
+}
+
+// zeroNodes[i] is the root of a subtree of height i whose leaves are all zero
+var zeroNodes = newZeroNodes()
+
+func newZeroNodes() [][NODE_SIZE]byte {
+	zeroNodes := make([][NODE_SIZE]byte, 64)
+	for i := 1; i < len(zeroNodes); i++ {
+		zeroNodes[i] = hashNodes(&zeroNodes[i-1], &zeroNodes[i-1])
+	}
+
+	return zeroNodes
+}
+
+func zeroNode(level int) [NODE_SIZE]byte {
+	return zeroNodes[level]
+}
+
+func log2(n uint64) int {
+	exp := 0
+	for n > 1 {
+		n = n >> 1
+		exp++
+	}
+
+	return exp
+}
@@ -0,0 +1,107 @@
+package commp
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	commphashhash "github.com/filecoin-project/go-fil-commp-hashhash"
+	"github.com/filswan/go-swan-lib/utils"
+)
+
+// testPayload returns size pseudo-random bytes, the same ones for the same size
+func testPayload(size int) []byte {
+	payload := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(payload)
+	return payload
+}
+
+// hashhashCommP calculates the commitment of payload with go-fil-commp-hashhash
+func hashhashCommP(t *testing.T, payload []byte) *CommP {
+	calc := &commphashhash.Calc{}
+	_, err := calc.Write(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawCommP, pieceSize, err := calc.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitment := [NODE_SIZE]byte{}
+	copy(commitment[:], rawCommP)
+	return &CommP{PieceCid: PieceCid(commitment), PieceSize: pieceSize, PayloadSize: uint64(len(payload))}
+}
+
+func checkCommP(t *testing.T, payload []byte, want *CommP) {
+	commP, err := Calc(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *commP != *want {
+		t.Fatalf("commp of %d bytes: got %+v, want %+v", len(payload), commP, want)
+	}
+}
+
+func TestCalcKnownAnswers(t *testing.T) {
+	// the commitments of go-fil-commp-hashhash for testPayload
+	vectors := map[string]CommP{
+		"one chunk":                {PayloadSize: 127, PieceSize: 128, PieceCid: "baga6ea4seaqgasotnez33n3szikaasdlhchhmibb5loz6npnenmnh7t5wbchqay"},
+		"partial final chunk":      {PayloadSize: 200, PieceSize: 256, PieceCid: "baga6ea4seaqgphqxqiybhyf2byyu44ce3wow7zhvbmqv3ne6kyqnnpppomdwsoq"},
+		"non power of two":         {PayloadSize: 10000, PieceSize: 16384, PieceCid: "baga6ea4seaqc6oyloaqmhphowq6rusf2ra5ovtcyn24sc2iosayyh77affs32by"},
+		"multi-level zero padding": {PayloadSize: 127*16 + 1, PieceSize: 4096, PieceCid: "baga6ea4seaqalli5h5c2yd5w6sxlmbcnthkrixbbbepmdp7xpyjbxhw7s23gipq"},
+	}
+
+	for name, vector := range vectors {
+		t.Run(name, func(t *testing.T) {
+			checkCommP(t, testPayload(int(vector.PayloadSize)), &vector)
+		})
+	}
+}
+
+func TestCalcMatchesCommpHashhash(t *testing.T) {
+	sizes := []int{65, 126, 127, 128, 254, 255, 1000, 127 * 64, 127*64 + 1, 100000, 1<<20 + 1}
+	for _, size := range sizes {
+		payload := testPayload(size)
+		checkCommP(t, payload, hashhashCommP(t, payload))
+	}
+}
+
+func TestPaddedPieceSizeMatchesCalculatePieceSize(t *testing.T) {
+	sizes := []uint64{1 << 30, 1<<30 + 1, 32 << 30, 32<<30/128*127 + 1}
+	for size := uint64(65); size <= 100000; size++ {
+		sizes = append(sizes, size)
+	}
+
+	for _, size := range sizes {
+		_, pieceSize := utils.CalculatePieceSize(int64(size), false)
+		if PaddedPieceSize(size) != uint64(pieceSize) {
+			t.Fatalf("piece size of %d bytes: got %d, utils.CalculatePieceSize has %v", size, PaddedPieceSize(size), pieceSize)
+		}
+	}
+}
+
+func TestSumMidStream(t *testing.T) {
+	payload := testPayload(5000)
+	calculator := NewCalculator()
+
+	for _, size := range []int{100, 127, 1000, 3001, 5000} {
+		_, err := calculator.Write(payload[calculator.payloadSize:size])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		commP, err := calculator.Sum()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := Calc(bytes.NewReader(payload[:size]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *commP != *want {
+			t.Fatalf("sum after %d bytes: got %+v, want %+v", size, commP, want)
+		}
+	}
+}
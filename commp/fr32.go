@@ -0,0 +1,42 @@
+package commp
+
+const (
+	FR32_UNPADDED_CHUNK = 127 // bytes of data in a fr32 chunk
+	FR32_PADDED_CHUNK   = 128 // bytes of a fr32 chunk once padded
+)
+
+// fr32Pad spreads the 127 bytes of in over the 4 nodes of out, 254 bits per 32 bytes node,
+// so that every node is a valid element of the bls12-381 scalar field
+func fr32Pad(in *[FR32_UNPADDED_CHUNK]byte, out *[FR32_PADDED_CHUNK]byte) {
+	copy(out[:31], in[:31])
+
+	t := in[31] >> 6
+	out[31] = in[31] & 0x3f
+
+	var v byte
+	for i := 32; i < 64; i++ {
+		v = in[i]
+		out[i] = (v << 2) | t
+		t = v >> 6
+	}
+
+	t = v >> 4
+	out[63] &= 0x3f
+
+	for i := 64; i < 96; i++ {
+		v = in[i]
+		out[i] = (v << 4) | t
+		t = v >> 4
+	}
+
+	t = v >> 2
+	out[95] &= 0x3f
+
+	for i := 96; i < 127; i++ {
+		v = in[i]
+		out[i] = (v << 6) | t
+		t = v >> 2
+	}
+
+	out[127] = t & 0x3f
+}
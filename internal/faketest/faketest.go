@@ -0,0 +1,139 @@
+// Package faketest provides what the fake servers of the test packages share:
+// fault injection, call counting and the JSON-RPC messages.
+package faketest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RpcError is the JSON-RPC error of an answer
+type RpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (rpcError *RpcError) Error() string {
+	return fmt.Sprintf("code:%d, message:%s", rpcError.Code, rpcError.Message)
+}
+
+// Request is a JSON-RPC request
+type Request struct {
+	JsonRpc string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	Id      interface{}       `json:"id"`
+}
+
+// Fault is injected in the answers to the calls of a method or an endpoint,
+// each fake server documents the fields it applies besides Delay, HttpStatus and Drop
+type Fault struct {
+	Delay      time.Duration // wait before answering
+	HttpStatus int           // answer with this http status without handling the call
+	Drop       bool          // close the connection without answering
+	RpcError   *RpcError     // answer with this JSON-RPC error
+	Message    string        // answer with status fail and this message
+	// store this many bytes of the chunk of a PATCH then close the connection, like a link lost in the middle
+	// of a chunk, a negative value corrupts the chunk instead so that its checksum does not match
+	PartialBytes int64
+	Times        int // number of calls affected, 0 means all
+}
+
+// Interrupt waits for the delay of fault then drops the connection or answers with the http status of fault,
+// it reports whether the call was answered. A nil fault does nothing.
+func (fault *Fault) Interrupt(w http.ResponseWriter) bool {
+	if fault == nil {
+		return false
+	}
+
+	if fault.Delay > 0 {
+		time.Sleep(fault.Delay)
+	}
+	if fault.Drop {
+		DropConnection(w)
+		return true
+	}
+	if fault.HttpStatus != 0 {
+		w.WriteHeader(fault.HttpStatus)
+		return true
+	}
+
+	return false
+}
+
+// Faults counts the calls of a fake server by key, a method or a path, and holds the faults injected in them.
+// The zero value is ready to use and it is safe for concurrent use.
+type Faults struct {
+	mutex  sync.Mutex
+	faults map[string]*Fault
+	calls  map[string]int
+}
+
+// SetFault makes the calls of key fail, an empty key affects all the calls
+func (faults *Faults) SetFault(key string, fault Fault) {
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+
+	if faults.faults == nil {
+		faults.faults = map[string]*Fault{}
+	}
+	faults.faults[key] = &fault
+}
+
+func (faults *Faults) ClearFaults() {
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+	faults.faults = nil
+}
+
+// Calls returns how many times key was called, including the calls failed by a fault
+func (faults *Faults) Calls(key string) int {
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+	return faults.calls[key]
+}
+
+// TakeFault counts the call of key and returns the fault to apply to it, nil when there is none
+func (faults *Faults) TakeFault(key string) *Fault {
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+
+	if faults.calls == nil {
+		faults.calls = map[string]int{}
+	}
+	faults.calls[key]++
+
+	for _, faultKey := range []string{key, ""} {
+		fault := faults.faults[faultKey]
+		if fault == nil {
+			continue
+		}
+
+		faultCopy := *fault
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				delete(faults.faults, faultKey)
+			}
+		}
+		return &faultCopy
+	}
+
+	return nil
+}
+
+// DropConnection closes the connection of w without answering
+func DropConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic("faketest: connection cannot be dropped")
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
@@ -2,49 +2,289 @@ package logs
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rifflock/lfshook"
 	"github.com/sirupsen/logrus"
 )
 
-var logger *logrus.Logger
-
-func initLogger() {
-	logger = logrus.New()
-
-	formatter := &logrus.TextFormatter{
-		TimestampFormat: "2006-01-02 15:04:05.000",
-		FullTimestamp:   true,
-		CallerPrettyfier: func(f *runtime.Frame) (string, string) {
-			filename := filepath.Base(f.File)
-			funcRelativePathIndex := strings.LastIndex(f.Function, ".") + 1
-			funcName := f.Function[funcRelativePathIndex:]
-			return funcName, fmt.Sprintf("%s:%d", filename, f.Line)
-		},
-	}
-
-	logger.SetReportCaller(true)
-	logger.SetFormatter(formatter)
-	pathMap := lfshook.PathMap{
-		logrus.InfoLevel:  "./logs/info.log",
-		logrus.WarnLevel:  "./logs/warn.log",
-		logrus.ErrorLevel: "./logs/error.log",
-		logrus.FatalLevel: "./logs/error.log",
-		logrus.PanicLevel: "./logs/error.log",
-	}
-	logger.Hooks.Add(lfshook.NewHook(
-		pathMap,
+const (
+	LOG_DIR_DEFAULT   = "./logs"
+	LOG_LEVEL_DEFAULT = "info"
+	LOG_FORMAT_TEXT   = "text"
+	LOG_FORMAT_JSON   = "json"
+
+	// field names used by the clients of this library
+	FIELD_DEAL_CID  = "deal_cid"
+	FIELD_DEAL_ID   = "deal_id"
+	FIELD_TASK_UUID = "task_uuid"
+	FIELD_MINER_FID = "miner_fid"
+	FIELD_MSG_CID   = "msg_cid"
+)
+
+// Logger is what the library logs with, *logrus.Logger and *logrus.Entry satisfy it
+type Logger interface {
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type Config struct {
+	Level          string        // debug, info, warn or error, empty means LOG_LEVEL_DEFAULT
+	Format         string        // LOG_FORMAT_TEXT or LOG_FORMAT_JSON, empty means text
+	Output         io.Writer     // nil means os.Stderr
+	LogDir         string        // directory of info.log, warn.log and error.log, empty means LOG_DIR_DEFAULT
+	NoLogFiles     bool          // only write to Output
+	MaxSizeMB      int           // rotate a log file when it would grow beyond this, 0 means no size limit
+	RotateInterval time.Duration // rotate a log file written for this long, 0 means no time based rotation
+	MaxBackups     int           // rotated files kept per log file, 0 means all
+	MaxAge         time.Duration // rotated files older than this are removed, 0 means never
+}
+
+var (
+	logger        Logger
+	defaultLogger *logrus.Logger
+	loggerMutex   sync.RWMutex
+)
+
+// NewLogger creates the logrus logger used by default, with Config{} it writes to stderr and to ./logs
+func NewLogger(config Config) (*logrus.Logger, error) {
+	level := config.Level
+	if level == "" {
+		level = LOG_LEVEL_DEFAULT
+	}
+
+	logLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	newLogger := logrus.New()
+	newLogger.SetLevel(logLevel)
+	if config.Output != nil {
+		newLogger.SetOutput(config.Output)
+	}
+
+	formatter, err := newFormatter(config.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	newLogger.SetReportCaller(true)
+	newLogger.SetFormatter(formatter)
+
+	if config.NoLogFiles {
+		return newLogger, nil
+	}
+
+	logDir := config.LogDir
+	if logDir == "" {
+		logDir = LOG_DIR_DEFAULT
+	}
+
+	newRotatingFile := func(filename string) *RotatingFile {
+		return &RotatingFile{
+			Filename:       filepath.Join(logDir, filename),
+			MaxSize:        int64(config.MaxSizeMB) * 1024 * 1024,
+			RotateInterval: config.RotateInterval,
+			MaxBackups:     config.MaxBackups,
+			MaxAge:         config.MaxAge,
+		}
+	}
+
+	errorFile := newRotatingFile("error.log")
+	writerMap := lfshook.WriterMap{
+		logrus.InfoLevel:  newRotatingFile("info.log"),
+		logrus.WarnLevel:  newRotatingFile("warn.log"),
+		logrus.ErrorLevel: errorFile,
+		logrus.FatalLevel: errorFile,
+		logrus.PanicLevel: errorFile,
+	}
+	newLogger.Hooks.Add(lfshook.NewHook(
+		writerMap,
 		formatter,
 	))
-	logger.WriterLevel(logrus.InfoLevel)
+
+	return newLogger, nil
+}
+
+func newFormatter(format string) (logrus.Formatter, error) {
+	callerPrettyfier := func(f *runtime.Frame) (string, string) {
+		filename := filepath.Base(f.File)
+		funcRelativePathIndex := strings.LastIndex(f.Function, ".") + 1
+		funcName := f.Function[funcRelativePathIndex:]
+		return funcName, fmt.Sprintf("%s:%d", filename, f.Line)
+	}
+
+	switch format {
+	case "", LOG_FORMAT_TEXT:
+		formatter := &logrus.TextFormatter{
+			TimestampFormat:  "2006-01-02 15:04:05.000",
+			FullTimestamp:    true,
+			CallerPrettyfier: callerPrettyfier,
+		}
+		return formatter, nil
+	case LOG_FORMAT_JSON:
+		formatter := &logrus.JSONFormatter{
+			TimestampFormat:  "2006-01-02 15:04:05.000",
+			CallerPrettyfier: callerPrettyfier,
+		}
+		return formatter, nil
+	default:
+		err := fmt.Errorf("invalid log format:%s", format)
+		return nil, err
+	}
+}
+
+// InitLogger replaces the logger of the library by a logrus logger created from config
+func InitLogger(config Config) error {
+	newLogger, err := NewLogger(config)
+	if err != nil {
+		return err
+	}
+
+	SetLogger(newLogger)
+	return nil
+}
+
+// SetLogger makes the library log with the given logger, nil restores the default one
+func SetLogger(newLogger Logger) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+	logger = newLogger
+}
+
+// Log returns the logger the library logs with, the one set by SetLogger or InitLogger, GetLogger() by default
+func Log() Logger {
+	loggerMutex.RLock()
+	currentLogger := logger
+	loggerMutex.RUnlock()
+	if currentLogger != nil {
+		return currentLogger
+	}
+
+	return GetLogger()
 }
 
+// GetLogger returns the logrus logger of the library: the logger set by SetLogger or InitLogger when it is
+// a *logrus.Logger, otherwise the default one, which writes to stderr and to ./logs
 func GetLogger() *logrus.Logger {
-	if logger == nil {
-		initLogger()
+	loggerMutex.RLock()
+	currentLogger := logger
+	currentDefaultLogger := defaultLogger
+	loggerMutex.RUnlock()
+
+	logrusLogger, ok := currentLogger.(*logrus.Logger)
+	if ok {
+		return logrusLogger
+	}
+	if currentDefaultLogger != nil {
+		return currentDefaultLogger
+	}
+
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+	if defaultLogger == nil {
+		// the default config never fails
+		defaultLogger, _ = NewLogger(Config{})
+	}
+
+	return defaultLogger
+}
+
+// NopLogger discards everything, SetLogger(NopLogger{}) silences the library
+type NopLogger struct{}
+
+func (NopLogger) Debug(args ...interface{})                 {}
+func (NopLogger) Info(args ...interface{})                  {}
+func (NopLogger) Warn(args ...interface{})                  {}
+func (NopLogger) Error(args ...interface{})                 {}
+func (NopLogger) Debugf(format string, args ...interface{}) {}
+func (NopLogger) Infof(format string, args ...interface{})  {}
+func (NopLogger) Warnf(format string, args ...interface{})  {}
+func (NopLogger) Errorf(format string, args ...interface{}) {}
+
+type Fields = logrus.Fields
+
+// fieldLogger is implemented by loggers supporting structured fields
+type fieldLogger interface {
+	WithFields(fields Fields) Logger
+}
+
+// WithFields returns the logger of the library with fields attached to every entry.
+// Loggers without structured fields get them prepended to the message.
+func WithFields(fields Fields) Logger {
+	currentLogger := Log()
+
+	switch currentLogger := currentLogger.(type) {
+	case *logrus.Logger:
+		return currentLogger.WithFields(fields)
+	case *logrus.Entry:
+		return currentLogger.WithFields(fields)
+	case fieldLogger:
+		return currentLogger.WithFields(fields)
+	case NopLogger:
+		return currentLogger
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	prefix := ""
+	for _, key := range keys {
+		prefix = prefix + fmt.Sprintf("%s=%v ", key, fields[key])
 	}
-	return logger
+
+	return &prefixLogger{logger: currentLogger, prefix: prefix}
+}
+
+type prefixLogger struct {
+	logger Logger
+	prefix string
+}
+
+func (prefixLogger *prefixLogger) Debug(args ...interface{}) {
+	prefixLogger.logger.Debug(append([]interface{}{prefixLogger.prefix}, args...)...)
+}
+
+func (prefixLogger *prefixLogger) Info(args ...interface{}) {
+	prefixLogger.logger.Info(append([]interface{}{prefixLogger.prefix}, args...)...)
+}
+
+func (prefixLogger *prefixLogger) Warn(args ...interface{}) {
+	prefixLogger.logger.Warn(append([]interface{}{prefixLogger.prefix}, args...)...)
+}
+
+func (prefixLogger *prefixLogger) Error(args ...interface{}) {
+	prefixLogger.logger.Error(append([]interface{}{prefixLogger.prefix}, args...)...)
+}
+
+func (prefixLogger *prefixLogger) Debugf(format string, args ...interface{}) {
+	prefixLogger.logger.Debugf("%s"+format, append([]interface{}{prefixLogger.prefix}, args...)...)
+}
+
+func (prefixLogger *prefixLogger) Infof(format string, args ...interface{}) {
+	prefixLogger.logger.Infof("%s"+format, append([]interface{}{prefixLogger.prefix}, args...)...)
+}
+
+func (prefixLogger *prefixLogger) Warnf(format string, args ...interface{}) {
+	prefixLogger.logger.Warnf("%s"+format, append([]interface{}{prefixLogger.prefix}, args...)...)
+}
+
+func (prefixLogger *prefixLogger) Errorf(format string, args ...interface{}) {
+	prefixLogger.logger.Errorf("%s"+format, append([]interface{}{prefixLogger.prefix}, args...)...)
 }
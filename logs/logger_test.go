@@ -0,0 +1,105 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type recordingLogger struct {
+	NopLogger
+	errors []string
+}
+
+func (recordingLogger *recordingLogger) Error(args ...interface{}) {
+	recordingLogger.errors = append(recordingLogger.errors, fmt.Sprint(args...))
+}
+
+func TestGetLoggerKeepsLogrusApi(t *testing.T) {
+	defer SetLogger(nil)
+
+	output := &bytes.Buffer{}
+	logrusLogger, err := NewLogger(Config{Output: output, NoLogFiles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetLogger(logrusLogger)
+
+	if GetLogger() != logrusLogger {
+		t.Fatal("GetLogger does not return the logrus logger set")
+	}
+	GetLogger().SetLevel(logrus.WarnLevel)
+	GetLogger().WithField(FIELD_DEAL_CID, "bafy").Warn("warned")
+	Log().Info("not logged")
+
+	if !strings.Contains(output.String(), "warned") || !strings.Contains(output.String(), "deal_cid=bafy") {
+		t.Fatalf("output: %q", output.String())
+	}
+	if strings.Contains(output.String(), "not logged") {
+		t.Fatalf("level of GetLogger ignored by Log: %q", output.String())
+	}
+}
+
+func TestLogUsesPluggedLogger(t *testing.T) {
+	defer SetLogger(nil)
+
+	recordingLogger := &recordingLogger{}
+	SetLogger(recordingLogger)
+
+	Log().Error("failed")
+	WithFields(Fields{FIELD_DEAL_ID: 1}).Error("failed")
+	if len(recordingLogger.errors) != 2 {
+		t.Fatalf("errors logged: got %d, want 2", len(recordingLogger.errors))
+	}
+
+	// callers of the logrus api still get a logger
+	if GetLogger() == nil {
+		t.Fatal("GetLogger returned nil with a plugged logger")
+	}
+}
+
+func TestJsonFormatWithFields(t *testing.T) {
+	defer SetLogger(Log())
+
+	output := &bytes.Buffer{}
+	logDir := t.TempDir()
+	err := InitLogger(Config{Format: LOG_FORMAT_JSON, Output: output, LogDir: logDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	WithFields(Fields{FIELD_DEAL_ID: 1, FIELD_MINER_FID: "f01000"}).Error("failed")
+
+	errorLog, err := os.ReadFile(filepath.Join(logDir, "error.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, logged := range map[string][]byte{"output": output.Bytes(), "error.log": errorLog} {
+		entry := map[string]interface{}{}
+		err := json.Unmarshal(logged, &entry)
+		if err != nil {
+			t.Fatalf("%s is not json: %q", name, logged)
+		}
+		if entry["msg"] != "failed" || entry["level"] != "error" || entry[FIELD_DEAL_ID] != float64(1) || entry[FIELD_MINER_FID] != "f01000" {
+			t.Fatalf("%s: %v", name, entry)
+		}
+	}
+}
+
+func TestWithFieldsPrefixesPlainLoggers(t *testing.T) {
+	defer SetLogger(Log())
+
+	recordingLogger := &recordingLogger{}
+	SetLogger(recordingLogger)
+
+	WithFields(Fields{FIELD_MINER_FID: "f01000", FIELD_DEAL_ID: 1}).Error("failed")
+	if len(recordingLogger.errors) != 1 || recordingLogger.errors[0] != "deal_id=1 miner_fid=f01000 failed" {
+		t.Fatalf("errors logged: %q", recordingLogger.errors)
+	}
+}
@@ -0,0 +1,13 @@
+package logs
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+// TestMain replaces the default logger so that the tests do not write logs under the package directory
+func TestMain(m *testing.M) {
+	defaultLogger, _ = NewLogger(Config{Output: io.Discard, NoLogFiles: true})
+	os.Exit(m.Run())
+}
@@ -0,0 +1,206 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ROTATED_FILE_TIME_FORMAT = "20060102T150405.000"
+
+// RotatingFile is an io.WriteCloser appending to Filename, which is renamed to name-<time>.ext
+// when it exceeds MaxSize or has been written for RotateInterval, or to name-<time>_<n>.ext when
+// it is rotated several times within a millisecond. It is safe for concurrent use.
+type RotatingFile struct {
+	Filename       string
+	MaxSize        int64         // bytes, 0 means no size limit
+	RotateInterval time.Duration // 0 means no time based rotation
+	MaxBackups     int           // rotated files kept, 0 means all
+	MaxAge         time.Duration // rotated files older than this are removed, 0 means never
+
+	mutex      sync.Mutex
+	file       *os.File
+	size       int64
+	openedAt   time.Time
+	lastBackup string // name of the last backup without its sequence number and extension
+	sequence   int    // next sequence number for lastBackup
+}
+
+func (rotatingFile *RotatingFile) Write(p []byte) (int, error) {
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+
+	if rotatingFile.file == nil {
+		err := rotatingFile.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if rotatingFile.shouldRotate(int64(len(p))) {
+		err := rotatingFile.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rotatingFile.file.Write(p)
+	rotatingFile.size += int64(n)
+	return n, err
+}
+
+func (rotatingFile *RotatingFile) Close() error {
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+
+	if rotatingFile.file == nil {
+		return nil
+	}
+
+	err := rotatingFile.file.Close()
+	rotatingFile.file = nil
+	return err
+}
+
+func (rotatingFile *RotatingFile) open() error {
+	err := os.MkdirAll(filepath.Dir(rotatingFile.Filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(rotatingFile.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rotatingFile.file = file
+	rotatingFile.size = fileInfo.Size()
+	rotatingFile.openedAt = time.Now()
+	return nil
+}
+
+func (rotatingFile *RotatingFile) shouldRotate(writeSize int64) bool {
+	if rotatingFile.size == 0 {
+		return false
+	}
+
+	if rotatingFile.MaxSize > 0 && rotatingFile.size+writeSize > rotatingFile.MaxSize {
+		return true
+	}
+
+	return rotatingFile.RotateInterval > 0 && time.Since(rotatingFile.openedAt) >= rotatingFile.RotateInterval
+}
+
+func (rotatingFile *RotatingFile) rotate() error {
+	err := rotatingFile.file.Close()
+	rotatingFile.file = nil
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(rotatingFile.Filename, rotatingFile.backupName(time.Now()))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	rotatingFile.removeOldBackups()
+
+	return rotatingFile.open()
+}
+
+func (rotatingFile *RotatingFile) backupPrefix() (string, string) {
+	ext := filepath.Ext(rotatingFile.Filename)
+	return strings.TrimSuffix(rotatingFile.Filename, ext) + "-", ext
+}
+
+// backupName returns the name of a backup rotated at rotatedAt, the backups of the same millisecond,
+// which os.Rename would replace, get increasing sequence numbers even when the older ones were removed
+func (rotatingFile *RotatingFile) backupName(rotatedAt time.Time) string {
+	prefix, ext := rotatingFile.backupPrefix()
+	name := prefix + rotatedAt.Format(ROTATED_FILE_TIME_FORMAT)
+	if name != rotatingFile.lastBackup {
+		rotatingFile.lastBackup = name
+		rotatingFile.sequence = 0
+	}
+
+	for {
+		backupName := name + ext
+		if rotatingFile.sequence > 0 {
+			backupName = fmt.Sprintf("%s_%d%s", name, rotatingFile.sequence, ext)
+		}
+		rotatingFile.sequence++
+
+		_, err := os.Lstat(backupName)
+		if os.IsNotExist(err) {
+			return backupName
+		}
+	}
+}
+
+// sortBackups sorts backups from the oldest to the newest by the time and the sequence number in their names
+func (rotatingFile *RotatingFile) sortBackups(backups []string) {
+	sort.Slice(backups, func(i, j int) bool {
+		rotatedAtI, sequenceI := rotatingFile.backupOrder(backups[i])
+		rotatedAtJ, sequenceJ := rotatingFile.backupOrder(backups[j])
+		if rotatedAtI != rotatedAtJ {
+			return rotatedAtI < rotatedAtJ
+		}
+		return sequenceI < sequenceJ
+	})
+}
+
+// backupOrder returns the time and the sequence number in the name of a backup
+func (rotatingFile *RotatingFile) backupOrder(backup string) (string, int) {
+	prefix, ext := rotatingFile.backupPrefix()
+	rotatedAt := strings.TrimSuffix(strings.TrimPrefix(backup, prefix), ext)
+
+	rotatedAt, sequence, found := strings.Cut(rotatedAt, "_")
+	if !found {
+		return rotatedAt, 0
+	}
+
+	sequenceNum, err := strconv.Atoi(sequence)
+	if err != nil {
+		return rotatedAt, 0
+	}
+
+	return rotatedAt, sequenceNum
+}
+
+// removeOldBackups is best effort, a backup that cannot be removed is left for the next rotation
+func (rotatingFile *RotatingFile) removeOldBackups() {
+	if rotatingFile.MaxBackups <= 0 && rotatingFile.MaxAge <= 0 {
+		return
+	}
+
+	prefix, ext := rotatingFile.backupPrefix()
+	backups, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return
+	}
+
+	rotatingFile.sortBackups(backups)
+
+	for i, backup := range backups {
+		remove := rotatingFile.MaxBackups > 0 && i < len(backups)-rotatingFile.MaxBackups
+		if !remove && rotatingFile.MaxAge > 0 {
+			fileInfo, err := os.Stat(backup)
+			remove = err == nil && time.Since(fileInfo.ModTime()) > rotatingFile.MaxAge
+		}
+
+		if remove {
+			os.Remove(backup)
+		}
+	}
+}
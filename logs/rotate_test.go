@@ -0,0 +1,93 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readBackups returns the contents of the backups of rotatingFile from the oldest to the newest
+func readBackups(t *testing.T, rotatingFile *RotatingFile) []string {
+	prefix, ext := rotatingFile.backupPrefix()
+	backups, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatingFile.sortBackups(backups)
+
+	contents := []string{}
+	for _, backup := range backups {
+		content, err := os.ReadFile(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(content))
+	}
+
+	return contents
+}
+
+func writeLines(t *testing.T, rotatingFile *RotatingFile, lines ...string) {
+	for _, line := range lines {
+		_, err := rotatingFile.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRotatingFileRotatesAtMaxSize(t *testing.T) {
+	rotatingFile := &RotatingFile{Filename: filepath.Join(t.TempDir(), "info.log"), MaxSize: 10}
+	defer rotatingFile.Close()
+
+	// each line would take the file beyond MaxSize, the rotations happen within the same millisecond
+	lines := []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n", "line 5\n"}
+	writeLines(t, rotatingFile, lines...)
+
+	backups := readBackups(t, rotatingFile)
+	if strings.Join(backups, "|") != strings.Join(lines[:4], "|") {
+		t.Fatalf("backups: got %q, want %q", backups, lines[:4])
+	}
+
+	content, err := os.ReadFile(rotatingFile.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != lines[4] {
+		t.Fatalf("log file: got %q, want %q", content, lines[4])
+	}
+}
+
+func TestRotatingFileKeepsMaxBackups(t *testing.T) {
+	rotatingFile := &RotatingFile{Filename: filepath.Join(t.TempDir(), "info.log"), MaxSize: 10, MaxBackups: 2}
+	defer rotatingFile.Close()
+
+	writeLines(t, rotatingFile, "line 1\n", "line 2\n", "line 3\n", "line 4\n", "line 5\n")
+
+	backups := readBackups(t, rotatingFile)
+	if len(backups) != 2 || backups[0] != "line 3\n" || backups[1] != "line 4\n" {
+		t.Fatalf("backups: got %q, want the 2 newest", backups)
+	}
+}
+
+func TestRotatingFileBackupNameAvoidsCollisions(t *testing.T) {
+	rotatingFile := &RotatingFile{Filename: filepath.Join(t.TempDir(), "info.log")}
+	rotatedAt := time.Date(2023, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	names := []string{}
+	for i := 0; i < 3; i++ {
+		name := rotatingFile.backupName(rotatedAt)
+		err := os.WriteFile(name, nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.Base(name))
+	}
+
+	want := []string{"info-20230102T030405.006.log", "info-20230102T030405.006_1.log", "info-20230102T030405.006_2.log"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("backup names: got %v, want %v", names, want)
+	}
+}
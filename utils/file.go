@@ -21,7 +21,7 @@ func IsFileExists(filePath, fileName string) bool {
 	_, err := os.Stat(fileFullPath)
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return false
 	}
 
@@ -32,7 +32,7 @@ func IsFileExistsFullPath(fileFullPath string) bool {
 	_, err := os.Stat(fileFullPath)
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return false
 	}
 
@@ -43,7 +43,7 @@ func IsPathFile(dirFullPath string) (*bool, error) {
 	fi, err := os.Stat(dirFullPath)
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 
@@ -56,7 +56,7 @@ func IsPathFile(dirFullPath string) (*bool, error) {
 		return &isFile, nil
 	default:
 		err := fmt.Errorf("unknown path type")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil, err
 	}
 }
@@ -65,7 +65,7 @@ func GetPathType(dirFullPath string) int {
 	fi, err := os.Stat(dirFullPath)
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return constants.PATH_TYPE_NOT_EXIST
 	}
 
@@ -83,14 +83,14 @@ func RemoveFile(filePath, fileName string) {
 	fileFullPath := filepath.Join(filePath, fileName)
 	err := os.Remove(fileFullPath)
 	if err != nil {
-		logs.GetLogger().Error(err.Error())
+		logs.Log().Error(err.Error())
 	}
 }
 
 func GetFileSize(fileFullPath string) int64 {
 	fi, err := os.Stat(fileFullPath)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return -1
 	}
 
@@ -101,7 +101,7 @@ func GetFileSize2(dir, fileName string) int64 {
 	fileFullPath := filepath.Join(dir, fileName)
 	fi, err := os.Stat(fileFullPath)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return -1
 	}
 
@@ -111,19 +111,19 @@ func GetFileSize2(dir, fileName string) int64 {
 func CopyFile(srcFilePath, destFilePath string) (int64, error) {
 	sourceFileStat, err := os.Stat(srcFilePath)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, err
 	}
 
 	if !sourceFileStat.Mode().IsRegular() {
 		err = errors.New(srcFilePath + " is not a regular file")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, err
 	}
 
 	source, err := os.Open(srcFilePath)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, err
 	}
 
@@ -131,7 +131,7 @@ func CopyFile(srcFilePath, destFilePath string) (int64, error) {
 
 	destination, err := os.Create(destFilePath)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, err
 	}
 
@@ -139,7 +139,7 @@ func CopyFile(srcFilePath, destFilePath string) (int64, error) {
 
 	nBytes, err := io.Copy(destination, source)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, err
 	}
 
@@ -150,7 +150,7 @@ func CreateFileWithContents(filefullpath string, lines []string) (int, error) {
 	f, err := os.Create(filefullpath)
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, nil
 	}
 
@@ -160,18 +160,18 @@ func CreateFileWithContents(filefullpath string, lines []string) (int, error) {
 	for _, line := range lines {
 		bytesWritten1, err := f.WriteString(line + "\n")
 		if err != nil {
-			logs.GetLogger().Error(err)
+			logs.Log().Error(err)
 			return 0, nil
 		}
 		bytesWritten = bytesWritten + bytesWritten1
 	}
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, nil
 	}
 
-	logs.GetLogger().Info(filefullpath, " generated.")
+	logs.Log().Info(filefullpath, " generated.")
 	return bytesWritten, nil
 }
 
@@ -179,7 +179,7 @@ func CreateFileWithByteContents(filefullpath string, contents []byte) (int, erro
 	f, err := os.Create(filefullpath)
 
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, nil
 	}
 
@@ -187,11 +187,11 @@ func CreateFileWithByteContents(filefullpath string, contents []byte) (int, erro
 
 	bytesWritten, err := f.Write(contents)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return 0, nil
 	}
 
-	logs.GetLogger().Info(filefullpath, " generated.")
+	logs.Log().Info(filefullpath, " generated.")
 	return bytesWritten, nil
 }
 
@@ -201,7 +201,7 @@ func ReadAllLines(dir, filename string) ([]string, error) {
 	file, err := os.Open(fileFullPath)
 
 	if err != nil {
-		logs.GetLogger().Error("failed opening file: ", fileFullPath)
+		logs.Log().Error("failed opening file: ", fileFullPath)
 		return nil, err
 	}
 
@@ -221,20 +221,20 @@ func ReadAllLines(dir, filename string) ([]string, error) {
 func ReadFile(filePath string) (string, []byte, error) {
 	sourceFileStat, err := os.Stat(filePath)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return "", nil, err
 	}
 
 	if !sourceFileStat.Mode().IsRegular() {
 		err = errors.New(filePath + " is not a regular file")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return "", nil, err
 	}
 
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		logs.GetLogger().Error("failed reading data from file: ", filePath)
-		logs.GetLogger().Error(err)
+		logs.Log().Error("failed reading data from file: ", filePath)
+		logs.Log().Error(err)
 		return "", nil, err
 	}
 
@@ -244,7 +244,7 @@ func ReadFile(filePath string) (string, []byte, error) {
 func IsDirExists(dir string) bool {
 	if IsStrEmpty(&dir) {
 		err := fmt.Errorf("dir is not provided")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return false
 	}
 
@@ -258,14 +258,14 @@ func IsDirExists(dir string) bool {
 func CreateDir(dir string) error {
 	if len(dir) == 0 {
 		err := fmt.Errorf("dir is not provided")
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
 	err := os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
 		err := fmt.Errorf("%s, failed to create output dir:%s", err.Error(), dir)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -280,7 +280,7 @@ func GenerateFile(filepath, filename string, filesize int64) {
 		return
 	}
 
-	logs.GetLogger().Info("start to generate file:", filefullpath, ", target size:", filesize, "GB")
+	logs.Log().Info("start to generate file:", filefullpath, ", target size:", filesize, "GB")
 
 	filesizeInByte := filesize * 100000000
 	var i int64
@@ -299,13 +299,13 @@ func GenerateFile(filepath, filename string, filesize int64) {
 		return
 	}
 
-	logs.GetLogger().Info("file:", filefullpath, " generated, size:", filesize, "GB")
+	logs.Log().Info("file:", filefullpath, " generated, size:", filesize, "GB")
 }
 
 func CreateDirIfNotExists(dir, dirName string) error {
 	if IsStrEmpty(&dir) {
 		err := fmt.Errorf("%s directory is required", dirName)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
@@ -316,24 +316,24 @@ func CreateDirIfNotExists(dir, dirName string) error {
 	err := os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
 		err := fmt.Errorf("failed to create %s directory:%s,%s", dirName, dir, err.Error())
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
-	logs.GetLogger().Info(dirName, " directory: ", dir, " created")
+	logs.Log().Info(dirName, " directory: ", dir, " created")
 	return nil
 }
 
 func CheckDirExists(dir, dirName string) error {
 	if IsStrEmpty(&dir) {
 		err := fmt.Errorf("%s directory is required", dirName)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
 	if !IsDirExists(dir) {
 		err := fmt.Errorf("%s directory:%s not exists", dirName, dir)
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return err
 	}
 
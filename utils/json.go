@@ -10,12 +10,12 @@ func GetFieldFromJson(jsonBytes []byte, fieldName string) interface{} {
 	var result map[string]interface{}
 	err := json.Unmarshal(jsonBytes, &result)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return nil
 	}
 
 	if result == nil {
-		logs.GetLogger().Error("Failed to parse ", jsonBytes, " as map[string]interface{}.")
+		logs.Log().Error("Failed to parse ", jsonBytes, " as map[string]interface{}.")
 		return nil
 	}
 
@@ -40,7 +40,7 @@ func GetFieldStrFromJson(jsonBytes []byte, fieldName string) string {
 func GetFieldMapFromJson(jsonBytes []byte, fieldName string) map[string]interface{} {
 	fieldVal := GetFieldFromJson(jsonBytes, fieldName)
 	if fieldVal == nil {
-		logs.GetLogger().Info("No ", fieldName, " in ", jsonBytes)
+		logs.Log().Info("No ", fieldName, " in ", jsonBytes)
 		return nil
 	}
 
@@ -55,7 +55,7 @@ func GetFieldMapFromJson(jsonBytes []byte, fieldName string) map[string]interfac
 func ToJson(obj interface{}) string {
 	jsonBytes, err := json.Marshal(obj)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return ""
 	}
 
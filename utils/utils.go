@@ -30,7 +30,7 @@ func GetCurrentUtcSecond() int64 {
 func GetInt64FromStr(numStr string) int64 {
 	num, err := strconv.ParseInt(numStr, 10, 64)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return -1
 	}
 
@@ -49,7 +49,7 @@ func GetFloat64FromStr(numStr *string) (float64, error) {
 
 	num, err := strconv.ParseFloat(*numStr, 64)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return -1, err
 	}
 
@@ -59,7 +59,7 @@ func GetFloat64FromStr(numStr *string) (float64, error) {
 func GetIntFromStr(numStr string) (int, error) {
 	num, err := strconv.ParseInt(numStr, 10, 32)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return -1, err
 	}
 
@@ -69,7 +69,7 @@ func GetIntFromStr(numStr string) (int, error) {
 func GetNumStrFromStr(numStr string) string {
 	re := regexp.MustCompile("[0-9]+.?[0-9]*")
 	words := re.FindAllString(numStr, -1)
-	//logs.GetLogger().Info("words:", words)
+	//logs.Log().Info("words:", words)
 	if len(words) > 0 {
 		return words[0]
 	}
@@ -83,7 +83,7 @@ func GetByteSizeFromStr(sizeStr string) int64 {
 	numStr = strings.Trim(numStr, " ")
 	size, err := strconv.ParseInt(numStr, 10, 64)
 	if err != nil {
-		logs.GetLogger().Error(err)
+		logs.Log().Error(err)
 		return -1
 	}
 	unit := strings.Trim(sizeStr, numStr)
@@ -172,7 +172,7 @@ func GetDecimalFromStr(source string) (*decimal.Decimal, error) {
 		numStr := strings.Trim(words[0], " ")
 		result, err := decimal.NewFromString(numStr)
 		if err != nil {
-			logs.GetLogger().Error(err)
+			logs.Log().Error(err)
 			return nil, err
 		}
 		return &result, nil
@@ -250,11 +250,11 @@ func CalculatePieceSize(fileSize int64, isBoost bool) (int64, float64) {
 }
 
 func CalculateRealCost(sectorSizeBytes float64, pricePerGiB decimal.Decimal) decimal.Decimal {
-	//logs.GetLogger().Info("sectorSizeBytes:", sectorSizeBytes, " pricePerGiB:", pricePerGiB)
+	//logs.Log().Info("sectorSizeBytes:", sectorSizeBytes, " pricePerGiB:", pricePerGiB)
 	bytesPerGiB := decimal.NewFromInt(1024 * 1024 * 1024)
 	sectorSizeGiB := decimal.NewFromFloat(sectorSizeBytes).Div(bytesPerGiB)
 	realCost := sectorSizeGiB.Mul(pricePerGiB)
-	//logs.GetLogger().Info("realCost:", realCost)
+	//logs.Log().Info("realCost:", realCost)
 	return realCost
 }
 
@@ -275,7 +275,7 @@ func Convert2Title(text string) string {
 	}
 
 	result = result + "."
-	//logs.GetLogger().Info(result)
+	//logs.Log().Info(result)
 	return result
 }
 
@@ -295,7 +295,7 @@ func SearchFloat64FromStr(source string) *float64 {
 		numStr := strings.Trim(words[0], " ")
 		result, err := strconv.ParseFloat(numStr, 64)
 		if err != nil {
-			logs.GetLogger().Error(err)
+			logs.Log().Error(err)
 			return nil
 		}
 		return &result
@@ -314,7 +314,7 @@ func ConvertPrice2AttoFil(price string) string {
 	}
 	priceAttoFil, err := decimal.NewFromString(fields[0])
 	if err != nil {
-		logs.GetLogger().Error()
+		logs.Log().Error()
 		return ""
 	}
 	unit := strings.ToUpper(fields[1])